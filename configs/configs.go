@@ -107,29 +107,150 @@ type ProviderConfig struct {
 
 // ProviderInstanceConfig individual provider instance configuration
 type ProviderInstanceConfig struct {
-	Name        string   `mapstructure:"NAME"`        // Instance name
-	Enabled     bool     `mapstructure:"ENABLED"`     // Whether instance is enabled
-	BaseURL     string   `mapstructure:"BASE_URL"`    // API base URL
-	Keys        []string `mapstructure:"KEYS"`        // API key list
-	Models      []string `mapstructure:"MODELS"`      // Available model list
-	MaxTokens   int      `mapstructure:"MAX_TOKENS"`  // Maximum output tokens, controls response length
-	Temperature float32  `mapstructure:"TEMPERATURE"` // Sampling temperature (0.0-2.0), higher=more creative, lower=more focused
-	TopP        float32  `mapstructure:"TOP_P"`       // Nucleus sampling (0.0-1.0), controls vocabulary diversity, typically 0.9-0.95
-	TopK        int      `mapstructure:"TOP_K"`       // Limits candidate words, 0=unlimited, typically 40-100
-	Timeout     int      `mapstructure:"TIMEOUT"`     // Request timeout (seconds)
-	MaxRetries  int      `mapstructure:"MAX_RETRIES"` // Maximum retry attempts
-	RateLimit   string   `mapstructure:"RATE_LIMIT"`  // Rate limit (e.g., "60/min", "1/s")
+	Name            string   `mapstructure:"NAME"`             // Instance name
+	Enabled         bool     `mapstructure:"ENABLED"`          // Whether instance is enabled
+	BaseURL         string   `mapstructure:"BASE_URL"`         // API base URL
+	Keys            []string `mapstructure:"KEYS"`             // API key list
+	Models          []string `mapstructure:"MODELS"`           // Available model list
+	EmbeddingModels []string `mapstructure:"EMBEDDING_MODELS"` // Available embedding model list, empty=no embeddings
+	MaxTokens       int      `mapstructure:"MAX_TOKENS"`       // Maximum output tokens, controls response length
+	Temperature     float32  `mapstructure:"TEMPERATURE"`      // Sampling temperature (0.0-2.0), higher=more creative, lower=more focused
+	TopP            float32  `mapstructure:"TOP_P"`            // Nucleus sampling (0.0-1.0), controls vocabulary diversity, typically 0.9-0.95
+	TopK            int      `mapstructure:"TOP_K"`            // Limits candidate words, 0=unlimited, typically 40-100
+	Timeout         int      `mapstructure:"TIMEOUT"`          // Request timeout (seconds)
+	MaxRetries      int      `mapstructure:"MAX_RETRIES"`      // Maximum retry attempts
+	RateLimit       string   `mapstructure:"RATE_LIMIT"`       // Rate limit (e.g., "60/min", "1/s")
+
+	ThinkingBudget  int                   `mapstructure:"THINKING_BUDGET"`  // Extended thinking token budget, 0=disabled (anthropic)
+	IncludeThoughts *bool                 `mapstructure:"INCLUDE_THOUGHTS"` // Whether thought summaries are returned as reasoning content, unset=true (gemini)
+	KeepAlive       string                `mapstructure:"KEEP_ALIVE"`       // How long the model stays loaded after a request, e.g. "5m", "-1"=forever (ollama)
+	ModelOptions    []ModelOptionsConfig  `mapstructure:"MODEL_OPTIONS"`    // Per-model runtime options (ollama)
+	ContextWindows  []ContextWindowConfig `mapstructure:"CONTEXT_WINDOWS"`  // Per-model context window, models without one are not guarded
+	Retry           RetryConfig           `mapstructure:"RETRY"`            // Retry backoff and jitter, the number of retries is MAX_RETRIES
+	Weight          int                   `mapstructure:"WEIGHT"`           // Share of requests under weighted routing, 0=1
+	Priority        int                   `mapstructure:"PRIORITY"`         // Tier under priority routing, lower tiers are tried first
+	Mode            string                `mapstructure:"MODE"`             // record or replay, unset=replay (replay)
+	Upstream        string                `mapstructure:"UPSTREAM"`         // Provider type calls are recorded from in record mode, e.g. "openai" (replay)
+	FixtureDir      string                `mapstructure:"FIXTURE_DIR"`      // Directory of the request/response fixtures (replay)
+}
+
+// ContextWindowConfig context window of a model, MODEL also matches versioned names it is a prefix of
+type ContextWindowConfig struct {
+	Model  string `mapstructure:"MODEL"`  // Model name
+	Tokens int    `mapstructure:"TOKENS"` // Prompt plus output tokens the model accepts
+}
+
+// ModelOptionsConfig runtime options applied to a single model
+type ModelOptionsConfig struct {
+	Model   string         `mapstructure:"MODEL"`   // Model name
+	Options map[string]any `mapstructure:"OPTIONS"` // Options passed through to the server, e.g. num_ctx, num_gpu
 }
 
 // PromptConfig prompt template configuration
 type PromptConfig struct {
-	Dir string `mapstructure:"DIR"` // Prompt templates directory
+	Dir   string `mapstructure:"DIR"`   // Prompt templates directory
+	Store string `mapstructure:"STORE"` // Template store: file or database, read at startup, unset=file
+	Seed  bool   `mapstructure:"SEED"`  // Import the templates of DIR missing from the database store on first use
+}
+
+// RetryConfig provider instance retry backoff configuration
+type RetryConfig struct {
+	BaseDelay int      `mapstructure:"BASE_DELAY"` // Delay before the first retry (milliseconds), doubled on every retry, 0=500
+	MaxDelay  int      `mapstructure:"MAX_DELAY"`  // Longest delay, a longer Retry-After fails over instead (milliseconds), 0=30000
+	Jitter    *float64 `mapstructure:"JITTER"`     // Random fraction taken off each delay (0.0-1.0), unset=0.2
+}
+
+// CircuitBreakerConfig provider instance circuit breaker configuration
+type CircuitBreakerConfig struct {
+	FailureThreshold int `mapstructure:"FAILURE_THRESHOLD"` // Consecutive failures before an instance is tripped
+	Cooldown         int `mapstructure:"COOLDOWN"`          // Seconds a tripped instance waits before a half-open probe
+}
+
+// RoutingConfig instance routing configuration
+type RoutingConfig struct {
+	Strategy string `mapstructure:"STRATEGY"` // round_robin, weighted, least_latency or priority, unset=round_robin
+}
+
+// KeyQuarantineConfig API key quarantine configuration, keys rejected with 401/403 stay quarantined until the config is reloaded
+type KeyQuarantineConfig struct {
+	FailureThreshold int `mapstructure:"FAILURE_THRESHOLD"` // Consecutive rate limit failures before a key is quarantined
+	Cooldown         int `mapstructure:"COOLDOWN"`          // Seconds a key with exhausted quota or repeated rate limits is skipped
+}
+
+// ModelPriceConfig price of a model in currency units per million tokens
+type ModelPriceConfig struct {
+	Model  string  `mapstructure:"MODEL"`  // Model name, also matches versioned names it prefixes, e.g. "gpt-4o" matches "gpt-4o-2024-08-06"
+	Input  float64 `mapstructure:"INPUT"`  // Price per million prompt tokens
+	Output float64 `mapstructure:"OUTPUT"` // Price per million completion tokens
+}
+
+// UsageConfig AI usage accounting configuration
+type UsageConfig struct {
+	Enabled bool               `mapstructure:"ENABLED"` // Whether every provider call is persisted to the database
+	Prices  []ModelPriceConfig `mapstructure:"PRICES"`  // Per-model prices used to compute the cost of a call
+}
+
+// QuotaLimitConfig token budget of a caller, 0 means unlimited
+type QuotaLimitConfig struct {
+	Caller  string `mapstructure:"CALLER"`  // Caller ID passed to ai.WithCaller, e.g. a user or tenant ID, unused in DEFAULT
+	Daily   int64  `mapstructure:"DAILY"`   // Tokens per UTC day
+	Monthly int64  `mapstructure:"MONTHLY"` // Tokens per UTC month
+}
+
+// QuotaConfig AI token quota configuration
+type QuotaConfig struct {
+	Enabled bool               `mapstructure:"ENABLED"` // Whether token budgets are enforced
+	Default QuotaLimitConfig   `mapstructure:"DEFAULT"` // Budget of callers without an entry in CALLERS
+	Callers []QuotaLimitConfig `mapstructure:"CALLERS"` // Per-caller budgets
+}
+
+// CacheConfig AI response cache configuration
+type CacheConfig struct {
+	Enabled    bool `mapstructure:"ENABLED"`     // Whether responses of deterministic calls (temperature 0) are cached
+	TTL        int  `mapstructure:"TTL"`         // Default time to live in seconds, overridden by a template cache_ttl
+	MaxEntries int  `mapstructure:"MAX_ENTRIES"` // Capacity of the in-memory fallback used while Redis is unavailable
+}
+
+// ConversationConfig AI conversation memory configuration
+type ConversationConfig struct {
+	Strategy     string `mapstructure:"STRATEGY"`      // History trimming: last_n, token_budget or summarize, unset=last_n
+	MaxMessages  int    `mapstructure:"MAX_MESSAGES"`  // History messages sent by last_n
+	MaxTokens    int    `mapstructure:"MAX_TOKENS"`    // History token budget of token_budget and summarize
+	SummaryModel string `mapstructure:"SUMMARY_MODEL"` // Model that summarizes older turns, empty=instance model rotation
+	CacheTTL     int    `mapstructure:"CACHE_TTL"`     // Seconds a conversation history stays in the Redis hot cache
+}
+
+// ContextGuardConfig prompt length guard configuration
+type ContextGuardConfig struct {
+	Policy       string `mapstructure:"POLICY"`        // reject or truncate prompts beyond the context window, unset=reject
+	TokenizerDir string `mapstructure:"TOKENIZER_DIR"` // Directory of tiktoken files (cl100k_base.tiktoken, o200k_base.tiktoken), empty=estimate
+}
+
+// GatewayConfig OpenAI-compatible gateway configuration, served under /v1
+type GatewayConfig struct {
+	Enabled bool               `mapstructure:"ENABLED"` // Serve /v1/chat/completions and /v1/models
+	Keys    []GatewayKeyConfig `mapstructure:"KEYS"`    // Gateway-issued API keys, sent by clients as Bearer tokens
+}
+
+// GatewayKeyConfig gateway API key and the caller its requests are accounted to
+type GatewayKeyConfig struct {
+	Key    string `mapstructure:"KEY"`    // API key
+	Caller string `mapstructure:"CALLER"` // Caller for usage accounting and quotas, empty=no caller
 }
 
 // AIConfig AI service configuration
 type AIConfig struct {
-	Providers map[string]ProviderConfig `mapstructure:"PROVIDERS"` // Provider configurations
-	Prompt    PromptConfig              `mapstructure:"PROMPT"`    // Prompt template configuration
+	Providers      map[string]ProviderConfig `mapstructure:"PROVIDERS"`       // Provider configurations
+	Prompt         PromptConfig              `mapstructure:"PROMPT"`          // Prompt template configuration
+	Routing        RoutingConfig             `mapstructure:"ROUTING"`         // Instance routing configuration, requests with a model only go to instances listing it
+	CircuitBreaker CircuitBreakerConfig      `mapstructure:"CIRCUIT_BREAKER"` // Instance circuit breaker configuration
+	KeyQuarantine  KeyQuarantineConfig       `mapstructure:"KEY_QUARANTINE"`  // API key quarantine configuration
+	Usage          UsageConfig               `mapstructure:"USAGE"`           // Usage and cost accounting configuration
+	Quota          QuotaConfig               `mapstructure:"QUOTA"`           // Per-caller token budget configuration
+	Cache          CacheConfig               `mapstructure:"CACHE"`           // Response cache configuration
+	Conversation   ConversationConfig        `mapstructure:"CONVERSATION"`    // Conversation memory configuration
+	ContextGuard   ContextGuardConfig        `mapstructure:"CONTEXT_GUARD"`   // Context window guard configuration
+	Gateway        GatewayConfig             `mapstructure:"GATEWAY"`         // OpenAI-compatible gateway configuration
 }
 
 // Config main configuration structure
@@ -158,7 +279,7 @@ var (
 // New initializes configuration
 // - ENV=prod uses production configuration
 func New() error {
-	v = viper.New()
+	cv := viper.New()
 
 	env := os.Getenv("ENV")
 	var configPath string
@@ -171,19 +292,23 @@ func New() error {
 		configPath = DefaultConfigPath
 	}
 
-	v.SetConfigFile(configPath)
+	cv.SetConfigFile(configPath)
 
-	if err := v.ReadInConfig(); err != nil {
+	if err := cv.ReadInConfig(); err != nil {
 		return fmt.Errorf("failed to read config file %s: %w", configPath, err)
 	}
 
 	var config Config
-	if err := v.Unmarshal(&config); err != nil {
+	if err := cv.Unmarshal(&config); err != nil {
 		return fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	mu.Lock()
+	v = cv
 	instance = &config
-	go monitorConfigChanges()
+	mu.Unlock()
+
+	go monitorConfigChanges(cv)
 	return nil
 }
 
@@ -201,7 +326,7 @@ func GetConfig() (*Config, error) {
 }
 
 // monitorConfigChanges monitors configuration changes
-func monitorConfigChanges() {
+func monitorConfigChanges(v *viper.Viper) {
 	v.WatchConfig()
 	v.OnConfigChange(func(e fsnotify.Event) {
 		var newConfig Config
@@ -4,25 +4,118 @@
 package ai
 
 import (
+	"context"
+	"time"
+
 	"github.com/Done-0/gin-scaffold/configs"
 	"github.com/Done-0/gin-scaffold/internal/ai/internal"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/cache"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/conversation"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/prompter"
 	"github.com/Done-0/gin-scaffold/internal/ai/internal/provider"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/recorder"
+	"github.com/Done-0/gin-scaffold/internal/db"
+	"github.com/Done-0/gin-scaffold/internal/redis"
+
+	conversationModel "github.com/Done-0/gin-scaffold/internal/model/conversation"
 )
 
 type (
 	AIManager          = internal.Manager
+	BreakerState       = provider.BreakerState
+	CacheStats         = cache.Stats
+	Call               = provider.Call
 	ChatRequest        = provider.ChatRequest
 	ChatResponse       = provider.ChatResponse
 	ChatStreamResponse = provider.ChatStreamResponse
 	Choice             = provider.Choice
+	Conversation       = conversationModel.Conversation
+	ConversationQuery  = conversation.ConversationQuery
+	ContentPart        = provider.ContentPart
+	Embedding          = provider.Embedding
+	EmbeddingRequest   = provider.EmbeddingRequest
+	EmbeddingResponse  = provider.EmbeddingResponse
+	FunctionCall       = provider.FunctionCall
+	FunctionDefinition = provider.FunctionDefinition
+	KeyState           = provider.KeyState
 	Message            = provider.Message
 	MessageDelta       = provider.MessageDelta
 	Provider           = provider.Provider
+	ReasoningBlock     = provider.ReasoningBlock
+	ResponseFormat     = provider.ResponseFormat
 	StreamChoice       = provider.StreamChoice
+	Template           = prompter.Template
+	TemplateVersion    = prompter.TemplateVersion
+	Tool               = provider.Tool
+	ToolCall           = provider.ToolCall
+	ToolChoice         = provider.ToolChoice
 	Usage              = provider.Usage
+	UsageQuery         = recorder.UsageQuery
+	UsageSummary       = recorder.UsageSummary
+	Variable           = prompter.Variable
+	VariableError      = prompter.VariableError
 )
 
-// New creates a new AI manager instance
-func New(config *configs.Config) (*AIManager, error) {
-	return internal.New(config)
+// Message roles, content part types, tool choice types, response format types, circuit breaker and API key states
+const (
+	RoleSystem               = provider.RoleSystem
+	RoleUser                 = provider.RoleUser
+	RoleAssistant            = provider.RoleAssistant
+	RoleTool                 = provider.RoleTool
+	PartTypeText             = provider.PartTypeText
+	PartTypeImageURL         = provider.PartTypeImageURL
+	PartTypeImage            = provider.PartTypeImage
+	PartTypeFile             = provider.PartTypeFile
+	ToolTypeFunction         = provider.ToolTypeFunction
+	ToolChoiceAuto           = provider.ToolChoiceAuto
+	ToolChoiceNone           = provider.ToolChoiceNone
+	ToolChoiceRequired       = provider.ToolChoiceRequired
+	ToolChoiceFunction       = provider.ToolChoiceFunction
+	ResponseFormatText       = provider.ResponseFormatText
+	ResponseFormatJSONObject = provider.ResponseFormatJSONObject
+	ResponseFormatJSONSchema = provider.ResponseFormatJSONSchema
+	BreakerClosed            = provider.BreakerClosed
+	BreakerOpen              = provider.BreakerOpen
+	BreakerHalfOpen          = provider.BreakerHalfOpen
+	KeyHealthy               = provider.KeyHealthy
+	KeyQuarantined           = provider.KeyQuarantined
+)
+
+// New creates a new AI manager instance, recording usage through the database manager and enforcing token quotas through Redis
+func New(config *configs.Config, dbManager db.DatabaseManager, redisManager redis.RedisManager) (*AIManager, error) {
+	return internal.New(config, dbManager, redisManager)
+}
+
+// WithCaller returns a context whose AI calls are accounted and budgeted to caller, e.g. a user or tenant ID
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return recorder.WithCaller(ctx, caller)
+}
+
+// CallerFrom returns the caller set by WithCaller, empty when unknown
+func CallerFrom(ctx context.Context) string {
+	return recorder.CallerFrom(ctx)
+}
+
+// WithTemplateChange returns a context whose template creates, updates and rollbacks record author and note in the
+// version they store
+func WithTemplateChange(ctx context.Context, author, note string) context.Context {
+	return prompter.WithChange(ctx, author, note)
+}
+
+// CacheBypassHeader HTTP header whose presence skips the response cache for a request
+const CacheBypassHeader = cache.BypassHeader
+
+// WithCacheTTL returns a context whose deterministic calls are cached for ttl, e.g. a template CacheTTL
+func WithCacheTTL(ctx context.Context, ttl time.Duration) context.Context {
+	return cache.WithTTL(ctx, ttl)
+}
+
+// WithoutCache returns a context whose calls neither read nor fill the response cache
+func WithoutCache(ctx context.Context) context.Context {
+	return cache.WithBypass(ctx)
+}
+
+// DecodeJSON decodes a structured response into T and validates it with its validate struct tags
+func DecodeJSON[T any](resp *ChatResponse) (*T, error) {
+	return provider.DecodeJSON[T](resp)
 }
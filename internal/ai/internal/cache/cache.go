@@ -0,0 +1,200 @@
+// Package cache provides response caching for deterministic AI calls
+// Author: Done-0
+// Created: 2025-09-25
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	goRedis "github.com/redis/go-redis/v9"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/provider"
+	"github.com/Done-0/gin-scaffold/internal/redis"
+)
+
+// BypassHeader HTTP header whose presence skips the cache for a request
+const BypassHeader = "X-AI-Cache-Bypass"
+
+// keyPrefix prefix of the Redis keys, followed by the request hash
+const keyPrefix = "ai:cache:"
+
+type (
+	ttlKey    struct{}
+	bypassKey struct{}
+)
+
+// WithTTL returns a context whose cacheable calls are cached for ttl instead of the configured TTL, e.g. a template cache_ttl
+func WithTTL(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, ttlKey{}, ttl)
+}
+
+// WithBypass returns a context whose calls neither read nor fill the cache
+func WithBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+type cache struct {
+	redisManager redis.RedisManager
+	memory       *memoryStore
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+}
+
+func New(redisManager redis.RedisManager) Cache {
+	return &cache{redisManager: redisManager, memory: newMemoryStore()}
+}
+
+// Get returns the cached response of a cacheable request
+func (c *cache) Get(ctx context.Context, req *provider.ChatRequest) (*provider.ChatResponse, bool) {
+	if _, ok := cacheable(ctx, req); !ok {
+		return nil, false
+	}
+
+	key, err := requestKey(req)
+	if err != nil {
+		return nil, false
+	}
+
+	data, found := c.load(ctx, key)
+	var resp provider.ChatResponse
+	if found && json.Unmarshal(data, &resp) == nil {
+		c.hits.Add(1)
+		return &resp, true
+	}
+	c.misses.Add(1)
+	return nil, false
+}
+
+// Set caches the response of a cacheable request
+func (c *cache) Set(ctx context.Context, req *provider.ChatRequest, resp *provider.ChatResponse) {
+	ttl, ok := cacheable(ctx, req)
+	if !ok || resp == nil {
+		return
+	}
+
+	key, err := requestKey(req)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	c.store(ctx, key, data, ttl)
+}
+
+// SetStream forwards a stream and caches the response it assembles when the stream completes without error
+func (c *cache) SetStream(ctx context.Context, req *provider.ChatRequest, stream <-chan *provider.ChatStreamResponse) <-chan *provider.ChatStreamResponse {
+	if _, ok := cacheable(ctx, req); !ok {
+		return stream
+	}
+
+	ch := make(chan *provider.ChatStreamResponse)
+	go func() {
+		defer close(ch)
+
+		var accumulator provider.StreamAccumulator
+		for resp := range stream {
+			accumulator.Add(resp)
+
+			select {
+			case ch <- resp:
+			case <-ctx.Done():
+				// Drain so the pool can finish the stream
+				for range stream {
+				}
+				return
+			}
+		}
+
+		if resp, ok := accumulator.Response(); ok {
+			c.Set(ctx, req, resp)
+		}
+	}()
+	return ch
+}
+
+// Stats returns the hit and miss counters
+func (c *cache) Stats() Stats {
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// load reads a cached response from Redis, falling back to memory when Redis is unavailable
+func (c *cache) load(ctx context.Context, key string) ([]byte, bool) {
+	if client := c.client(); client != nil {
+		data, err := client.Get(ctx, key).Bytes()
+		if err == nil {
+			return data, true
+		}
+		if errors.Is(err, goRedis.Nil) {
+			return nil, false
+		}
+		log.Printf("Failed to read AI response cache from Redis, using memory: %v", err)
+	}
+	return c.memory.get(key)
+}
+
+// store writes a cached response to Redis, falling back to memory when Redis is unavailable
+func (c *cache) store(ctx context.Context, key string, data []byte, ttl time.Duration) {
+	if client := c.client(); client != nil {
+		err := client.Set(context.WithoutCancel(ctx), key, data, ttl).Err()
+		if err == nil {
+			return
+		}
+		log.Printf("Failed to write AI response cache to Redis, using memory: %v", err)
+	}
+
+	cfg, err := configs.GetConfig()
+	if err != nil {
+		return
+	}
+	c.memory.set(key, data, ttl, cfg.AI.Cache.MaxEntries)
+}
+
+// client returns the Redis client, nil when Redis is not initialized
+func (c *cache) client() *goRedis.Client {
+	if c.redisManager == nil {
+		return nil
+	}
+	return c.redisManager.Client()
+}
+
+// cacheable reports whether the request is deterministic and cached in this context, and for how long
+func cacheable(ctx context.Context, req *provider.ChatRequest) (time.Duration, bool) {
+	cfg, err := configs.GetConfig()
+	if err != nil || !cfg.AI.Cache.Enabled {
+		return 0, false
+	}
+
+	if bypass, _ := ctx.Value(bypassKey{}).(bool); bypass {
+		return 0, false
+	}
+	if req.Temperature == nil || *req.Temperature != 0 {
+		return 0, false
+	}
+
+	ttl := time.Duration(cfg.AI.Cache.TTL) * time.Second
+	if override, ok := ctx.Value(ttlKey{}).(time.Duration); ok && override > 0 {
+		ttl = override
+	}
+	return ttl, ttl > 0
+}
+
+// requestKey hashes the canonical JSON encoding of the request, which covers the model, messages and every
+// generation parameter; map keys of schemas are sorted by the encoder so equal requests share a key
+func requestKey(req *provider.ChatRequest) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return keyPrefix + hex.EncodeToString(sum[:]), nil
+}
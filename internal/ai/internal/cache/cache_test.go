@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/provider"
+)
+
+// nilRedisManager a Redis manager that was never initialized, so the cache falls back to memory
+type nilRedisManager struct{}
+
+func (nilRedisManager) Client() *redis.Client { return nil }
+func (nilRedisManager) Initialize() error     { return nil }
+func (nilRedisManager) Close() error          { return nil }
+
+func initTestConfig(t *testing.T, content string) {
+	t.Helper()
+	testDir := t.TempDir()
+	os.MkdirAll(filepath.Join(testDir, "configs"), 0755)
+	os.WriteFile(filepath.Join(testDir, "configs", "config.local.yml"), []byte(content), 0644)
+	t.Chdir(testDir)
+
+	if err := configs.New(); err != nil {
+		t.Fatalf("Failed to initialize config: %v", err)
+	}
+}
+
+func deterministicRequest(content string) *provider.ChatRequest {
+	temperature := float32(0)
+	return &provider.ChatRequest{
+		Model:       "mock-model",
+		Messages:    []provider.Message{{Role: provider.RoleUser, Content: content}},
+		Temperature: &temperature,
+	}
+}
+
+func TestCacheGetSet(t *testing.T) {
+	initTestConfig(t, `AI:
+  CACHE:
+    ENABLED: true
+    TTL: 60`)
+
+	c := New(nilRedisManager{})
+	ctx := context.Background()
+	req := deterministicRequest("Hi")
+	resp := &provider.ChatResponse{ID: "1", Model: "mock-model", Choices: []provider.Choice{{Message: provider.Message{Role: provider.RoleAssistant, Content: "Hello"}}}}
+
+	if _, ok := c.Get(ctx, req); ok {
+		t.Fatal("Get() hit on an empty cache")
+	}
+	c.Set(ctx, req, resp)
+
+	got, ok := c.Get(ctx, deterministicRequest("Hi"))
+	if !ok || got.Choices[0].Message.Content != "Hello" {
+		t.Fatalf("Get() = %+v, %v, want the cached response", got, ok)
+	}
+	if _, ok := c.Get(ctx, deterministicRequest("Hello")); ok {
+		t.Error("Get() hit for different messages")
+	}
+	if _, ok := c.Get(WithBypass(ctx), req); ok {
+		t.Error("Get() hit with the bypass set")
+	}
+
+	sampled := deterministicRequest("Hi")
+	sampled.Temperature = nil
+	c.Set(ctx, sampled, resp)
+	if _, ok := c.Get(ctx, sampled); ok {
+		t.Error("Get() hit for a request without temperature 0")
+	}
+
+	// Bypassed and non-deterministic lookups are not counted
+	if stats := c.Stats(); stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("Stats() = %+v, want 1 hit and 2 misses", stats)
+	}
+}
+
+func TestCacheStream(t *testing.T) {
+	initTestConfig(t, `AI:
+  CACHE:
+    ENABLED: true
+    TTL: 60`)
+
+	c := New(nilRedisManager{})
+	ctx := context.Background()
+	req := deterministicRequest("Weather?")
+
+	stream := make(chan *provider.ChatStreamResponse, 4)
+	stream <- &provider.ChatStreamResponse{ID: "1", Model: "mock-model", Choices: []provider.StreamChoice{{Delta: provider.MessageDelta{Role: provider.RoleAssistant, Content: "Let me "}}}}
+	stream <- &provider.ChatStreamResponse{ID: "1", Model: "mock-model", Choices: []provider.StreamChoice{{Delta: provider.MessageDelta{Content: "check", ToolCalls: []provider.ToolCall{{Index: 0, ID: "call_1", Type: "function", Function: provider.FunctionCall{Name: "get_weather", Arguments: `{"city":`}}}}}}}
+	stream <- &provider.ChatStreamResponse{ID: "1", Model: "mock-model", Choices: []provider.StreamChoice{{Delta: provider.MessageDelta{ToolCalls: []provider.ToolCall{{Index: 0, Function: provider.FunctionCall{Arguments: `"Paris"}`}}}}, FinishReason: "tool_calls"}}}
+	stream <- &provider.ChatStreamResponse{ID: "1", Model: "mock-model", Choices: []provider.StreamChoice{}, Usage: &provider.Usage{PromptTokens: 5, CompletionTokens: 3, TotalTokens: 8}}
+	close(stream)
+
+	for range c.SetStream(ctx, req, stream) {
+	}
+
+	resp, ok := c.Get(ctx, req)
+	if !ok {
+		t.Fatal("Get() missed after a completed stream")
+	}
+	msg := resp.Choices[0].Message
+	if msg.Content != "Let me check" || len(msg.ToolCalls) != 1 || msg.ToolCalls[0].Function.Arguments != `{"city":"Paris"}` || resp.Usage.TotalTokens != 8 {
+		t.Fatalf("assembled response = %+v", resp)
+	}
+
+	var chunks []*provider.ChatStreamResponse
+	for chunk := range Replay(resp) {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 2 || chunks[0].Choices[0].Delta.Content != "Let me check" || chunks[0].Choices[0].FinishReason != "tool_calls" || chunks[1].Usage.TotalTokens != 8 {
+		t.Errorf("Replay() chunks = %+v, want the message then the usage", chunks)
+	}
+}
@@ -0,0 +1,69 @@
+// Package cache provides response caching for deterministic AI calls
+// Author: Done-0
+// Created: 2025-09-25
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultMaxEntries capacity of the memory store when MAX_ENTRIES is not set
+const defaultMaxEntries = 1000
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// memoryStore in-process fallback used while Redis is unavailable
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{entries: make(map[string]memoryEntry)}
+}
+
+// get returns an unexpired entry
+func (s *memoryStore) get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(s.entries, key)
+		return nil, false
+	}
+	return entry.data, true
+}
+
+// set stores an entry, dropping expired entries and then arbitrary ones when the store is full
+func (s *memoryStore) set(key string, data []byte, ttl time.Duration, maxEntries int) {
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[key]; !ok && len(s.entries) >= maxEntries {
+		now := time.Now()
+		for k, entry := range s.entries {
+			if now.After(entry.expires) {
+				delete(s.entries, k)
+			}
+		}
+		for k := range s.entries {
+			if len(s.entries) < maxEntries {
+				break
+			}
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = memoryEntry{data: data, expires: time.Now().Add(ttl)}
+}
@@ -0,0 +1,49 @@
+// Package cache provides response caching for deterministic AI calls
+// Author: Done-0
+// Created: 2025-09-25
+package cache
+
+import (
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/provider"
+)
+
+// Replay turns a cached response into a synthetic stream: one chunk per choice carrying the whole message,
+// followed by a usage chunk, like a provider stream with usage reporting enabled
+func Replay(resp *provider.ChatResponse) <-chan *provider.ChatStreamResponse {
+	ch := make(chan *provider.ChatStreamResponse, len(resp.Choices)+1)
+	for _, choice := range resp.Choices {
+		ch <- &provider.ChatStreamResponse{
+			ID:      resp.ID,
+			Object:  "chat.completion.chunk",
+			Created: resp.Created,
+			Model:   resp.Model,
+			Choices: []provider.StreamChoice{{
+				Index: choice.Index,
+				Delta: provider.MessageDelta{
+					Role:             choice.Message.Role,
+					Content:          choice.Message.Content,
+					ReasoningContent: choice.Message.ReasoningContent,
+					ReasoningBlocks:  choice.Message.ReasoningBlocks,
+					ToolCalls:        choice.Message.ToolCalls,
+				},
+				FinishReason: choice.FinishReason,
+			}},
+			SystemFingerprint: resp.SystemFingerprint,
+			Provider:          resp.Provider,
+		}
+	}
+
+	usage := resp.Usage
+	ch <- &provider.ChatStreamResponse{
+		ID:                resp.ID,
+		Object:            "chat.completion.chunk",
+		Created:           resp.Created,
+		Model:             resp.Model,
+		Choices:           []provider.StreamChoice{},
+		SystemFingerprint: resp.SystemFingerprint,
+		Usage:             &usage,
+		Provider:          resp.Provider,
+	}
+	close(ch)
+	return ch
+}
@@ -0,0 +1,23 @@
+// Package cache provides response caching for deterministic AI calls
+// Author: Done-0
+// Created: 2025-09-25
+package cache
+
+import (
+	"context"
+
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/provider"
+)
+
+type Cache interface {
+	Get(ctx context.Context, req *provider.ChatRequest) (*provider.ChatResponse, bool)
+	Set(ctx context.Context, req *provider.ChatRequest, resp *provider.ChatResponse)
+	SetStream(ctx context.Context, req *provider.ChatRequest, stream <-chan *provider.ChatStreamResponse) <-chan *provider.ChatStreamResponse
+	Stats() Stats
+}
+
+// Stats counts lookups of cacheable requests since start
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
@@ -0,0 +1,374 @@
+// Package conversation provides multi-turn conversation memory for AI chats
+// Author: Done-0
+// Created: 2025-09-25
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	goRedis "github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/provider"
+	"github.com/Done-0/gin-scaffold/internal/db"
+	"github.com/Done-0/gin-scaffold/internal/redis"
+	"github.com/Done-0/gin-scaffold/internal/types/errno"
+	"github.com/Done-0/gin-scaffold/internal/utils/errorx"
+
+	conversationModel "github.com/Done-0/gin-scaffold/internal/model/conversation"
+)
+
+// keyPrefix prefix of the Redis history keys, followed by the conversation ID
+const keyPrefix = "ai:conversation:"
+
+// Defaults applied when the configuration leaves a value unset
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	defaultCacheTTL = time.Hour
+)
+
+type conversations struct {
+	dbManager    db.DatabaseManager
+	redisManager redis.RedisManager
+	provider     provider.Provider // Serves conversation turns and summaries
+}
+
+// New creates the conversation store, chatProvider serves the turns and the summaries of older turns
+func New(dbManager db.DatabaseManager, redisManager redis.RedisManager, chatProvider provider.Provider) Conversations {
+	return &conversations{dbManager: dbManager, redisManager: redisManager, provider: chatProvider}
+}
+
+// CreateConversation stores a new conversation
+func (c *conversations) CreateConversation(ctx context.Context, conv *conversationModel.Conversation) error {
+	database, err := c.db(ctx)
+	if err != nil {
+		return err
+	}
+	if err := database.Create(conv).Error; err != nil {
+		return fmt.Errorf("failed to create conversation: %w", err)
+	}
+	return nil
+}
+
+// GetConversation returns a conversation that has not been deleted
+func (c *conversations) GetConversation(ctx context.Context, id int64) (*conversationModel.Conversation, error) {
+	database, err := c.db(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var conv conversationModel.Conversation
+	if err := database.Where("id = ? AND deleted = ?", id, false).First(&conv).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, notFoundError(id)
+		}
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	return &conv, nil
+}
+
+// ListConversations returns a page of conversations, most recently updated first, and the total count
+func (c *conversations) ListConversations(ctx context.Context, query *ConversationQuery) ([]conversationModel.Conversation, int64, error) {
+	database, err := c.db(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	page, pageSize := 1, defaultPageSize
+	tx := database.Model(&conversationModel.Conversation{}).Where("deleted = ?", false)
+	if query != nil {
+		if query.Caller != "" {
+			tx = tx.Where("caller = ?", query.Caller)
+		}
+		if query.Page > 0 {
+			page = query.Page
+		}
+		if query.PageSize > 0 {
+			pageSize = min(query.PageSize, maxPageSize)
+		}
+	}
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count conversations: %w", err)
+	}
+
+	var convs []conversationModel.Conversation
+	if err := tx.Order("updated_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&convs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list conversations: %w", err)
+	}
+	return convs, total, nil
+}
+
+// DeleteConversation soft deletes a conversation and its messages
+func (c *conversations) DeleteConversation(ctx context.Context, id int64) error {
+	database, err := c.db(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = database.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&conversationModel.Conversation{}).
+			Where("id = ? AND deleted = ?", id, false).
+			Updates(map[string]any{"deleted": true, "updated_at": time.Now().Unix()})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete conversation: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return notFoundError(id)
+		}
+
+		if err := tx.Model(&conversationModel.Message{}).
+			Where("conversation_id = ? AND deleted = ?", id, false).
+			Updates(map[string]any{"deleted": true, "updated_at": time.Now().Unix()}).Error; err != nil {
+			return fmt.Errorf("failed to delete conversation messages: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.invalidate(ctx, id)
+	return nil
+}
+
+// ConversationMessages returns every stored message of a conversation in order
+func (c *conversations) ConversationMessages(ctx context.Context, id int64) ([]provider.Message, error) {
+	if _, err := c.GetConversation(ctx, id); err != nil {
+		return nil, err
+	}
+
+	records, err := c.records(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toMessages(records), nil
+}
+
+// ConversationChat sends the trimmed history followed by the request messages, then stores both and the reply
+func (c *conversations) ConversationChat(ctx context.Context, id int64, req *provider.ChatRequest) (*provider.ChatResponse, error) {
+	conv, request, err := c.prepare(ctx, id, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.provider.Chat(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Choices) > 0 {
+		c.appendTurn(ctx, conv.ID, req.Messages, resp.Choices[0].Message)
+	}
+	return resp, nil
+}
+
+// ConversationChatStream sends the trimmed history followed by the request messages, and stores both and the
+// assembled reply once the stream completes without error
+func (c *conversations) ConversationChatStream(ctx context.Context, id int64, req *provider.ChatRequest) (<-chan *provider.ChatStreamResponse, error) {
+	conv, request, err := c.prepare(ctx, id, req)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := c.provider.ChatStream(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *provider.ChatStreamResponse)
+	go func() {
+		defer close(ch)
+
+		var accumulator provider.StreamAccumulator
+		for resp := range stream {
+			accumulator.Add(resp)
+
+			select {
+			case ch <- resp:
+			case <-ctx.Done():
+				// Drain so the pool can finish the stream, an interrupted reply is not stored
+				for range stream {
+				}
+				return
+			}
+		}
+
+		if resp, ok := accumulator.Response(); ok {
+			c.appendTurn(ctx, conv.ID, req.Messages, resp.Choices[0].Message)
+		}
+	}()
+	return ch, nil
+}
+
+// prepare loads the conversation and builds the request carrying its trimmed history
+func (c *conversations) prepare(ctx context.Context, id int64, req *provider.ChatRequest) (*conversationModel.Conversation, *provider.ChatRequest, error) {
+	conv, err := c.GetConversation(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records, err := c.records(ctx, conv.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	history, err := c.history(ctx, conv, records)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	request := *req
+	request.Messages = append(history, req.Messages...)
+	return conv, &request, nil
+}
+
+// appendTurn stores the request messages and the reply, failures are logged since the reply was already produced
+func (c *conversations) appendTurn(ctx context.Context, id int64, messages []provider.Message, reply provider.Message) {
+	if reply.Role == "" {
+		reply.Role = provider.RoleAssistant
+	}
+	turn := append(append([]provider.Message{}, messages...), reply)
+	if err := c.append(context.WithoutCancel(ctx), id, turn); err != nil {
+		log.Printf("Failed to store turn of conversation %d: %v", id, err)
+	}
+}
+
+// append stores messages at the end of a conversation
+func (c *conversations) append(ctx context.Context, id int64, messages []provider.Message) error {
+	database, err := c.db(ctx)
+	if err != nil {
+		return err
+	}
+
+	records := make([]conversationModel.Message, len(messages))
+	for i, msg := range messages {
+		payload, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("failed to encode message: %w", err)
+		}
+		records[i] = conversationModel.Message{
+			ConversationID: id,
+			Role:           msg.Role,
+			Content:        msg.Content,
+			Payload:        string(payload),
+			Tokens:         provider.CountMessageTokens("", msg),
+		}
+	}
+
+	err = database.Transaction(func(tx *gorm.DB) error {
+		// One at a time so IDs, and therefore the history order, follow the message order
+		for i := range records {
+			if err := tx.Create(&records[i]).Error; err != nil {
+				return fmt.Errorf("failed to store message: %w", err)
+			}
+		}
+		return tx.Model(&conversationModel.Conversation{}).Where("id = ?", id).Updates(map[string]any{
+			"message_count": gorm.Expr("message_count + ?", len(records)),
+			"updated_at":    time.Now().Unix(),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	c.invalidate(ctx, id)
+	return nil
+}
+
+// records returns the stored messages of a conversation, from the Redis hot cache when present
+func (c *conversations) records(ctx context.Context, id int64) ([]conversationModel.Message, error) {
+	key := keyPrefix + strconv.FormatInt(id, 10)
+	client := c.client()
+	if client != nil {
+		data, err := client.Get(ctx, key).Bytes()
+		if err == nil {
+			var records []conversationModel.Message
+			if err := json.Unmarshal(data, &records); err == nil {
+				return records, nil
+			}
+		} else if !errors.Is(err, goRedis.Nil) {
+			log.Printf("Failed to read conversation %d from Redis, using database: %v", id, err)
+		}
+	}
+
+	database, err := c.db(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []conversationModel.Message
+	if err := database.Where("conversation_id = ? AND deleted = ?", id, false).Order("id").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to load conversation messages: %w", err)
+	}
+
+	if client != nil {
+		if data, err := json.Marshal(records); err == nil {
+			if err := client.Set(ctx, key, data, cacheTTL()).Err(); err != nil {
+				log.Printf("Failed to cache conversation %d in Redis: %v", id, err)
+			}
+		}
+	}
+	return records, nil
+}
+
+// invalidate drops the cached history of a conversation
+func (c *conversations) invalidate(ctx context.Context, id int64) {
+	client := c.client()
+	if client == nil {
+		return
+	}
+	if err := client.Del(context.WithoutCancel(ctx), keyPrefix+strconv.FormatInt(id, 10)).Err(); err != nil {
+		log.Printf("Failed to invalidate conversation %d in Redis: %v", id, err)
+	}
+}
+
+// db returns the database bound to ctx
+func (c *conversations) db(ctx context.Context) (*gorm.DB, error) {
+	if c.dbManager == nil || c.dbManager.DB() == nil {
+		return nil, fmt.Errorf("database is not initialized")
+	}
+	return c.dbManager.DB().WithContext(ctx), nil
+}
+
+// client returns the Redis client, nil when Redis is not initialized
+func (c *conversations) client() *goRedis.Client {
+	if c.redisManager == nil {
+		return nil
+	}
+	return c.redisManager.Client()
+}
+
+// cacheTTL returns how long histories stay in Redis
+func cacheTTL() time.Duration {
+	cfg, err := configs.GetConfig()
+	if err != nil || cfg.AI.Conversation.CacheTTL <= 0 {
+		return defaultCacheTTL
+	}
+	return time.Duration(cfg.AI.Conversation.CacheTTL) * time.Second
+}
+
+// toMessages decodes stored messages, falling back to role and content for payloads that fail to decode
+func toMessages(records []conversationModel.Message) []provider.Message {
+	messages := make([]provider.Message, len(records))
+	for i, record := range records {
+		if err := json.Unmarshal([]byte(record.Payload), &messages[i]); err != nil {
+			messages[i] = provider.Message{Role: record.Role, Content: record.Content}
+		}
+	}
+	return messages
+}
+
+// notFoundError reports a conversation that does not exist or was deleted
+func notFoundError(id int64) error {
+	return errorx.New(errno.ErrResourceNotFound, errorx.KV("resource", "conversation"), errorx.KV("id", strconv.FormatInt(id, 10)))
+}
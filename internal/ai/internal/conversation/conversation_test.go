@@ -0,0 +1,175 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/provider"
+	"github.com/Done-0/gin-scaffold/internal/db/dbtest"
+	"github.com/Done-0/gin-scaffold/internal/types/errno"
+	"github.com/Done-0/gin-scaffold/internal/utils/errorx"
+
+	conversationModel "github.com/Done-0/gin-scaffold/internal/model/conversation"
+)
+
+// echoProvider replies with the number of messages it received, and with a fixed text to summary requests
+type echoProvider struct {
+	requests []*provider.ChatRequest
+}
+
+func (p *echoProvider) Chat(ctx context.Context, req *provider.ChatRequest) (*provider.ChatResponse, error) {
+	p.requests = append(p.requests, req)
+	content := "reply"
+	if req.Messages[0].Content == summaryInstruction {
+		content = "the user likes tea"
+	}
+	return &provider.ChatResponse{Choices: []provider.Choice{{Message: provider.Message{Role: provider.RoleAssistant, Content: content}}}}, nil
+}
+
+func (p *echoProvider) ChatStream(ctx context.Context, req *provider.ChatRequest) (<-chan *provider.ChatStreamResponse, error) {
+	p.requests = append(p.requests, req)
+	ch := make(chan *provider.ChatStreamResponse, 2)
+	ch <- &provider.ChatStreamResponse{Choices: []provider.StreamChoice{{Delta: provider.MessageDelta{Role: provider.RoleAssistant, Content: "streamed "}}}}
+	ch <- &provider.ChatStreamResponse{Choices: []provider.StreamChoice{{Delta: provider.MessageDelta{Content: "reply"}, FinishReason: "stop"}}}
+	close(ch)
+	return ch, nil
+}
+
+func initTestConfig(t *testing.T, content string) {
+	t.Helper()
+	testDir := t.TempDir()
+	os.MkdirAll(filepath.Join(testDir, "configs"), 0755)
+	os.WriteFile(filepath.Join(testDir, "configs", "config.local.yml"), []byte(content), 0644)
+	t.Chdir(testDir)
+
+	if err := configs.New(); err != nil {
+		t.Fatalf("Failed to initialize config: %v", err)
+	}
+}
+
+func userMessage(content string) []provider.Message {
+	return []provider.Message{{Role: provider.RoleUser, Content: content}}
+}
+
+func TestConversationChat(t *testing.T) {
+	initTestConfig(t, `AI:
+  CONVERSATION:
+    STRATEGY: "last_n"
+    MAX_MESSAGES: 3`)
+
+	chat := &echoProvider{}
+	c := New(dbtest.New(t, &conversationModel.Conversation{}, &conversationModel.Message{}), nil, chat)
+	ctx := context.Background()
+
+	conv := &conversationModel.Conversation{Caller: "tenant-a", Title: "Tea", SystemPrompt: "Be brief."}
+	if err := c.CreateConversation(ctx, conv); err != nil {
+		t.Fatalf("CreateConversation() failed: %v", err)
+	}
+
+	if _, err := c.ConversationChat(ctx, conv.ID, &provider.ChatRequest{Messages: userMessage("one")}); err != nil {
+		t.Fatalf("ConversationChat() failed: %v", err)
+	}
+	stream, err := c.ConversationChatStream(ctx, conv.ID, &provider.ChatRequest{Messages: userMessage("two")})
+	if err != nil {
+		t.Fatalf("ConversationChatStream() failed: %v", err)
+	}
+	for range stream {
+	}
+	if _, err := c.ConversationChat(ctx, conv.ID, &provider.ChatRequest{Messages: userMessage("three")}); err != nil {
+		t.Fatalf("ConversationChat() failed: %v", err)
+	}
+
+	messages, err := c.ConversationMessages(ctx, conv.ID)
+	if err != nil {
+		t.Fatalf("ConversationMessages() failed: %v", err)
+	}
+	if len(messages) != 6 || messages[3].Content != "streamed reply" || messages[4].Content != "three" {
+		t.Fatalf("stored messages = %+v, want three turns including the streamed reply", messages)
+	}
+
+	// System prompt, the last 3 stored messages of two turns, then the new message
+	last := chat.requests[2].Messages
+	if len(last) != 5 || last[0].Content != "Be brief." || last[1].Role != provider.RoleAssistant || last[4].Content != "three" {
+		t.Errorf("last request messages = %+v", last)
+	}
+
+	stored, err := c.GetConversation(ctx, conv.ID)
+	if err != nil || stored.MessageCount != 6 {
+		t.Errorf("GetConversation() = %+v, %v, want 6 messages", stored, err)
+	}
+}
+
+func TestConversationSummarize(t *testing.T) {
+	initTestConfig(t, `AI:
+  CONVERSATION:
+    STRATEGY: "summarize"
+    MAX_TOKENS: 20`)
+
+	chat := &echoProvider{}
+	c := New(dbtest.New(t, &conversationModel.Conversation{}, &conversationModel.Message{}), nil, chat)
+	ctx := context.Background()
+
+	conv := &conversationModel.Conversation{}
+	if err := c.CreateConversation(ctx, conv); err != nil {
+		t.Fatalf("CreateConversation() failed: %v", err)
+	}
+	for _, content := range []string{"I like tea, not coffee", "Green tea mostly", "Any suggestions?"} {
+		if _, err := c.ConversationChat(ctx, conv.ID, &provider.ChatRequest{Messages: userMessage(content)}); err != nil {
+			t.Fatalf("ConversationChat() failed: %v", err)
+		}
+	}
+
+	stored, err := c.GetConversation(ctx, conv.ID)
+	if err != nil {
+		t.Fatalf("GetConversation() failed: %v", err)
+	}
+	if stored.Summary != "the user likes tea" || stored.SummarizedUntil == 0 {
+		t.Fatalf("conversation = %+v, want older turns folded into the summary", stored)
+	}
+
+	last := chat.requests[len(chat.requests)-1].Messages
+	if last[0].Role != provider.RoleSystem || !strings.Contains(last[0].Content, "the user likes tea") {
+		t.Errorf("last request = %+v, want the summary first", last)
+	}
+}
+
+func TestConversationListDelete(t *testing.T) {
+	initTestConfig(t, `AI:
+  CONVERSATION:
+    STRATEGY: "token_budget"`)
+
+	c := New(dbtest.New(t, &conversationModel.Conversation{}, &conversationModel.Message{}), nil, &echoProvider{})
+	ctx := context.Background()
+
+	for _, caller := range []string{"a", "a", "b"} {
+		if err := c.CreateConversation(ctx, &conversationModel.Conversation{Caller: caller}); err != nil {
+			t.Fatalf("CreateConversation() failed: %v", err)
+		}
+	}
+
+	convs, total, err := c.ListConversations(ctx, &ConversationQuery{Caller: "a", PageSize: 1})
+	if err != nil || total != 2 || len(convs) != 1 {
+		t.Fatalf("ListConversations() = %d of %d, %v, want 1 of 2", len(convs), total, err)
+	}
+
+	if err := c.DeleteConversation(ctx, convs[0].ID); err != nil {
+		t.Fatalf("DeleteConversation() failed: %v", err)
+	}
+	if _, total, _ := c.ListConversations(ctx, &ConversationQuery{Caller: "a"}); total != 1 {
+		t.Errorf("total after delete = %d, want 1", total)
+	}
+
+	var statusErr errorx.StatusError
+	err = c.DeleteConversation(ctx, convs[0].ID)
+	if !errors.As(err, &statusErr) || statusErr.Code() != errno.ErrResourceNotFound {
+		t.Errorf("second DeleteConversation() = %v, want not found", err)
+	}
+	if _, err := c.ConversationChat(ctx, convs[0].ID, &provider.ChatRequest{Messages: userMessage("hi")}); err == nil {
+		t.Error("ConversationChat() on a deleted conversation succeeded")
+	}
+}
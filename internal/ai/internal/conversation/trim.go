@@ -0,0 +1,180 @@
+// Package conversation provides multi-turn conversation memory for AI chats
+// Author: Done-0
+// Created: 2025-09-25
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/provider"
+
+	conversationModel "github.com/Done-0/gin-scaffold/internal/model/conversation"
+)
+
+// Defaults of the trimming strategies
+const (
+	defaultMaxMessages = 20
+	defaultMaxTokens   = 4000
+)
+
+// summaryInstruction asks the model to fold older turns into the running summary
+const summaryInstruction = "Summarize the conversation below so the summary can replace it as context for the rest of the conversation. " +
+	"Keep facts, decisions, user preferences and open questions. Reply with the summary only."
+
+// history returns the messages sent before the request: the system prompt, the summary of folded turns and
+// the stored messages kept by the configured strategy
+func (c *conversations) history(ctx context.Context, conv *conversationModel.Conversation, records []conversationModel.Message) ([]provider.Message, error) {
+	cfg, err := configs.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config: %w", err)
+	}
+	settings := cfg.AI.Conversation
+	if settings.MaxMessages <= 0 {
+		settings.MaxMessages = defaultMaxMessages
+	}
+	if settings.MaxTokens <= 0 {
+		settings.MaxTokens = defaultMaxTokens
+	}
+
+	var kept []conversationModel.Message
+	switch settings.Strategy {
+	case "", StrategyLastN:
+		kept = lastN(records, settings.MaxMessages)
+	case StrategyTokenBudget:
+		kept = withinBudget(records, settings.MaxTokens)
+	case StrategySummarize:
+		kept = c.summarize(ctx, conv, records, &settings)
+	default:
+		return nil, fmt.Errorf("unknown conversation strategy: %s", settings.Strategy)
+	}
+
+	var messages []provider.Message
+	if conv.SystemPrompt != "" {
+		messages = append(messages, provider.Message{Role: provider.RoleSystem, Content: conv.SystemPrompt})
+	}
+	if settings.Strategy == StrategySummarize && conv.Summary != "" {
+		messages = append(messages, provider.Message{Role: provider.RoleSystem, Content: "Summary of the earlier conversation:\n" + conv.Summary})
+	}
+	return append(messages, toMessages(kept)...), nil
+}
+
+// summarize keeps the turns after the summary while they fit MAX_TOKENS; beyond that the older half of the budget
+// is folded into the summary by the model. When summarizing fails the history is trimmed to the budget instead.
+func (c *conversations) summarize(ctx context.Context, conv *conversationModel.Conversation, records []conversationModel.Message, settings *configs.ConversationConfig) []conversationModel.Message {
+	pending := records
+	for i, record := range records {
+		if record.ID > conv.SummarizedUntil {
+			pending = records[i:]
+			break
+		}
+		pending = nil
+	}
+
+	if totalTokens(pending) <= settings.MaxTokens {
+		return pending
+	}
+
+	kept := withinBudget(pending, settings.MaxTokens/2)
+	folded := pending[:len(pending)-len(kept)]
+	if len(folded) == 0 {
+		return kept
+	}
+
+	summary, err := c.summary(ctx, conv.Summary, toMessages(folded), settings.SummaryModel)
+	if err != nil {
+		log.Printf("Failed to summarize conversation %d, trimming to the token budget: %v", conv.ID, err)
+		return withinBudget(pending, settings.MaxTokens)
+	}
+
+	until := folded[len(folded)-1].ID
+	database, err := c.db(ctx)
+	if err == nil {
+		err = database.Model(&conversationModel.Conversation{}).Where("id = ?", conv.ID).
+			Updates(map[string]any{"summary": summary, "summarized_until": until}).Error
+	}
+	if err != nil {
+		// The summary still serves this request, the next one summarizes again
+		log.Printf("Failed to store summary of conversation %d: %v", conv.ID, err)
+	}
+
+	conv.Summary, conv.SummarizedUntil = summary, until
+	return kept
+}
+
+// summary asks the model for a summary of the previous summary followed by the folded turns
+func (c *conversations) summary(ctx context.Context, previous string, folded []provider.Message, model string) (string, error) {
+	var transcript strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&transcript, "Summary so far:\n%s\n\n", previous)
+	}
+	transcript.WriteString("Conversation:\n")
+	for _, msg := range folded {
+		content := msg.Content
+		for _, part := range msg.Parts {
+			if part.Type == provider.PartTypeText {
+				content += part.Text
+			}
+		}
+		for _, call := range msg.ToolCalls {
+			content += fmt.Sprintf(" [called %s(%s)]", call.Function.Name, call.Function.Arguments)
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n", msg.Role, strings.TrimSpace(content))
+	}
+
+	temperature := float32(0)
+	resp, err := c.provider.Chat(ctx, &provider.ChatRequest{
+		Model: model,
+		Messages: []provider.Message{
+			{Role: provider.RoleSystem, Content: summaryInstruction},
+			{Role: provider.RoleUser, Content: transcript.String()},
+		},
+		Temperature: &temperature,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("summary response is empty")
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+// lastN keeps the last n messages
+func lastN(records []conversationModel.Message, n int) []conversationModel.Message {
+	if len(records) > n {
+		records = records[len(records)-n:]
+	}
+	return dropOrphans(records)
+}
+
+// withinBudget keeps the most recent messages whose estimated tokens fit the budget
+func withinBudget(records []conversationModel.Message, budget int) []conversationModel.Message {
+	start := len(records)
+	used := 0
+	for start > 0 && used+records[start-1].Tokens <= budget {
+		start--
+		used += records[start].Tokens
+	}
+	return dropOrphans(records[start:])
+}
+
+// dropOrphans drops leading tool results whose assistant tool call was trimmed, providers reject them
+func dropOrphans(records []conversationModel.Message) []conversationModel.Message {
+	for len(records) > 0 && records[0].Role == provider.RoleTool {
+		records = records[1:]
+	}
+	return records
+}
+
+// totalTokens sums the estimated tokens of messages
+func totalTokens(records []conversationModel.Message) int {
+	total := 0
+	for _, record := range records {
+		total += record.Tokens
+	}
+	return total
+}
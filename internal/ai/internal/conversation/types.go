@@ -0,0 +1,36 @@
+// Package conversation provides multi-turn conversation memory for AI chats
+// Author: Done-0
+// Created: 2025-09-25
+package conversation
+
+import (
+	"context"
+
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/provider"
+
+	conversationModel "github.com/Done-0/gin-scaffold/internal/model/conversation"
+)
+
+type Conversations interface {
+	CreateConversation(ctx context.Context, conv *conversationModel.Conversation) error
+	GetConversation(ctx context.Context, id int64) (*conversationModel.Conversation, error)
+	ListConversations(ctx context.Context, query *ConversationQuery) ([]conversationModel.Conversation, int64, error)
+	DeleteConversation(ctx context.Context, id int64) error
+	ConversationMessages(ctx context.Context, id int64) ([]provider.Message, error)
+	ConversationChat(ctx context.Context, id int64, req *provider.ChatRequest) (*provider.ChatResponse, error)
+	ConversationChatStream(ctx context.Context, id int64, req *provider.ChatRequest) (<-chan *provider.ChatStreamResponse, error)
+}
+
+// ConversationQuery filters and pages conversations, zero values match everything
+type ConversationQuery struct {
+	Caller   string `json:"caller,omitempty"` // Only conversations of this caller
+	Page     int    `json:"page"`             // 1-based page, 0=first page
+	PageSize int    `json:"page_size"`        // Conversations per page, 0=default
+}
+
+// History trimming strategies
+const (
+	StrategyLastN       = "last_n"       // Keep the last MAX_MESSAGES messages
+	StrategyTokenBudget = "token_budget" // Keep the most recent messages within MAX_TOKENS
+	StrategySummarize   = "summarize"    // Fold turns beyond MAX_TOKENS into a summary written by the model
+)
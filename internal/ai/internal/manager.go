@@ -4,20 +4,144 @@
 package internal
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/cache"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/conversation"
 	"github.com/Done-0/gin-scaffold/internal/ai/internal/prompter"
 	"github.com/Done-0/gin-scaffold/internal/ai/internal/provider"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/quota"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/recorder"
+	"github.com/Done-0/gin-scaffold/internal/db"
+	"github.com/Done-0/gin-scaffold/internal/redis"
 )
 
 type Manager struct {
-	provider.Provider
+	provider.Pool
 	prompter.Prompter
+	recorder.Recorder
+	conversation.Conversations
+
+	quota quota.Quota
+	cache cache.Cache
+}
+
+// New creates a new AI provider manager with dynamic prompt loading, usage accounting, token quotas, response caching
+// and conversation memory
+func New(config *configs.Config, dbManager db.DatabaseManager, redisManager redis.RedisManager) (*Manager, error) {
+	var prompts prompter.Prompter
+	switch store := config.AI.Prompt.Store; store {
+	case "", prompter.StoreFile:
+		prompts = prompter.New()
+	case prompter.StoreDatabase:
+		prompts = prompter.NewDatabase(dbManager, redisManager)
+	default:
+		return nil, fmt.Errorf("unknown prompt store '%s', want %s or %s", store, prompter.StoreFile, prompter.StoreDatabase)
+	}
+
+	usageRecorder := recorder.New(dbManager)
+	manager := &Manager{
+		Pool:     provider.New(usageRecorder.Record),
+		Prompter: prompts,
+		Recorder: usageRecorder,
+		quota:    quota.New(redisManager),
+		cache:    cache.New(redisManager),
+	}
+	// Conversation turns go through the manager so they are cached and budgeted like any other call
+	manager.Conversations = conversation.New(dbManager, redisManager, manager)
+	return manager, nil
+}
+
+// CacheStats returns the response cache hit and miss counters
+func (m *Manager) CacheStats() cache.Stats {
+	return m.cache.Stats()
+}
+
+// Chat serves deterministic requests from the cache, otherwise reserves the estimated prompt tokens against
+// the caller's quota, then settles the actual usage
+func (m *Manager) Chat(ctx context.Context, req *provider.ChatRequest) (*provider.ChatResponse, error) {
+	if resp, ok := m.cache.Get(ctx, req); ok {
+		return resp, nil
+	}
+
+	reservation, err := m.quota.Reserve(ctx, quota.EstimateChat(req))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := m.Pool.Chat(ctx, req)
+	used := 0
+	if resp != nil {
+		used = resp.Usage.TotalTokens
+	}
+	reservation.Settle(ctx, used)
+	if err == nil {
+		m.cache.Set(ctx, req, resp)
+	}
+	return resp, err
+}
+
+// ChatStream replays cached responses of deterministic requests, otherwise reserves the estimated prompt tokens
+// against the caller's quota, then settles the final stream usage
+func (m *Manager) ChatStream(ctx context.Context, req *provider.ChatRequest) (<-chan *provider.ChatStreamResponse, error) {
+	if resp, ok := m.cache.Get(ctx, req); ok {
+		return cache.Replay(resp), nil
+	}
+
+	reservation, err := m.quota.Reserve(ctx, quota.EstimateChat(req))
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := m.Pool.ChatStream(ctx, req)
+	if err != nil {
+		reservation.Settle(ctx, 0)
+		return nil, err
+	}
+	stream = m.cache.SetStream(ctx, req, stream)
+	if reservation == nil {
+		return stream, nil
+	}
+
+	ch := make(chan *provider.ChatStreamResponse)
+	go func() {
+		defer close(ch)
+
+		used := 0
+		defer func() { reservation.Settle(ctx, used) }()
+
+		for resp := range stream {
+			if resp.Usage != nil {
+				used = resp.Usage.TotalTokens
+			}
+
+			select {
+			case ch <- resp:
+			case <-ctx.Done():
+				// Drain so the pool can finish the stream
+				for range stream {
+				}
+				return
+			}
+		}
+	}()
+	return ch, nil
 }
 
-// New creates a new AI provider manager with dynamic prompt loading
-func New(config *configs.Config) (*Manager, error) {
-	return &Manager{
-		Provider: provider.New(),
-		Prompter: prompter.New(),
-	}, nil
+// Embed reserves the estimated input tokens against the caller's quota, then settles the actual usage
+func (m *Manager) Embed(ctx context.Context, req *provider.EmbeddingRequest) (*provider.EmbeddingResponse, error) {
+	reservation, err := m.quota.Reserve(ctx, quota.EstimateEmbedding(req))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := m.Pool.Embed(ctx, req)
+	used := 0
+	if resp != nil {
+		used = resp.Usage.TotalTokens
+	}
+	reservation.Settle(ctx, used)
+	return resp, err
 }
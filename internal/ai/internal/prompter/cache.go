@@ -0,0 +1,217 @@
+// Package prompter provides dynamic prompt loading and management
+// Author: Done-0
+// Created: 2025-08-31
+package prompter
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	textTemplate "text/template"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/Done-0/gin-scaffold/internal/utils/template"
+)
+
+// compiledTemplate template with its base templates and partials resolved and its messages parsed once, reused by
+// every render
+type compiledTemplate struct {
+	tmpl     *Template                // Stored form, returned when no vars are passed
+	resolved *Template                // tmpl with the messages of its base templates and the variables of its partials
+	messages []*textTemplate.Template // Parsed messages of resolved with their partials defined, nil for empty ones
+	deps     []string                 // Base templates and partials, a change to any of them makes the entry stale
+	err      error                    // Resolve or parse error, returned by renders with vars
+}
+
+// newCompiledTemplate resolves and parses tmpl, loading its base templates and partials with load. Errors are kept
+// for renders, so the stored form of a broken template can still be read and fixed.
+func newCompiledTemplate(path string, tmpl *Template, load loadFunc) *compiledTemplate {
+	r := &resolver{path: path, load: load}
+	c := &compiledTemplate{tmpl: tmpl}
+	c.resolved, c.messages, c.err = r.compile(tmpl)
+	c.deps = r.deps
+	return c
+}
+
+// render returns the resolved template with vars replaced in its messages, or a copy of the stored template when
+// vars is nil. vars are validated against the variable schema first, and referencing a variable that was not passed
+// fails.
+func (c *compiledTemplate) render(path string, vars *map[string]any) (*Template, error) {
+	if vars == nil {
+		result := *c.tmpl
+		result.Messages = slices.Clone(c.tmpl.Messages)
+		return &result, nil
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	values, err := prepareVars(path, c.resolved, *vars)
+	if err != nil {
+		return nil, err
+	}
+
+	result := *c.resolved
+	result.Messages = make([]Message, len(c.resolved.Messages))
+	for i, msg := range c.resolved.Messages {
+		content, err := template.Execute(c.messages[i], values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to replace variables in message %d: %w", i, err)
+		}
+		result.Messages[i] = Message{Role: msg.Role, Content: content}
+	}
+	return &result, nil
+}
+
+// stale reports whether the entry cached under key is affected by a change of path, of every template when path is
+// empty. Changes of a directory affect every template below it.
+func (c *compiledTemplate) stale(key, path string) bool {
+	if path == "" {
+		return true
+	}
+	for _, ref := range append([]string{key}, c.deps...) {
+		if ref == path || strings.HasPrefix(ref, path+"@") || strings.HasPrefix(ref, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// templateCache compiled templates of the prompts directory, kept only while an fsnotify watcher reports changes
+// to the directory so edits made outside the prompter apply without a restart
+type templateCache struct {
+	mu         sync.Mutex
+	dir        string            // Watched directory, the cache starts over when AI.PROMPT.DIR changes
+	watcher    *fsnotify.Watcher // nil when the directory cannot be watched, every lookup then reads the file
+	entries    map[string]*compiledTemplate
+	generation uint64 // Bumped by every invalidation so loads racing it are not cached
+}
+
+func newTemplateCache() *templateCache {
+	return &templateCache{entries: make(map[string]*compiledTemplate)}
+}
+
+// get returns the cached template under key, loading and caching it on a miss
+func (c *templateCache) get(dir, key string, load func() (*compiledTemplate, error)) (*compiledTemplate, error) {
+	c.mu.Lock()
+	if dir != c.dir {
+		c.watch(dir)
+	}
+	compiled, cached := c.entries[key]
+	generation := c.generation
+	c.mu.Unlock()
+	if cached {
+		return compiled, nil
+	}
+
+	compiled, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.watcher != nil && c.dir == dir && c.generation == generation {
+		c.entries[key] = compiled
+	}
+	c.mu.Unlock()
+	return compiled, nil
+}
+
+// watch replaces the watcher with one on dir and every directory below it. Callers must hold c.mu.
+func (c *templateCache) watch(dir string) {
+	if c.watcher != nil {
+		c.watcher.Close()
+		c.watcher = nil
+	}
+	c.dir = dir
+	c.entries = make(map[string]*compiledTemplate)
+	c.generation++
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("Failed to watch prompt templates, caching disabled: %v", err)
+		return
+	}
+	if err := addWatches(watcher, dir); err != nil {
+		watcher.Close()
+		log.Printf("Failed to watch prompt templates in %s, caching disabled: %v", dir, err)
+		return
+	}
+	c.watcher = watcher
+	go c.run(watcher, dir)
+}
+
+// addWatches watches root and every directory below it, fsnotify does not watch recursively
+func addWatches(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return err
+		}
+		return watcher.Add(path)
+	})
+}
+
+// run drops the cached templates touched by every event until the watcher is closed
+func (c *templateCache) run(watcher *fsnotify.Watcher, dir string) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Create) {
+				// Directories created later, templates written into them before the watch is added are missed
+				// by the watcher but were never cached either
+				if err := addWatches(watcher, event.Name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					log.Printf("Failed to watch prompt templates in %s: %v", event.Name, err)
+				}
+			}
+			c.invalidate(templatePath(dir, event.Name))
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			// Events may have been dropped
+			log.Printf("Prompt template watcher error: %v", err)
+			c.invalidate("")
+		}
+	}
+}
+
+// invalidate drops the cached versions of a template, of every template below it and of every template extending
+// or including any of those, all of them when path is empty
+func (c *templateCache) invalidate(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for key, compiled := range c.entries {
+		if compiled.stale(key, path) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// templatePath maps a changed file or directory of the prompts directory to the template path it affects, the
+// template itself for a template file or one of its version files, and the directory path otherwise
+func templatePath(dir, name string) string {
+	rel, err := filepath.Rel(dir, name)
+	if err != nil || rel == "." || rel == versionsDir {
+		return ""
+	}
+	rel = filepath.ToSlash(rel)
+
+	if versioned, found := strings.CutPrefix(rel, versionsDir+"/"); found {
+		if strings.HasSuffix(versioned, ".json") {
+			return filepath.ToSlash(filepath.Dir(versioned))
+		}
+		return versioned
+	}
+	return strings.TrimSuffix(rel, ".json")
+}
@@ -0,0 +1,173 @@
+// Package prompter provides dynamic prompt loading and management
+// Author: Done-0
+// Created: 2025-08-31
+package prompter
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	textTemplate "text/template"
+	"text/template/parse"
+
+	"github.com/Done-0/gin-scaffold/internal/utils/template"
+)
+
+// partialSeparator joins the messages of a partial into the text it inserts
+const partialSeparator = "\n\n"
+
+// loadFunc loads the stored form of a template by path or path@version
+type loadFunc func(ref string) (*Template, error)
+
+// resolver resolves the base templates and partials of one template through the prompter path namespace
+type resolver struct {
+	path string
+	load loadFunc
+	deps []string // Base templates and partials referenced, including those that failed to load
+}
+
+// compile merges the base templates of tmpl and parses its messages with every partial they include defined
+func (r *resolver) compile(tmpl *Template) (*Template, []*textTemplate.Template, error) {
+	resolved, err := r.extend(tmpl, []string{r.path})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	messages := make([]*textTemplate.Template, len(resolved.Messages))
+	for i, msg := range resolved.Messages {
+		root, err := template.Parse(msg.Content)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse message %d of template '%s': %w", i, r.path, err)
+		}
+		if root == nil {
+			continue
+		}
+		// Blocks declared with {{define}} may include partials as well
+		for _, t := range root.Templates() {
+			if err := r.include(root, resolved, t, nil); err != nil {
+				return nil, nil, err
+			}
+		}
+		messages[i] = root
+	}
+	return resolved, messages, nil
+}
+
+// extend returns a copy of tmpl with the messages of its base templates, outermost first, before its own, and the
+// variables of its base templates merged under its own. chain holds the templates being extended to detect cycles.
+func (r *resolver) extend(tmpl *Template, chain []string) (*Template, error) {
+	result := *tmpl
+	result.Extends = ""
+	result.Messages = slices.Clone(tmpl.Messages)
+	result.Variables = maps.Clone(tmpl.Variables)
+	if tmpl.Extends == "" {
+		return &result, nil
+	}
+
+	basePath, _, err := splitVersion(tmpl.Extends)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(chain, basePath) {
+		return nil, fmt.Errorf("template '%s' has an inheritance cycle: %s", r.path, strings.Join(append(chain, basePath), " -> "))
+	}
+
+	r.deps = append(r.deps, tmpl.Extends)
+	base, err := r.load(tmpl.Extends)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load base template '%s' of '%s': %w", tmpl.Extends, chain[len(chain)-1], err)
+	}
+	if base, err = r.extend(base, slices.Concat(chain, []string{basePath})); err != nil {
+		return nil, err
+	}
+
+	result.Messages = slices.Concat(base.Messages, tmpl.Messages)
+	result.Variables = mergeVariables(result.Variables, base.Variables)
+	if result.CacheTTL == 0 {
+		result.CacheTTL = base.CacheTTL
+	}
+	return &result, nil
+}
+
+// include defines every partial referenced by t in the template set of root, then the partials those reference,
+// and merges their variables into resolved. stack holds the partials being defined to detect cycles.
+func (r *resolver) include(root *textTemplate.Template, resolved *Template, t *textTemplate.Template, stack []string) error {
+	if t.Tree == nil {
+		return nil
+	}
+
+	for _, name := range partialNames(t.Tree.Root, nil) {
+		if slices.Contains(stack, name) {
+			return fmt.Errorf("template '%s' has a partial cycle: %s", r.path, strings.Join(append(stack, name), " -> "))
+		}
+		if root.Lookup(name) != nil {
+			continue
+		}
+
+		r.deps = append(r.deps, name)
+		partial, err := r.load(name)
+		if err != nil {
+			return fmt.Errorf("failed to load partial '%s' included by template '%s': %w", name, r.path, err)
+		}
+		partialPath, _, _ := splitVersion(name)
+		if partial, err = r.extend(partial, []string{partialPath}); err != nil {
+			return err
+		}
+
+		contents := make([]string, len(partial.Messages))
+		for i, msg := range partial.Messages {
+			contents[i] = msg.Content
+		}
+		defined, err := root.New(name).Parse(strings.Join(contents, partialSeparator))
+		if err != nil {
+			return fmt.Errorf("failed to parse partial '%s' included by template '%s': %w", name, r.path, err)
+		}
+		resolved.Variables = mergeVariables(resolved.Variables, partial.Variables)
+
+		if err := r.include(root, resolved, defined, slices.Concat(stack, []string{name})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// partialNames appends the names of the templates invoked with {{template}} below node to names
+func partialNames(node parse.Node, names []string) []string {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return names
+		}
+		for _, child := range n.Nodes {
+			names = partialNames(child, names)
+		}
+	case *parse.TemplateNode:
+		if !slices.Contains(names, n.Name) {
+			names = append(names, n.Name)
+		}
+	case *parse.IfNode:
+		names = partialNames(n.ElseList, partialNames(n.List, names))
+	case *parse.RangeNode:
+		names = partialNames(n.ElseList, partialNames(n.List, names))
+	case *parse.WithNode:
+		names = partialNames(n.ElseList, partialNames(n.List, names))
+	}
+	return names
+}
+
+// mergeVariables adds the variables of from missing in into, the schema of into wins
+func mergeVariables(into, from map[string]Variable) map[string]Variable {
+	if len(from) == 0 {
+		return into
+	}
+	if into == nil {
+		into = make(map[string]Variable, len(from))
+	}
+	for name, v := range from {
+		if _, ok := into[name]; !ok {
+			into[name] = v
+		}
+	}
+	return into
+}
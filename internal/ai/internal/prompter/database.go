@@ -0,0 +1,465 @@
+// Package prompter provides dynamic prompt loading and management
+// Author: Done-0
+// Created: 2025-08-31
+package prompter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	goRedis "github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/db"
+	"github.com/Done-0/gin-scaffold/internal/redis"
+	"github.com/Done-0/gin-scaffold/internal/utils/file"
+	"github.com/Done-0/gin-scaffold/internal/utils/snowflake"
+
+	promptModel "github.com/Done-0/gin-scaffold/internal/model/prompt"
+)
+
+// Template stores, selected by AI.PROMPT.STORE
+const (
+	StoreFile     = "file"     // JSON files under AI.PROMPT.DIR
+	StoreDatabase = "database" // ai_prompt_versions table, shared by all replicas
+)
+
+// invalidateChannel Redis channel announcing the paths of changed templates to every replica
+const invalidateChannel = "ai:prompt:invalidate"
+
+// likeEscape escape character of LIKE patterns, a backslash would need escaping differently in MySQL string literals
+const likeEscape = "!"
+
+type dbPrompter struct {
+	dbManager    db.DatabaseManager
+	redisManager redis.RedisManager
+	files        Prompter // Templates of AI.PROMPT.DIR, the seed source
+
+	seedOnce   sync.Once
+	mu         sync.Mutex
+	listening  bool                         // Subscribed to invalidateChannel, templates are only cached while listening
+	cache      map[string]*compiledTemplate // Templates by path or path@version
+	generation uint64                       // Bumped by every invalidation so loads racing it are not cached
+}
+
+// NewDatabase creates a prompter storing every template version in the database. Replicas cache templates and drop
+// them when another replica announces a change over Redis, without Redis every lookup reads the database.
+func NewDatabase(dbManager db.DatabaseManager, redisManager redis.RedisManager) Prompter {
+	return &dbPrompter{
+		dbManager:    dbManager,
+		redisManager: redisManager,
+		files:        New(),
+		cache:        make(map[string]*compiledTemplate),
+	}
+}
+
+func (p *dbPrompter) GetTemplate(ctx context.Context, path string, vars *map[string]any) (*Template, error) {
+	database, err := p.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	path, version, err := splitVersion(path)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := p.cachedTemplate(database, path, version)
+	if err != nil {
+		return nil, err
+	}
+	return compiled.render(path, vars)
+}
+
+func (p *dbPrompter) ListTemplates(ctx context.Context, prefix string) ([]string, error) {
+	database, err := p.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := database.Model(&promptModel.PromptVersion{})
+	if prefix != "" {
+		tx = tx.Where("path LIKE ? ESCAPE '"+likeEscape+"'", escapeLike(strings.TrimSuffix(prefix, "/"))+"/%")
+	}
+	var names []string
+	if err := tx.Distinct("path").Order("path").Pluck("path", &names).Error; err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	return names, nil
+}
+
+func (p *dbPrompter) CreateTemplate(ctx context.Context, path string, tmpl *Template) error {
+	if err := checkTemplate(path, tmpl); err != nil {
+		return err
+	}
+	database, err := p.prepare(ctx)
+	if err != nil {
+		return err
+	}
+
+	if latest, err := latestVersion(database, path); err != nil {
+		return err
+	} else if latest > 0 {
+		return fmt.Errorf("template '%s' already exists", path)
+	}
+	_, err = p.saveVersion(ctx, database, path, tmpl, "created")
+	return err
+}
+
+func (p *dbPrompter) UpdateTemplate(ctx context.Context, path string, tmpl *Template) error {
+	if path == "" {
+		return fmt.Errorf("path cannot be empty")
+	}
+	if err := checkVariables(tmpl); err != nil {
+		return err
+	}
+	database, err := p.prepare(ctx)
+	if err != nil {
+		return err
+	}
+
+	if latest, err := latestVersion(database, path); err != nil {
+		return err
+	} else if latest == 0 {
+		return fmt.Errorf("template '%s' does not exist", path)
+	}
+	_, err = p.saveVersion(ctx, database, path, tmpl, "updated")
+	return err
+}
+
+// DeleteTemplate deletes every version of a template, or of every template below a directory path. Versions are
+// removed rather than soft deleted so the path can be created again.
+func (p *dbPrompter) DeleteTemplate(ctx context.Context, path string) error {
+	database, err := p.prepare(ctx)
+	if err != nil {
+		return err
+	}
+
+	result := database.Where("path = ? OR path LIKE ? ESCAPE '"+likeEscape+"'", path, escapeLike(path)+"/%").Delete(&promptModel.PromptVersion{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete template '%s': %w", path, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("template '%s' not found", path)
+	}
+	p.changed(ctx, path)
+	return nil
+}
+
+// ListVersions returns the versions of a template, oldest first
+func (p *dbPrompter) ListVersions(ctx context.Context, path string) ([]TemplateVersion, error) {
+	database, err := p.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []promptModel.PromptVersion
+	if err := database.Where("path = ?", path).Order("version").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to list versions of template '%s': %w", path, err)
+	}
+	versions := make([]TemplateVersion, len(rows))
+	for i := range rows {
+		v, err := toTemplateVersion(&rows[i])
+		if err != nil {
+			return nil, err
+		}
+		versions[i] = *v
+	}
+	return versions, nil
+}
+
+// DiffVersions returns a line diff between two versions of a template, 0 selects the newest version
+func (p *dbPrompter) DiffVersions(ctx context.Context, path string, from, to int) (string, error) {
+	database, err := p.prepare(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	fromVersion, err := loadDBVersion(database, path, from)
+	if err != nil {
+		return "", err
+	}
+	toVersion, err := loadDBVersion(database, path, to)
+	if err != nil {
+		return "", err
+	}
+	return diffTemplates(path, fromVersion, toVersion)
+}
+
+// RollbackTemplate restores a version of a template as a new version and returns the new version number
+func (p *dbPrompter) RollbackTemplate(ctx context.Context, path string, version int) (int, error) {
+	if version < 1 {
+		return 0, fmt.Errorf("invalid version %d, versions start at 1", version)
+	}
+	database, err := p.prepare(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := loadDBVersion(database, path, version)
+	if err != nil {
+		return 0, err
+	}
+	return p.saveVersion(ctx, database, path, &v.Template, fmt.Sprintf("rollback to version %d", version))
+}
+
+// prepare returns the database, seeding it from AI.PROMPT.DIR and subscribing to change announcements on first use
+func (p *dbPrompter) prepare(ctx context.Context) (*gorm.DB, error) {
+	if p.dbManager == nil || p.dbManager.DB() == nil {
+		return nil, fmt.Errorf("database is not initialized")
+	}
+	database := p.dbManager.DB().WithContext(ctx)
+
+	// Concurrent first requests wait for the seed, lookups and invalidations of the cache do not
+	p.seedOnce.Do(func() {
+		if cfg, err := configs.GetConfig(); err == nil && cfg.AI.Prompt.Seed && cfg.AI.Prompt.Dir != "" {
+			// The seed outlives the request that happens to trigger it
+			p.seed(context.WithoutCancel(ctx), database.WithContext(context.WithoutCancel(ctx)), cfg.AI.Prompt.Dir)
+		}
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.listening && p.redisManager != nil && p.redisManager.Client() != nil {
+		p.listening = true
+		go p.listen(p.redisManager.Client())
+	}
+	return database, nil
+}
+
+// seed imports the templates of dir that the database does not have yet, with their file versions when there are any
+func (p *dbPrompter) seed(ctx context.Context, database *gorm.DB, dir string) {
+	names, err := p.files.ListTemplates(ctx, "")
+	if err != nil {
+		log.Printf("Failed to seed prompt templates from %s: %v", dir, err)
+		return
+	}
+
+	imported := 0
+	for _, name := range names {
+		if latest, err := latestVersion(database, name); err != nil || latest > 0 {
+			continue
+		}
+
+		var versions []*TemplateVersion
+		numbers, err := versionNumbers(dir, name)
+		for _, n := range numbers {
+			v, loadErr := loadVersion(dir, name, n)
+			if loadErr != nil {
+				err = loadErr
+				break
+			}
+			versions = append(versions, v)
+		}
+		if err == nil && len(numbers) == 0 {
+			var tmpl Template
+			if err = file.LoadJSONFile(filepath.Join(dir, name+".json"), &tmpl); err == nil {
+				versions = []*TemplateVersion{{Version: 1, Note: "imported", CreatedAt: time.Now(), Template: tmpl}}
+			}
+		}
+		if err != nil {
+			log.Printf("Failed to seed prompt template '%s': %v", name, err)
+			continue
+		}
+
+		// Another replica seeding at the same time makes the transaction fail on the unique path and version
+		err = database.Transaction(func(tx *gorm.DB) error {
+			for _, v := range versions {
+				row, err := toPromptVersion(name, v)
+				if err != nil {
+					return err
+				}
+				if err := tx.Create(row).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to seed prompt template '%s': %v", name, err)
+			continue
+		}
+		imported++
+	}
+	if imported > 0 {
+		log.Printf("Seeded %d prompt templates from %s", imported, dir)
+	}
+}
+
+// listen drops cached templates announced as changed by any replica until the Redis client is closed
+func (p *dbPrompter) listen(client *goRedis.Client) {
+	pubsub := client.Subscribe(context.Background(), invalidateChannel)
+	defer pubsub.Close()
+
+	for msg := range pubsub.ChannelWithSubscriptions() {
+		switch m := msg.(type) {
+		case *goRedis.Subscription:
+			// (Re)subscribed, announcements sent while disconnected are lost
+			p.invalidate("")
+		case *goRedis.Message:
+			p.invalidate(m.Payload)
+		}
+	}
+
+	p.mu.Lock()
+	p.listening = false
+	p.cache = make(map[string]*compiledTemplate)
+	p.generation++
+	p.mu.Unlock()
+}
+
+// cachedTemplate returns a version of a template, the newest when version is 0, from the cache while listening
+func (p *dbPrompter) cachedTemplate(database *gorm.DB, path string, version int) (*compiledTemplate, error) {
+	key := path
+	if version > 0 {
+		key = fmt.Sprintf("%s@%d", path, version)
+	}
+
+	p.mu.Lock()
+	compiled, cached := p.cache[key]
+	generation := p.generation
+	p.mu.Unlock()
+	if cached {
+		return compiled, nil
+	}
+
+	v, err := loadDBVersion(database, path, version)
+	if err != nil {
+		return nil, err
+	}
+	compiled = newCompiledTemplate(path, &v.Template, func(ref string) (*Template, error) {
+		path, version, err := splitVersion(ref)
+		if err != nil {
+			return nil, err
+		}
+		v, err := loadDBVersion(database, path, version)
+		if err != nil {
+			return nil, err
+		}
+		return &v.Template, nil
+	})
+	// A change announced while loading may not be in what was read, so the load is only cached without one
+	p.mu.Lock()
+	if p.listening && p.generation == generation {
+		p.cache[key] = compiled
+	}
+	p.mu.Unlock()
+	return compiled, nil
+}
+
+// invalidate drops the cached versions of a template, of every template below it and of every template extending
+// or including any of those, all of them when path is empty
+func (p *dbPrompter) invalidate(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.generation++
+	for key, compiled := range p.cache {
+		if compiled.stale(key, path) {
+			delete(p.cache, key)
+		}
+	}
+}
+
+// changed drops the local cache of a template and announces the change to the other replicas
+func (p *dbPrompter) changed(ctx context.Context, path string) {
+	p.invalidate(path)
+	if p.redisManager == nil || p.redisManager.Client() == nil {
+		return
+	}
+	if err := p.redisManager.Client().Publish(ctx, invalidateChannel, path).Err(); err != nil {
+		log.Printf("Failed to announce change of prompt template '%s': %v", path, err)
+	}
+}
+
+// saveVersion stores tmpl as the next version of a template and returns its number. The unique path and version
+// index rejects a concurrent write of the same version from another replica.
+func (p *dbPrompter) saveVersion(ctx context.Context, database *gorm.DB, path string, tmpl *Template, note string) (int, error) {
+	latest, err := latestVersion(database, path)
+	if err != nil {
+		return 0, err
+	}
+
+	v := &TemplateVersion{Version: latest + 1, Template: *tmpl}
+	v.Author, v.Note = describeChange(ctx, note)
+	row, err := toPromptVersion(path, v)
+	if err != nil {
+		return 0, err
+	}
+	if err := database.Create(row).Error; err != nil {
+		return 0, fmt.Errorf("failed to save version %d of template '%s': %w", v.Version, path, err)
+	}
+	p.changed(ctx, path)
+	return v.Version, nil
+}
+
+// latestVersion returns the newest version number of a template, 0 when it does not exist
+func latestVersion(database *gorm.DB, path string) (int, error) {
+	var latest int
+	err := database.Model(&promptModel.PromptVersion{}).Where("path = ?", path).Select("COALESCE(MAX(version), 0)").Scan(&latest).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest version of template '%s': %w", path, err)
+	}
+	return latest, nil
+}
+
+// loadDBVersion reads one version of a template, the newest when version is 0
+func loadDBVersion(database *gorm.DB, path string, version int) (*TemplateVersion, error) {
+	tx := database.Where("path = ?", path)
+	if version > 0 {
+		tx = tx.Where("version = ?", version)
+	}
+
+	var row promptModel.PromptVersion
+	if err := tx.Order("version DESC").First(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if version > 0 {
+				return nil, fmt.Errorf("version %d of template '%s' not found", version, path)
+			}
+			return nil, fmt.Errorf("template '%s' not found", path)
+		}
+		return nil, fmt.Errorf("failed to load template '%s': %w", path, err)
+	}
+	return toTemplateVersion(&row)
+}
+
+func toPromptVersion(path string, v *TemplateVersion) (*promptModel.PromptVersion, error) {
+	tmpl := v.Template
+	tmpl.Version = v.Version
+	content, err := json.Marshal(&tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal template '%s': %w", path, err)
+	}
+
+	row := &promptModel.PromptVersion{Path: path, Version: v.Version, Author: v.Author, Note: v.Note, Content: string(content)}
+	if !v.CreatedAt.IsZero() {
+		// The create hook only fills in the timestamps of rows without an ID, keep those of imported versions
+		if row.ID, err = snowflake.GenerateID(); err != nil {
+			return nil, err
+		}
+		row.CreatedAt = v.CreatedAt.Unix()
+		row.UpdatedAt = row.CreatedAt
+	}
+	return row, nil
+}
+
+func toTemplateVersion(row *promptModel.PromptVersion) (*TemplateVersion, error) {
+	v := &TemplateVersion{Version: row.Version, Author: row.Author, Note: row.Note, CreatedAt: time.Unix(row.CreatedAt, 0)}
+	if err := json.Unmarshal([]byte(row.Content), &v.Template); err != nil {
+		return nil, fmt.Errorf("invalid version %d of template '%s': %w", row.Version, row.Path, err)
+	}
+	v.Template.Version = row.Version
+	return v, nil
+}
+
+// escapeLike escapes the LIKE wildcards of s, template names often contain underscores
+func escapeLike(s string) string {
+	return strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_").Replace(s)
+}
@@ -0,0 +1,164 @@
+package prompter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/db/dbtest"
+
+	promptModel "github.com/Done-0/gin-scaffold/internal/model/prompt"
+)
+
+func TestDatabasePrompter(t *testing.T) {
+	promptDir := initPromptDir(t)
+	file := New()
+	ctx := context.Background()
+
+	// Seed sources: a versioned template and a plain file
+	file.CreateTemplate(ctx, "seeded/versioned", &Template{Name: "Versioned", Messages: []Message{{Role: "system", Content: "v1"}}})
+	file.UpdateTemplate(ctx, "seeded/versioned", &Template{Name: "Versioned", Messages: []Message{{Role: "system", Content: "v2"}}})
+	os.WriteFile(filepath.Join(promptDir, "plain.json"), []byte(`{"name":"Plain","messages":[{"role":"system","content":"Hello {{.name}}"}]}`), 0644)
+	os.WriteFile(filepath.Join(filepath.Dir(promptDir), "configs", "config.local.yml"), []byte("AI:\n  PROMPT:\n    DIR: "+promptDir+"\n    STORE: database\n    SEED: true"), 0644)
+	if err := configs.New(); err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+
+	dbManager := dbtest.New(t, &promptModel.PromptVersion{})
+	p := NewDatabase(dbManager, nil)
+
+	content := func(ref string) string {
+		t.Helper()
+		tmpl, err := p.GetTemplate(ctx, ref, nil)
+		if err != nil {
+			t.Fatalf("GetTemplate(%s) failed: %v", ref, err)
+		}
+		return tmpl.Messages[0].Content
+	}
+
+	t.Run("Seed", func(t *testing.T) {
+		vars := map[string]any{"name": "World"}
+		rendered, err := p.GetTemplate(ctx, "plain", &vars)
+		if err != nil {
+			t.Fatalf("GetTemplate failed: %v", err)
+		}
+		if rendered.Messages[0].Content != "Hello World" || rendered.Version != 1 {
+			t.Errorf("plain = %q version %d, want Hello World version 1", rendered.Messages[0].Content, rendered.Version)
+		}
+		if content("seeded/versioned") != "v2" || content("seeded/versioned@1") != "v1" {
+			t.Errorf("seeded/versioned should keep its file versions")
+		}
+	})
+
+	t.Run("CreateUpdateRollback", func(t *testing.T) {
+		if err := p.CreateTemplate(WithChange(ctx, "alice", ""), "stories/midnight_store", &Template{Name: "Store", Messages: []Message{{Role: "system", Content: "Dark"}}}); err != nil {
+			t.Fatalf("CreateTemplate failed: %v", err)
+		}
+		if err := p.CreateTemplate(ctx, "stories/midnight_store", &Template{Name: "Store", Messages: []Message{{Role: "system", Content: "Dark"}}}); err == nil {
+			t.Error("Should fail creating duplicate template")
+		}
+		if err := p.UpdateTemplate(WithChange(ctx, "bob", "darker"), "stories/midnight_store", &Template{Name: "Store", Messages: []Message{{Role: "system", Content: "Darker"}}}); err != nil {
+			t.Fatalf("UpdateTemplate failed: %v", err)
+		}
+		if err := p.UpdateTemplate(ctx, "stories/missing", &Template{Messages: []Message{{Role: "system", Content: "x"}}}); err == nil {
+			t.Error("Should fail updating a missing template")
+		}
+
+		version, err := p.RollbackTemplate(ctx, "stories/midnight_store", 1)
+		if err != nil || version != 3 {
+			t.Fatalf("RollbackTemplate = %d, %v, want version 3", version, err)
+		}
+		if content("stories/midnight_store") != "Dark" || content("stories/midnight_store@2") != "Darker" {
+			t.Errorf("Rollback should restore version 1 and keep version 2")
+		}
+
+		versions, err := p.ListVersions(ctx, "stories/midnight_store")
+		if err != nil || len(versions) != 3 {
+			t.Fatalf("ListVersions = %d versions, %v, want 3", len(versions), err)
+		}
+		if versions[0].Author != "alice" || versions[0].Note != "created" || versions[1].Note != "darker" || versions[2].Note != "rollback to version 1" {
+			t.Errorf("Versions = %+v", versions)
+		}
+
+		diff, err := p.DiffVersions(ctx, "stories/midnight_store", 1, 2)
+		if err != nil || !strings.Contains(diff, "-      \"content\": \"Dark\"\n+      \"content\": \"Darker\"\n") {
+			t.Errorf("DiffVersions = %q, %v", diff, err)
+		}
+	})
+
+	t.Run("ListTemplates", func(t *testing.T) {
+		// The underscore of the prefix must not match any character
+		p.CreateTemplate(ctx, "a_b/one", &Template{Messages: []Message{{Role: "system", Content: "x"}}})
+		p.CreateTemplate(ctx, "axb/two", &Template{Messages: []Message{{Role: "system", Content: "x"}}})
+
+		names, err := p.ListTemplates(ctx, "")
+		if err != nil {
+			t.Fatalf("ListTemplates failed: %v", err)
+		}
+		if got := strings.Join(names, ","); got != "a_b/one,axb/two,plain,seeded/versioned,stories/midnight_store" {
+			t.Errorf("ListTemplates = %s", got)
+		}
+		if names, _ := p.ListTemplates(ctx, "a_b"); len(names) != 1 || names[0] != "a_b/one" {
+			t.Errorf("ListTemplates(a_b) = %v, want only a_b/one", names)
+		}
+	})
+
+	t.Run("DeleteTemplate", func(t *testing.T) {
+		if err := p.DeleteTemplate(ctx, "stories"); err != nil {
+			t.Fatalf("DeleteTemplate directory failed: %v", err)
+		}
+		if _, err := p.GetTemplate(ctx, "stories/midnight_store", nil); err == nil {
+			t.Error("Deleted template should not exist")
+		}
+		if err := p.DeleteTemplate(ctx, "stories"); err == nil {
+			t.Error("Should fail deleting a missing template")
+		}
+		if err := p.CreateTemplate(ctx, "stories/midnight_store", &Template{Messages: []Message{{Role: "system", Content: "Again"}}}); err != nil {
+			t.Fatalf("Recreating a deleted template failed: %v", err)
+		}
+	})
+
+	t.Run("Invalidation", func(t *testing.T) {
+		// Two replicas sharing the database, the first caches as if subscribed
+		replica := NewDatabase(dbManager, nil)
+		cached := p.(*dbPrompter)
+		cached.listening = true
+		defer func() { cached.listening = false }()
+
+		if content("plain") != "Hello {{.name}}" {
+			t.Fatal("unexpected plain content")
+		}
+		replica.UpdateTemplate(ctx, "plain", &Template{Messages: []Message{{Role: "system", Content: "Changed"}}})
+		if content("plain") != "Hello {{.name}}" {
+			t.Error("plain should be served from the cache until the change is announced")
+		}
+
+		cached.invalidate("plain")
+		if content("plain") != "Changed" {
+			t.Error("plain should be reloaded after the change is announced")
+		}
+
+		// Templates including a changed partial are dropped with it
+		p.CreateTemplate(ctx, "common/rules", &Template{Messages: []Message{{Role: "system", Content: "Rule one"}}})
+		p.CreateTemplate(ctx, "ruled", &Template{Extends: "plain", Messages: []Message{{Role: "user", Content: `{{template "common/rules"}}`}}})
+		render := func() string {
+			vars := map[string]any{}
+			tmpl, err := p.GetTemplate(ctx, "ruled", &vars)
+			if err != nil {
+				t.Fatalf("GetTemplate(ruled) failed: %v", err)
+			}
+			return tmpl.Messages[0].Content + "|" + tmpl.Messages[1].Content
+		}
+		if got := render(); got != "Changed|Rule one" {
+			t.Fatalf("ruled = %q, want Changed|Rule one", got)
+		}
+		replica.UpdateTemplate(ctx, "common/rules", &Template{Messages: []Message{{Role: "system", Content: "Rule two"}}})
+		cached.invalidate("common/rules")
+		if got := render(); got != "Changed|Rule two" {
+			t.Errorf("ruled = %q, want Changed|Rule two", got)
+		}
+	})
+}
@@ -9,47 +9,73 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/Done-0/gin-scaffold/configs"
 	"github.com/Done-0/gin-scaffold/internal/utils/file"
-	"github.com/Done-0/gin-scaffold/internal/utils/template"
 )
 
-type prompter struct{}
+type prompter struct {
+	mu    sync.Mutex // Serializes writes so version numbers are assigned once
+	cache *templateCache
+}
 
+// New creates a prompter reading templates from AI.PROMPT.DIR. Parsed templates are cached and dropped when an
+// fsnotify watcher reports a change of their files.
 func New() Prompter {
-	return &prompter{}
+	return &prompter{cache: newTemplateCache()}
 }
 
+// GetTemplate loads a template by path, path@version pins a version and path@latest resolves the newest one
 func (p *prompter) GetTemplate(ctx context.Context, path string, vars *map[string]any) (*Template, error) {
 	cfg, err := configs.GetConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get config: %w", err)
 	}
 
-	var tmpl Template
-	if err := file.LoadJSONFile(filepath.Join(cfg.AI.Prompt.Dir, path+".json"), &tmpl); err != nil {
-		return nil, fmt.Errorf("failed to load template '%s': %w", path, err)
+	key := path
+	path, version, err := splitVersion(path)
+	if err != nil {
+		return nil, err
 	}
-
-	if vars == nil {
-		return &tmpl, nil
+	if version == 0 {
+		key = path // path@latest shares the entry of path
 	}
 
-	result := &Template{
-		Name:        tmpl.Name,
-		Description: tmpl.Description,
-		Variables:   tmpl.Variables,
-		Messages:    make([]Message, len(tmpl.Messages)),
+	compiled, err := p.cache.get(cfg.AI.Prompt.Dir, key, func() (*compiledTemplate, error) {
+		load := func(ref string) (*Template, error) {
+			path, version, err := splitVersion(ref)
+			if err != nil {
+				return nil, err
+			}
+			return loadTemplate(cfg.AI.Prompt.Dir, path, version)
+		}
+		tmpl, err := loadTemplate(cfg.AI.Prompt.Dir, path, version)
+		if err != nil {
+			return nil, err
+		}
+		return newCompiledTemplate(path, tmpl, load), nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	for i, msg := range tmpl.Messages {
-		content, err := template.Replace(msg.Content, *vars)
+	return compiled.render(path, vars)
+}
+
+// loadTemplate reads one version of a template, the live file when version is 0
+func loadTemplate(dir, path string, version int) (*Template, error) {
+	if version > 0 {
+		v, err := loadVersion(dir, path, version)
 		if err != nil {
-			return nil, fmt.Errorf("failed to replace variables in message %d: %w", i, err)
+			return nil, err
 		}
-		result.Messages[i] = Message{Role: msg.Role, Content: content}
+		return &v.Template, nil
 	}
-	return result, nil
+	var tmpl Template
+	if err := file.LoadJSONFile(filepath.Join(dir, path+".json"), &tmpl); err != nil {
+		return nil, fmt.Errorf("failed to load template '%s': %w", path, err)
+	}
+	return &tmpl, nil
 }
 
 func (p *prompter) ListTemplates(ctx context.Context, prefix string) ([]string, error) {
@@ -66,6 +92,9 @@ func (p *prompter) ListTemplates(ctx context.Context, prefix string) ([]string,
 
 	var names []string
 	err = filepath.Walk(searchDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() && info.Name() == versionsDir {
+			return filepath.SkipDir
+		}
 		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".json") {
 			return err
 		}
@@ -85,13 +114,13 @@ func (p *prompter) CreateTemplate(ctx context.Context, path string, tmpl *Templa
 		return fmt.Errorf("failed to get config: %w", err)
 	}
 
-	if path == "" {
-		return fmt.Errorf("path cannot be empty")
-	}
-	if len(tmpl.Messages) == 0 {
-		return fmt.Errorf("template must have at least one message")
+	if err := checkTemplate(path, tmpl); err != nil {
+		return err
 	}
 
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	filePath := filepath.Join(cfg.AI.Prompt.Dir, path+".json")
 	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
@@ -99,7 +128,13 @@ func (p *prompter) CreateTemplate(ctx context.Context, path string, tmpl *Templa
 	if _, err := os.Stat(filePath); err == nil {
 		return fmt.Errorf("template '%s' already exists", path)
 	}
-	return file.SaveJSONFile(filePath, tmpl)
+	// Versions left behind by a template deleted outside the prompter do not belong to the new one
+	if err := removeVersions(cfg.AI.Prompt.Dir, path, false); err != nil {
+		return err
+	}
+	_, err = saveVersion(ctx, cfg.AI.Prompt.Dir, path, tmpl, "created")
+	p.cache.invalidate(path)
+	return err
 }
 
 func (p *prompter) UpdateTemplate(ctx context.Context, path string, tmpl *Template) error {
@@ -111,13 +146,24 @@ func (p *prompter) UpdateTemplate(ctx context.Context, path string, tmpl *Templa
 	if path == "" {
 		return fmt.Errorf("path cannot be empty")
 	}
+	if err := checkVariables(tmpl); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
 	filePath := filepath.Join(cfg.AI.Prompt.Dir, path+".json")
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		return fmt.Errorf("template '%s' does not exist", path)
 	}
 
-	return file.SaveJSONFile(filePath, tmpl)
+	if err := ensureBaseVersion(cfg.AI.Prompt.Dir, path); err != nil {
+		return err
+	}
+	_, err = saveVersion(ctx, cfg.AI.Prompt.Dir, path, tmpl, "updated")
+	p.cache.invalidate(path)
+	return err
 }
 
 func (p *prompter) DeleteTemplate(ctx context.Context, path string) error {
@@ -126,15 +172,105 @@ func (p *prompter) DeleteTemplate(ctx context.Context, path string) error {
 		return fmt.Errorf("failed to get config: %w", err)
 	}
 
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// The watcher reports the removal too, dropping the cache here makes it apply to the next lookup already
+	defer p.cache.invalidate(path)
+
 	fullPath := filepath.Join(cfg.AI.Prompt.Dir, path)
 
 	if info, err := os.Stat(fullPath + ".json"); err == nil && !info.IsDir() {
+		if err := removeVersions(cfg.AI.Prompt.Dir, path, false); err != nil {
+			return err
+		}
 		return os.Remove(fullPath + ".json")
 	}
 
 	if info, err := os.Stat(fullPath); err == nil && info.IsDir() {
+		if err := removeVersions(cfg.AI.Prompt.Dir, path, true); err != nil {
+			return err
+		}
 		return os.RemoveAll(fullPath)
 	}
 
 	return fmt.Errorf("template '%s' not found", path)
 }
+
+// ListVersions returns the versions of a template, oldest first
+func (p *prompter) ListVersions(ctx context.Context, path string) ([]TemplateVersion, error) {
+	cfg, err := configs.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config: %w", err)
+	}
+
+	numbers, err := versionNumbers(cfg.AI.Prompt.Dir, path)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]TemplateVersion, 0, len(numbers))
+	for _, n := range numbers {
+		v, err := loadVersion(cfg.AI.Prompt.Dir, path, n)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, *v)
+	}
+	return versions, nil
+}
+
+// DiffVersions returns a line diff between two versions of a template, 0 selects the newest version
+func (p *prompter) DiffVersions(ctx context.Context, path string, from, to int) (string, error) {
+	cfg, err := configs.GetConfig()
+	if err != nil {
+		return "", fmt.Errorf("failed to get config: %w", err)
+	}
+
+	fromVersion, err := loadVersion(cfg.AI.Prompt.Dir, path, from)
+	if err != nil {
+		return "", err
+	}
+	toVersion, err := loadVersion(cfg.AI.Prompt.Dir, path, to)
+	if err != nil {
+		return "", err
+	}
+	return diffTemplates(path, fromVersion, toVersion)
+}
+
+// RollbackTemplate restores a version of a template as a new version, so the history stays intact, and returns
+// the new version number
+func (p *prompter) RollbackTemplate(ctx context.Context, path string, version int) (int, error) {
+	cfg, err := configs.GetConfig()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get config: %w", err)
+	}
+	if version < 1 {
+		return 0, fmt.Errorf("invalid version %d, versions start at 1", version)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	v, err := loadVersion(cfg.AI.Prompt.Dir, path, version)
+	if err != nil {
+		return 0, err
+	}
+	defer p.cache.invalidate(path)
+	return saveVersion(ctx, cfg.AI.Prompt.Dir, path, &v.Template, fmt.Sprintf("rollback to version %d", version))
+}
+
+// checkTemplate validates the path and content of a new template
+func checkTemplate(path string, tmpl *Template) error {
+	if path == "" {
+		return fmt.Errorf("path cannot be empty")
+	}
+	if strings.Contains(path, "@") {
+		return fmt.Errorf("path cannot contain '@', it separates the version")
+	}
+	if len(tmpl.Messages) == 0 && tmpl.Extends == "" {
+		return fmt.Errorf("template must have at least one message or extend a template")
+	}
+	if base, _, _ := strings.Cut(tmpl.Extends, "@"); base == path {
+		return fmt.Errorf("template '%s' cannot extend itself", path)
+	}
+	return checkVariables(tmpl)
+}
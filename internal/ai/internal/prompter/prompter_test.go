@@ -5,11 +5,15 @@ package prompter
 
 import (
 	"context"
+	"errors"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Done-0/gin-scaffold/configs"
 )
@@ -269,3 +273,433 @@ func TestPrompt(t *testing.T) {
 		t.Logf("Final template list: %v", names)
 	})
 }
+
+// initPromptDir points AI.PROMPT.DIR at a fresh directory and returns it
+func initPromptDir(t *testing.T) string {
+	t.Helper()
+	testDir := t.TempDir()
+	configDir := filepath.Join(testDir, "configs")
+	promptDir := filepath.Join(testDir, "prompts")
+	os.MkdirAll(configDir, 0755)
+	os.MkdirAll(promptDir, 0755)
+	os.WriteFile(filepath.Join(configDir, "config.local.yml"), []byte("AI:\n  PROMPT:\n    DIR: "+promptDir), 0644)
+
+	oldDir, _ := os.Getwd()
+	os.Chdir(testDir)
+	t.Cleanup(func() { os.Chdir(oldDir) })
+
+	if err := configs.New(); err != nil {
+		t.Fatalf("Failed to initialize config: %v", err)
+	}
+	return promptDir
+}
+
+func TestTemplateVersions(t *testing.T) {
+	promptDir := initPromptDir(t)
+	p := New()
+	ctx := context.Background()
+
+	content := func(ref string) string {
+		t.Helper()
+		tmpl, err := p.GetTemplate(ctx, ref, nil)
+		if err != nil {
+			t.Fatalf("GetTemplate(%s) failed: %v", ref, err)
+		}
+		return tmpl.Messages[0].Content
+	}
+
+	if err := p.CreateTemplate(WithChange(ctx, "alice", "first draft"), "greeting", &Template{
+		Name:     "Greeting",
+		Messages: []Message{{Role: "system", Content: "Hello"}},
+	}); err != nil {
+		t.Fatalf("CreateTemplate failed: %v", err)
+	}
+	for i, text := range []string{"Hello there", "Hi"} {
+		if err := p.UpdateTemplate(WithChange(ctx, "bob", "edit "+text), "greeting", &Template{
+			Name:     "Greeting",
+			Messages: []Message{{Role: "system", Content: text}},
+		}); err != nil {
+			t.Fatalf("UpdateTemplate %d failed: %v", i, err)
+		}
+	}
+
+	t.Run("ResolveVersions", func(t *testing.T) {
+		for ref, want := range map[string]string{"greeting": "Hi", "greeting@latest": "Hi", "greeting@1": "Hello", "greeting@2": "Hello there"} {
+			if got := content(ref); got != want {
+				t.Errorf("GetTemplate(%s) = %q, want %q", ref, got, want)
+			}
+		}
+		if tmpl, _ := p.GetTemplate(ctx, "greeting@2", nil); tmpl.Version != 2 {
+			t.Errorf("Version = %d, want 2", tmpl.Version)
+		}
+		for _, ref := range []string{"greeting@4", "greeting@0", "greeting@v1"} {
+			if _, err := p.GetTemplate(ctx, ref, nil); err == nil {
+				t.Errorf("GetTemplate(%s) should fail", ref)
+			}
+		}
+	})
+
+	t.Run("ListVersions", func(t *testing.T) {
+		versions, err := p.ListVersions(ctx, "greeting")
+		if err != nil {
+			t.Fatalf("ListVersions failed: %v", err)
+		}
+		if len(versions) != 3 {
+			t.Fatalf("ListVersions returned %d versions, want 3", len(versions))
+		}
+		if v := versions[0]; v.Version != 1 || v.Author != "alice" || v.Note != "first draft" || v.CreatedAt.IsZero() {
+			t.Errorf("Version 1 = %+v, want alice's first draft", v)
+		}
+		if v := versions[2]; v.Version != 3 || v.Author != "bob" || v.Template.Messages[0].Content != "Hi" {
+			t.Errorf("Version 3 = %+v, want bob's edit", v)
+		}
+	})
+
+	t.Run("DiffVersions", func(t *testing.T) {
+		diff, err := p.DiffVersions(ctx, "greeting", 1, 0)
+		if err != nil {
+			t.Fatalf("DiffVersions failed: %v", err)
+		}
+		for _, line := range []string{"--- greeting@1", "+++ greeting@3", `-      "content": "Hello"`, `+      "content": "Hi"`, `   "name": "Greeting",`} {
+			if !strings.Contains(diff, line+"\n") {
+				t.Errorf("Diff missing line %q:\n%s", line, diff)
+			}
+		}
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		version, err := p.RollbackTemplate(WithChange(ctx, "carol", ""), "greeting", 1)
+		if err != nil {
+			t.Fatalf("RollbackTemplate failed: %v", err)
+		}
+		if version != 4 || content("greeting") != "Hello" || content("greeting@3") != "Hi" {
+			t.Errorf("Rollback created version %d with content %q, want version 4 restoring version 1", version, content("greeting"))
+		}
+		versions, _ := p.ListVersions(ctx, "greeting")
+		if v := versions[len(versions)-1]; v.Author != "carol" || v.Note != "rollback to version 1" {
+			t.Errorf("Rollback version = %+v, want carol's rollback note", v)
+		}
+	})
+
+	t.Run("UnversionedTemplate", func(t *testing.T) {
+		// Templates written before versioning get their original recorded as version 1 on the first update
+		os.WriteFile(filepath.Join(promptDir, "seeded.json"), []byte(`{"name":"Seeded","messages":[{"role":"system","content":"Original"}]}`), 0644)
+		if err := p.UpdateTemplate(ctx, "seeded", &Template{Name: "Seeded", Messages: []Message{{Role: "system", Content: "Edited"}}}); err != nil {
+			t.Fatalf("UpdateTemplate failed: %v", err)
+		}
+		if content("seeded@1") != "Original" || content("seeded") != "Edited" {
+			t.Errorf("seeded@1 = %q, seeded = %q, want Original and Edited", content("seeded@1"), content("seeded"))
+		}
+	})
+
+	t.Run("VersionsHiddenAndDeleted", func(t *testing.T) {
+		names, _ := p.ListTemplates(ctx, "")
+		sort.Strings(names)
+		if strings.Join(names, ",") != "greeting,seeded" {
+			t.Errorf("ListTemplates = %v, want only greeting and seeded", names)
+		}
+		if err := p.DeleteTemplate(ctx, "greeting"); err != nil {
+			t.Fatalf("DeleteTemplate failed: %v", err)
+		}
+		if versions, _ := p.ListVersions(ctx, "greeting"); len(versions) != 0 {
+			t.Errorf("ListVersions after delete = %d versions, want 0", len(versions))
+		}
+	})
+}
+
+func TestTemplateVariables(t *testing.T) {
+	promptDir := initPromptDir(t)
+	p := New()
+	ctx := context.Background()
+
+	os.WriteFile(filepath.Join(promptDir, "persona.json"), []byte(`{
+  "name": "Persona",
+  "variables": {
+    "name": {"type": "string", "required": true, "max_length": 5},
+    "tone": {"type": "string", "enum": ["formal", "casual"], "default": "formal"},
+    "age": {"type": "integer"},
+    "topics": {"type": "array", "max_length": 2},
+    "note": "untyped description"
+  },
+  "messages": [{"role": "system", "content": "{{.name}} {{.tone}}{{if .age}} {{.age}}{{end}}{{range .topics}} {{.}}{{end}}{{.note}}"}]
+}`), 0644)
+
+	render := func(vars map[string]any) (string, error) {
+		tmpl, err := p.GetTemplate(ctx, "persona", &vars)
+		if err != nil {
+			return "", err
+		}
+		return tmpl.Messages[0].Content, nil
+	}
+
+	t.Run("Defaults", func(t *testing.T) {
+		vars := map[string]any{"name": "Ann"}
+		got, err := render(vars)
+		if err != nil || got != "Ann formal" {
+			t.Errorf("render = %q, %v, want \"Ann formal\"", got, err)
+		}
+		if len(vars) != 1 {
+			t.Errorf("Caller vars should not be modified, got %v", vars)
+		}
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		got, err := render(map[string]any{"name": "Bob", "tone": "casual", "age": 30.0, "topics": []string{"go", "ai"}})
+		if err != nil || got != "Bob casual 30 go ai" {
+			t.Errorf("render = %q, %v, want \"Bob casual 30 go ai\"", got, err)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := render(map[string]any{"tone": "angry", "age": 1.5, "topics": []string{"a", "b", "c"}})
+		var varErr *VariableError
+		if !errors.As(err, &varErr) {
+			t.Fatalf("err = %v, want VariableError", err)
+		}
+		if got := slices.Sorted(maps.Keys(varErr.Invalid)); strings.Join(got, ",") != "age,name,tone,topics" {
+			t.Errorf("Invalid variables = %v, want age, name, tone and topics", got)
+		}
+		if !strings.Contains(err.Error(), "name is required") || !strings.Contains(err.Error(), "tone must be one of [formal casual]") {
+			t.Errorf("Error should name the variables, got: %v", err)
+		}
+		if _, err := render(map[string]any{"name": "Annabel"}); err == nil || !strings.Contains(err.Error(), "name must be at most 5 characters") {
+			t.Errorf("Too long name err = %v", err)
+		}
+	})
+
+	t.Run("MissingKey", func(t *testing.T) {
+		// Templates without a schema fail on variables that were not passed instead of rendering <no value>
+		p.CreateTemplate(ctx, "loose", &Template{Messages: []Message{{Role: "system", Content: "Hi {{.who}}"}}})
+		vars := map[string]any{}
+		if _, err := p.GetTemplate(ctx, "loose", &vars); err == nil || !strings.Contains(err.Error(), "who") {
+			t.Errorf("err = %v, want missing key who", err)
+		}
+	})
+
+	t.Run("InvalidSchema", func(t *testing.T) {
+		for name, v := range map[string]Variable{
+			"unknown type":   {Type: "date"},
+			"bad default":    {Type: VariableInteger, Default: "ten"},
+			"bad enum value": {Type: VariableString, Enum: []any{1.0}},
+		} {
+			tmpl := &Template{Variables: map[string]Variable{"x": v}, Messages: []Message{{Role: "system", Content: "{{.x}}"}}}
+			if err := p.CreateTemplate(ctx, "schema", tmpl); err == nil {
+				t.Errorf("CreateTemplate should reject %s", name)
+			}
+		}
+	})
+
+	t.Run("DescriptionFormat", func(t *testing.T) {
+		// Description-only variables keep their plain string format when saved
+		if err := p.UpdateTemplate(ctx, "persona", &Template{Variables: map[string]Variable{"note": {Description: "plain"}}, Messages: []Message{{Role: "system", Content: "{{.note}}"}}}); err != nil {
+			t.Fatalf("UpdateTemplate failed: %v", err)
+		}
+		data, _ := os.ReadFile(filepath.Join(promptDir, "persona.json"))
+		if !strings.Contains(string(data), `"note": "plain"`) {
+			t.Errorf("persona.json = %s", data)
+		}
+	})
+}
+
+func TestTemplateCache(t *testing.T) {
+	promptDir := initPromptDir(t)
+	p := New()
+	cache := p.(*prompter).cache
+	ctx := context.Background()
+
+	write := func(name, content string) {
+		t.Helper()
+		os.MkdirAll(filepath.Dir(filepath.Join(promptDir, name+".json")), 0755)
+		data := `{"name":"Cached","messages":[{"role":"system","content":"` + content + `"}]}`
+		if err := os.WriteFile(filepath.Join(promptDir, name+".json"), []byte(data), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	render := func(ref string) string {
+		t.Helper()
+		vars := map[string]any{"name": "World"}
+		tmpl, err := p.GetTemplate(ctx, ref, &vars)
+		if err != nil {
+			t.Fatalf("GetTemplate(%s) failed: %v", ref, err)
+		}
+		return tmpl.Messages[0].Content
+	}
+	// eventually waits for the watcher to report an edit made outside the prompter
+	eventually := func(ref, want string) {
+		t.Helper()
+		for range 200 {
+			if render(ref) == want {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Errorf("GetTemplate(%s) = %q, want %q", ref, render(ref), want)
+	}
+
+	t.Run("ReuseCompiled", func(t *testing.T) {
+		write("greeting", "Hello {{.name}}")
+		if got := render("greeting"); got != "Hello World" {
+			t.Fatalf("render = %q", got)
+		}
+		entry := func() *compiledTemplate {
+			cache.mu.Lock()
+			defer cache.mu.Unlock()
+			return cache.entries["greeting"]
+		}
+		first := entry()
+		render("greeting")
+		if first == nil || entry() != first || first.messages == nil {
+			t.Error("Renders should reuse the compiled template")
+		}
+
+		raw, _ := p.GetTemplate(ctx, "greeting", nil)
+		raw.Messages[0].Content = "mutated"
+		if got := render("greeting"); got != "Hello World" {
+			t.Errorf("Mutating a returned template changed the cache, got %q", got)
+		}
+	})
+
+	t.Run("ExternalEdit", func(t *testing.T) {
+		write("greeting", "Hi {{.name}}")
+		eventually("greeting", "Hi World")
+	})
+
+	t.Run("NewDirectory", func(t *testing.T) {
+		write("stories/horror/elevator", "Up {{.name}}")
+		eventually("stories/horror/elevator", "Up World")
+		write("stories/horror/elevator", "Down {{.name}}")
+		eventually("stories/horror/elevator", "Down World")
+	})
+
+	t.Run("PrompterWrites", func(t *testing.T) {
+		if err := p.UpdateTemplate(ctx, "greeting", &Template{Messages: []Message{{Role: "system", Content: "Hey {{.name}}"}}}); err != nil {
+			t.Fatalf("UpdateTemplate failed: %v", err)
+		}
+		if got := render("greeting"); got != "Hey World" {
+			t.Errorf("render after UpdateTemplate = %q, want Hey World", got)
+		}
+		if got := render("greeting@1"); got != "Hi World" {
+			t.Errorf("render greeting@1 = %q, want Hi World", got)
+		}
+		p.DeleteTemplate(ctx, "greeting")
+		if _, err := p.GetTemplate(ctx, "greeting@1", nil); err == nil {
+			t.Error("Deleted template versions should not be served from the cache")
+		}
+	})
+
+	t.Run("TemplatePath", func(t *testing.T) {
+		for name, want := range map[string]string{
+			"greeting.json":                  "greeting",
+			"stories/horror":                 "stories/horror",
+			".versions/stories/store/2.json": "stories/store",
+			".versions/stories":              "stories",
+			".versions":                      "",
+			"":                               "",
+		} {
+			if got := templatePath(promptDir, filepath.Join(promptDir, name)); got != want {
+				t.Errorf("templatePath(%s) = %q, want %q", name, got, want)
+			}
+		}
+	})
+}
+
+func TestTemplateComposition(t *testing.T) {
+	initPromptDir(t)
+	p := New()
+	ctx := context.Background()
+
+	create := func(path string, tmpl *Template) {
+		t.Helper()
+		if err := p.CreateTemplate(ctx, path, tmpl); err != nil {
+			t.Fatalf("CreateTemplate(%s) failed: %v", path, err)
+		}
+	}
+	render := func(ref string, vars map[string]any) ([]Message, error) {
+		tmpl, err := p.GetTemplate(ctx, ref, &vars)
+		if err != nil {
+			return nil, err
+		}
+		return tmpl.Messages, nil
+	}
+
+	create("common/safety", &Template{
+		Variables: map[string]Variable{"name": {Type: VariableString, Required: true}},
+		Messages:  []Message{{Role: "system", Content: "Be safe, {{.name}}."}},
+	})
+	create("common/base", &Template{
+		Variables: map[string]Variable{"tone": {Type: VariableString, Default: "calm"}},
+		Messages:  []Message{{Role: "system", Content: "You are a {{.tone}} narrator."}},
+	})
+	create("stories/store", &Template{
+		Extends:  "common/base",
+		Messages: []Message{{Role: "user", Content: `{{template "common/safety" .}} Tell a story.`}},
+	})
+
+	t.Run("ExtendsAndPartials", func(t *testing.T) {
+		messages, err := render("stories/store", map[string]any{"name": "Ann"})
+		if err != nil {
+			t.Fatalf("render failed: %v", err)
+		}
+		if len(messages) != 2 || messages[0].Content != "You are a calm narrator." || messages[1].Content != "Be safe, Ann. Tell a story." {
+			t.Errorf("messages = %+v", messages)
+		}
+
+		raw, _ := p.GetTemplate(ctx, "stories/store", nil)
+		if raw.Extends != "common/base" || len(raw.Messages) != 1 {
+			t.Errorf("Raw template should keep its stored form, got %+v", raw)
+		}
+	})
+
+	t.Run("PartialVariables", func(t *testing.T) {
+		var varErr *VariableError
+		if _, err := render("stories/store", map[string]any{}); !errors.As(err, &varErr) || varErr.Invalid["name"] != "is required" {
+			t.Errorf("err = %v, want name is required", err)
+		}
+	})
+
+	t.Run("DependencyChange", func(t *testing.T) {
+		if err := p.UpdateTemplate(ctx, "common/safety", &Template{Messages: []Message{{Role: "system", Content: "Stay kind, {{.name}}."}}}); err != nil {
+			t.Fatalf("UpdateTemplate failed: %v", err)
+		}
+		if messages, err := render("stories/store", map[string]any{"name": "Ann"}); err != nil || messages[1].Content != "Stay kind, Ann. Tell a story." {
+			t.Errorf("Extending template should see the updated partial, got %+v, %v", messages, err)
+		}
+		create("stories/pinned", &Template{Messages: []Message{{Role: "user", Content: `{{template "common/safety@1" .}}`}}})
+		if messages, err := render("stories/pinned", map[string]any{"name": "Ann"}); err != nil || messages[0].Content != "Be safe, Ann." {
+			t.Errorf("Pinned partial = %+v, %v", messages, err)
+		}
+	})
+
+	t.Run("MissingPartial", func(t *testing.T) {
+		create("stories/missing", &Template{Messages: []Message{{Role: "user", Content: `{{template "common/persona"}}`}}})
+		if _, err := render("stories/missing", map[string]any{}); err == nil || !strings.Contains(err.Error(), "failed to load partial 'common/persona' included by template 'stories/missing'") {
+			t.Errorf("err = %v, want missing partial common/persona", err)
+		}
+		create("common/persona", &Template{Messages: []Message{{Role: "system", Content: "A guide."}}})
+		if messages, err := render("stories/missing", map[string]any{}); err != nil || messages[0].Content != "A guide." {
+			t.Errorf("Creating the partial should fix the template, got %+v, %v", messages, err)
+		}
+		create("stories/orphan", &Template{Extends: "common/nothing"})
+		if _, err := render("stories/orphan", map[string]any{}); err == nil || !strings.Contains(err.Error(), "base template 'common/nothing'") {
+			t.Errorf("err = %v, want missing base template", err)
+		}
+	})
+
+	t.Run("Cycles", func(t *testing.T) {
+		create("cycle/a", &Template{Messages: []Message{{Role: "system", Content: `a {{template "cycle/b"}}`}}})
+		create("cycle/b", &Template{Messages: []Message{{Role: "system", Content: `b {{if true}}{{template "cycle/a"}}{{end}}`}}})
+		create("cycle/user", &Template{Messages: []Message{{Role: "user", Content: `{{template "cycle/a"}}`}}})
+		if _, err := render("cycle/user", map[string]any{}); err == nil || !strings.Contains(err.Error(), "partial cycle: cycle/a -> cycle/b -> cycle/a") {
+			t.Errorf("err = %v, want partial cycle", err)
+		}
+
+		create("cycle/x", &Template{Extends: "cycle/y", Messages: []Message{{Role: "user", Content: "x"}}})
+		create("cycle/y", &Template{Extends: "cycle/x", Messages: []Message{{Role: "user", Content: "y"}}})
+		if _, err := render("cycle/x", map[string]any{}); err == nil || !strings.Contains(err.Error(), "inheritance cycle: cycle/x -> cycle/y -> cycle/x") {
+			t.Errorf("err = %v, want inheritance cycle", err)
+		}
+		if err := p.CreateTemplate(ctx, "cycle/self", &Template{Extends: "cycle/self"}); err == nil {
+			t.Error("Template extending itself should be rejected")
+		}
+	})
+}
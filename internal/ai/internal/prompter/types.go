@@ -9,19 +9,27 @@ import (
 	"github.com/Done-0/gin-scaffold/internal/utils/template"
 )
 
+// Prompter loads and manages prompt templates. Every create, update and rollback stores an immutable version,
+// and GetTemplate resolves path@version and path@latest as well as the current path.
 type Prompter interface {
 	GetTemplate(ctx context.Context, path string, vars *map[string]any) (*Template, error)
 	ListTemplates(ctx context.Context, prefix string) ([]string, error)
 	CreateTemplate(ctx context.Context, path string, tmpl *Template) error
 	UpdateTemplate(ctx context.Context, path string, tmpl *Template) error
 	DeleteTemplate(ctx context.Context, path string) error
+	ListVersions(ctx context.Context, path string) ([]TemplateVersion, error)
+	DiffVersions(ctx context.Context, path string, from, to int) (string, error)
+	RollbackTemplate(ctx context.Context, path string, version int) (int, error)
 }
 
 type Template struct {
-	Name        string            `json:"name"`
-	Description string            `json:"description,omitempty"`
-	Variables   map[string]string `json:"variables,omitempty"`
-	Messages    []Message         `json:"messages"`
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Extends     string              `json:"extends,omitempty"`   // Base template path, its messages come before these
+	Variables   map[string]Variable `json:"variables,omitempty"` // Variable schema checked by GetTemplate
+	Messages    []Message           `json:"messages"`
+	CacheTTL    int                 `json:"cache_ttl,omitempty"` // Response cache time to live in seconds, 0=configured TTL
+	Version     int                 `json:"version,omitempty"`   // Version of the template, 0=created before versioning
 }
 
 type Message = template.Message
@@ -0,0 +1,208 @@
+// Package prompter provides dynamic prompt loading and management
+// Author: Done-0
+// Created: 2025-08-31
+package prompter
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Variable types of the template variable schema, an empty type accepts any value
+const (
+	VariableString  = "string"
+	VariableNumber  = "number"
+	VariableInteger = "integer"
+	VariableBoolean = "boolean"
+	VariableArray   = "array"
+	VariableObject  = "object"
+)
+
+// Variable schema of a template variable. A plain JSON string is read as the description of an untyped optional
+// variable, the format of templates written before the schema.
+type Variable struct {
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type,omitempty"`       // string, number, integer, boolean, array or object, empty=any
+	Required    bool   `json:"required,omitempty"`   // Fail when the variable is not passed and has no default
+	Default     any    `json:"default,omitempty"`    // Value used when the variable is not passed
+	Enum        []any  `json:"enum,omitempty"`       // Allowed values, empty=any
+	MaxLength   int    `json:"max_length,omitempty"` // Max characters of a string or items of an array, 0=unlimited
+}
+
+// UnmarshalJSON reads either a variable schema object or a plain description string
+func (v *Variable) UnmarshalJSON(data []byte) error {
+	var description string
+	if err := json.Unmarshal(data, &description); err == nil {
+		*v = Variable{Description: description}
+		return nil
+	}
+	type variable Variable
+	return json.Unmarshal(data, (*variable)(v))
+}
+
+// MarshalJSON writes a variable with only a description as a plain string, so such templates keep their format
+func (v Variable) MarshalJSON() ([]byte, error) {
+	if v.Type == "" && !v.Required && v.Default == nil && len(v.Enum) == 0 && v.MaxLength == 0 {
+		return json.Marshal(v.Description)
+	}
+	type variable Variable
+	return json.Marshal(variable(v))
+}
+
+// VariableError lists the missing and invalid variables passed to a template
+type VariableError struct {
+	Path    string
+	Invalid map[string]string // Variable name to reason
+}
+
+func (e *VariableError) Error() string {
+	problems := make([]string, 0, len(e.Invalid))
+	for _, name := range slices.Sorted(maps.Keys(e.Invalid)) {
+		problems = append(problems, name+" "+e.Invalid[name])
+	}
+	return fmt.Sprintf("invalid variables of template '%s': %s", e.Path, strings.Join(problems, "; "))
+}
+
+// checkVariables validates the variable schema of a template, including its defaults and enum values
+func checkVariables(tmpl *Template) error {
+	for _, name := range slices.Sorted(maps.Keys(tmpl.Variables)) {
+		v := tmpl.Variables[name]
+		switch v.Type {
+		case "", VariableString, VariableNumber, VariableInteger, VariableBoolean, VariableArray, VariableObject:
+		default:
+			return fmt.Errorf("variable '%s' has unknown type '%s'", name, v.Type)
+		}
+		if v.MaxLength < 0 {
+			return fmt.Errorf("variable '%s' has negative max length %d", name, v.MaxLength)
+		}
+		for _, value := range v.Enum {
+			if !hasType(v.Type, value) {
+				return fmt.Errorf("enum value %v of variable '%s' is not of type %s", value, name, v.Type)
+			}
+		}
+		if v.Default != nil {
+			if reason := checkValue(v, v.Default); reason != "" {
+				return fmt.Errorf("default of variable '%s' %s", name, reason)
+			}
+		}
+	}
+	return nil
+}
+
+// prepareVars validates vars against the variable schema of a template and returns them with the defaults filled
+// in. Optional variables without a default are set so templates can test them with {{if}}, an empty string when
+// they are untyped or strings and nil otherwise. vars itself is never modified.
+func prepareVars(path string, tmpl *Template, vars map[string]any) (map[string]any, error) {
+	if len(tmpl.Variables) == 0 {
+		return vars, nil
+	}
+
+	result := make(map[string]any, len(vars)+len(tmpl.Variables))
+	maps.Copy(result, vars)
+	invalid := make(map[string]string)
+	for name, v := range tmpl.Variables {
+		value, ok := vars[name]
+		if !ok || value == nil {
+			switch {
+			case v.Default != nil:
+				result[name] = v.Default
+			case v.Required:
+				invalid[name] = "is required"
+			case v.Type == "" || v.Type == VariableString:
+				result[name] = ""
+			default:
+				result[name] = nil
+			}
+			continue
+		}
+		if reason := checkValue(v, value); reason != "" {
+			invalid[name] = reason
+		}
+	}
+
+	if len(invalid) > 0 {
+		return nil, &VariableError{Path: path, Invalid: invalid}
+	}
+	return result, nil
+}
+
+// checkValue returns why value violates the schema of a variable, or an empty string when it is valid
+func checkValue(v Variable, value any) string {
+	if !hasType(v.Type, value) {
+		return fmt.Sprintf("must be of type %s, got %T", v.Type, value)
+	}
+	if len(v.Enum) > 0 && !slices.ContainsFunc(v.Enum, func(allowed any) bool { return sameValue(allowed, value) }) {
+		return fmt.Sprintf("must be one of %v, got %v", v.Enum, value)
+	}
+	if v.MaxLength > 0 {
+		rv := reflect.ValueOf(value)
+		switch rv.Kind() {
+		case reflect.String:
+			if n := utf8.RuneCountInString(rv.String()); n > v.MaxLength {
+				return fmt.Sprintf("must be at most %d characters, got %d", v.MaxLength, n)
+			}
+		case reflect.Slice, reflect.Array:
+			if rv.Len() > v.MaxLength {
+				return fmt.Sprintf("must have at most %d items, got %d", v.MaxLength, rv.Len())
+			}
+		}
+	}
+	return ""
+}
+
+// hasType reports whether value is of a variable type. Numbers decoded from JSON are float64, so integral floats
+// are integers.
+func hasType(typ string, value any) bool {
+	rv := reflect.ValueOf(value)
+	switch typ {
+	case "":
+		return true
+	case VariableString:
+		return rv.Kind() == reflect.String
+	case VariableBoolean:
+		return rv.Kind() == reflect.Bool
+	case VariableNumber:
+		_, ok := toFloat(rv)
+		return ok
+	case VariableInteger:
+		f, ok := toFloat(rv)
+		return ok && f == math.Trunc(f)
+	case VariableArray:
+		return rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array
+	case VariableObject:
+		return rv.Kind() == reflect.Map || rv.Kind() == reflect.Struct
+	}
+	return false
+}
+
+// sameValue compares an enum value with a passed value, numbers by value and named strings by content
+func sameValue(a, b any) bool {
+	ra, rb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if fa, ok := toFloat(ra); ok {
+		fb, ok := toFloat(rb)
+		return ok && fa == fb
+	}
+	if ra.Kind() == reflect.String && rb.Kind() == reflect.String {
+		return ra.String() == rb.String()
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// toFloat converts any integer or float kind to float64
+func toFloat(rv reflect.Value) (float64, bool) {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,230 @@
+// Package prompter provides dynamic prompt loading and management
+// Author: Done-0
+// Created: 2025-08-31
+package prompter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Done-0/gin-scaffold/internal/utils/file"
+)
+
+// versionsDir directory under the prompts directory holding the immutable versions of every template
+const versionsDir = ".versions"
+
+// VersionLatest path suffix resolving the newest version, e.g. "example@latest"
+const VersionLatest = "latest"
+
+// TemplateVersion immutable snapshot of a template, written on every create, update and rollback
+type TemplateVersion struct {
+	Version   int       `json:"version"`
+	Author    string    `json:"author,omitempty"`
+	Note      string    `json:"note,omitempty"` // Change note
+	CreatedAt time.Time `json:"created_at"`
+	Template  Template  `json:"template"`
+}
+
+type changeKey struct{}
+
+// change author and note of a template write
+type change struct {
+	author string
+	note   string
+}
+
+// WithChange returns a context whose template writes record author and note in the version they create
+func WithChange(ctx context.Context, author, note string) context.Context {
+	return context.WithValue(ctx, changeKey{}, change{author: author, note: note})
+}
+
+// describeChange returns the author and note recorded in ctx, falling back to the default note of the operation
+func describeChange(ctx context.Context, note string) (string, string) {
+	c, _ := ctx.Value(changeKey{}).(change)
+	if c.note != "" {
+		note = c.note
+	}
+	return c.author, note
+}
+
+// splitVersion splits path@version into the template path and version, 0 when the path is unpinned or @latest
+func splitVersion(ref string) (string, int, error) {
+	path, version, pinned := strings.Cut(ref, "@")
+	if !pinned || version == VersionLatest {
+		return path, 0, nil
+	}
+	n, err := strconv.Atoi(version)
+	if err != nil || n < 1 {
+		return "", 0, fmt.Errorf("invalid version '%s' of template '%s', want a positive number or %s", version, path, VersionLatest)
+	}
+	return path, n, nil
+}
+
+// versionDir returns the directory holding the versions of a template
+func versionDir(dir, path string) string {
+	return filepath.Join(dir, versionsDir, path)
+}
+
+// versionNumbers returns the version numbers of a template in ascending order
+func versionNumbers(dir, path string) ([]int, error) {
+	entries, err := os.ReadDir(versionDir(dir, path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read versions of template '%s': %w", path, err)
+	}
+
+	var numbers []int
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".json")); err == nil && strings.HasSuffix(entry.Name(), ".json") {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// loadVersion reads one version of a template, the newest when version is 0
+func loadVersion(dir, path string, version int) (*TemplateVersion, error) {
+	if version == 0 {
+		numbers, err := versionNumbers(dir, path)
+		if err != nil {
+			return nil, err
+		}
+		if len(numbers) == 0 {
+			return nil, fmt.Errorf("template '%s' has no versions", path)
+		}
+		version = numbers[len(numbers)-1]
+	}
+
+	var v TemplateVersion
+	if err := file.LoadJSONFile(filepath.Join(versionDir(dir, path), strconv.Itoa(version)+".json"), &v); err != nil {
+		return nil, fmt.Errorf("failed to load version %d of template '%s': %w", version, path, err)
+	}
+	return &v, nil
+}
+
+// saveVersion writes tmpl as the next version of a template and makes it the live template file
+func saveVersion(ctx context.Context, dir, path string, tmpl *Template, note string) (int, error) {
+	numbers, err := versionNumbers(dir, path)
+	if err != nil {
+		return 0, err
+	}
+	next := 1
+	if len(numbers) > 0 {
+		next = numbers[len(numbers)-1] + 1
+	}
+
+	v := TemplateVersion{Version: next, CreatedAt: time.Now(), Template: *tmpl}
+	v.Author, v.Note = describeChange(ctx, note)
+	v.Template.Version = next
+
+	// Versions are never overwritten
+	versionFile := filepath.Join(versionDir(dir, path), strconv.Itoa(next)+".json")
+	if _, err := os.Stat(versionFile); err == nil {
+		return 0, fmt.Errorf("version %d of template '%s' already exists", next, path)
+	}
+	if err := file.SaveJSONFile(versionFile, &v); err != nil {
+		return 0, fmt.Errorf("failed to save version %d of template '%s': %w", next, path, err)
+	}
+	return next, file.SaveJSONFile(filepath.Join(dir, path+".json"), &v.Template)
+}
+
+// ensureBaseVersion records the live file of a template created before versioning as its first version,
+// so the original can still be rolled back to
+func ensureBaseVersion(dir, path string) error {
+	numbers, err := versionNumbers(dir, path)
+	if err != nil || len(numbers) > 0 {
+		return err
+	}
+
+	var tmpl Template
+	if err := file.LoadJSONFile(filepath.Join(dir, path+".json"), &tmpl); err != nil {
+		return fmt.Errorf("failed to load template '%s': %w", path, err)
+	}
+	_, err = saveVersion(context.Background(), dir, path, &tmpl, "initial version")
+	return err
+}
+
+// removeVersions deletes the versions of a template, and of every template below it when recursive
+func removeVersions(dir, path string, recursive bool) error {
+	if recursive {
+		return os.RemoveAll(versionDir(dir, path))
+	}
+	numbers, err := versionNumbers(dir, path)
+	if err != nil {
+		return err
+	}
+	for _, n := range numbers {
+		if err := os.Remove(filepath.Join(versionDir(dir, path), strconv.Itoa(n)+".json")); err != nil {
+			return fmt.Errorf("failed to delete version %d of template '%s': %w", n, path, err)
+		}
+	}
+	return nil
+}
+
+// diffTemplates returns a line diff of two template versions rendered as indented JSON, prefixing removed lines
+// with "-", added lines with "+" and unchanged lines with a space
+func diffTemplates(path string, from, to *TemplateVersion) (string, error) {
+	render := func(v *TemplateVersion) ([]string, error) {
+		tmpl := v.Template
+		tmpl.Version = 0 // Differs between any two versions
+		data, err := json.MarshalIndent(&tmpl, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return strings.Split(string(data), "\n"), nil
+	}
+	a, err := render(from)
+	if err != nil {
+		return "", err
+	}
+	b, err := render(to)
+	if err != nil {
+		return "", err
+	}
+
+	// Longest common subsequence of lines, lcs[i][j] covers a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s@%d\n+++ %s@%d\n", path, from.Version, path, to.Version)
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			sb.WriteString(" " + a[i] + "\n")
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("-" + a[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+" + b[j] + "\n")
+			j++
+		}
+	}
+	return sb.String(), nil
+}
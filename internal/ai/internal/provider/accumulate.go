@@ -0,0 +1,90 @@
+// Package provider implements AI provider interfaces
+// Author: Done-0
+// Created: 2025-08-31
+package provider
+
+import "sort"
+
+// StreamAccumulator assembles a response from the chunks of a stream
+type StreamAccumulator struct {
+	first     *ChatStreamResponse
+	choices   map[int]*Choice
+	toolCalls map[int]map[int]*ToolCall
+	usage     Usage
+	failed    bool
+}
+
+// Add merges a chunk into the response
+func (c *StreamAccumulator) Add(resp *ChatStreamResponse) {
+	if resp.Err != nil {
+		c.failed = true
+		return
+	}
+	if c.first == nil {
+		c.first = resp
+		c.choices = make(map[int]*Choice)
+		c.toolCalls = make(map[int]map[int]*ToolCall)
+	}
+	if resp.Usage != nil {
+		c.usage = *resp.Usage
+	}
+
+	for _, delta := range resp.Choices {
+		choice, ok := c.choices[delta.Index]
+		if !ok {
+			choice = &Choice{Index: delta.Index, Message: Message{Role: RoleAssistant}}
+			c.choices[delta.Index] = choice
+			c.toolCalls[delta.Index] = make(map[int]*ToolCall)
+		}
+		choice.Message.Content += delta.Delta.Content
+		choice.Message.ReasoningContent += delta.Delta.ReasoningContent
+		choice.Message.ReasoningBlocks = append(choice.Message.ReasoningBlocks, delta.Delta.ReasoningBlocks...)
+		if delta.FinishReason != "" {
+			choice.FinishReason = delta.FinishReason
+		}
+
+		for _, call := range delta.Delta.ToolCalls {
+			merged, ok := c.toolCalls[delta.Index][call.Index]
+			if !ok {
+				merged = &ToolCall{Index: call.Index}
+				c.toolCalls[delta.Index][call.Index] = merged
+			}
+			if call.ID != "" {
+				merged.ID = call.ID
+			}
+			if call.Type != "" {
+				merged.Type = call.Type
+			}
+			merged.Function.Name += call.Function.Name
+			merged.Function.Arguments += call.Function.Arguments
+		}
+	}
+}
+
+// Response returns the assembled response, false when the stream failed or was empty
+func (c *StreamAccumulator) Response() (*ChatResponse, bool) {
+	if c.failed || c.first == nil || len(c.choices) == 0 {
+		return nil, false
+	}
+
+	resp := &ChatResponse{
+		ID:                c.first.ID,
+		Object:            "chat.completion",
+		Created:           c.first.Created,
+		Model:             c.first.Model,
+		Usage:             c.usage,
+		SystemFingerprint: c.first.SystemFingerprint,
+		Provider:          c.first.Provider,
+	}
+	for index, choice := range c.choices {
+		for _, call := range c.toolCalls[index] {
+			choice.Message.ToolCalls = append(choice.Message.ToolCalls, *call)
+		}
+		sort.Slice(choice.Message.ToolCalls, func(i, j int) bool {
+			return choice.Message.ToolCalls[i].Index < choice.Message.ToolCalls[j].Index
+		})
+		resp.Choices = append(resp.Choices, *choice)
+	}
+	sort.Slice(resp.Choices, func(i, j int) bool { return resp.Choices[i].Index < resp.Choices[j].Index })
+	return resp, true
+}
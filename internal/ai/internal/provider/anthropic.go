@@ -0,0 +1,626 @@
+// Package provider implements AI provider interfaces
+// Author: Done-0
+// Created: 2025-08-31
+package provider
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/Done-0/gin-scaffold/configs"
+
+	rateUtil "github.com/Done-0/gin-scaffold/internal/utils/rate"
+)
+
+const (
+	anthropicDefaultBaseURL   = "https://api.anthropic.com"
+	anthropicAPIVersion       = "2023-06-01"
+	anthropicDefaultMaxTokens = 4096 // max_tokens is required by the Messages API
+)
+
+type anthropicProvider struct {
+	config       *configs.ProviderInstanceConfig
+	rateLimiter  *rate.Limiter
+	keyCounter   *uint64
+	modelCounter *uint64
+	httpClient   *http.Client
+}
+
+// Messages API wire types
+type anthropicRequest struct {
+	Model         string               `json:"model"`
+	System        string               `json:"system,omitempty"`
+	Messages      []anthropicMessage   `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens"`
+	Temperature   *float32             `json:"temperature,omitempty"`
+	TopP          *float32             `json:"top_p,omitempty"`
+	TopK          int                  `json:"top_k,omitempty"`
+	StopSequences []string             `json:"stop_sequences,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	Tools         []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice    *anthropicToolChoice `json:"tool_choice,omitempty"`
+	Thinking      *anthropicThinking   `json:"thinking,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string             `json:"role"`
+	Content []anthropicContent `json:"content"`
+}
+
+type anthropicContent struct {
+	Type      string           `json:"type"`
+	Text      string           `json:"text,omitempty"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Signature string           `json:"signature,omitempty"` // Signature of a thinking block, required to send it back
+	Data      string           `json:"data,omitempty"`      // Encrypted reasoning of a redacted_thinking block
+	ID        string           `json:"id,omitempty"`
+	Name      string           `json:"name,omitempty"`
+	Input     json.RawMessage  `json:"input,omitempty"`
+	ToolUseID string           `json:"tool_use_id,omitempty"`
+	Content   string           `json:"content,omitempty"`
+	Source    *anthropicSource `json:"source,omitempty"`
+}
+
+// anthropicSource source of image and document blocks
+type anthropicSource struct {
+	Type      string `json:"type"` // base64 or url
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+type anthropicResponse struct {
+	ID         string             `json:"id"`
+	Model      string             `json:"model"`
+	Content    []anthropicContent `json:"content"`
+	StopReason string             `json:"stop_reason"`
+	Usage      anthropicUsage     `json:"usage"`
+}
+
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+type anthropicStreamEvent struct {
+	Type         string             `json:"type"`
+	Index        int                `json:"index"`
+	Message      *anthropicResponse `json:"message,omitempty"`
+	ContentBlock *anthropicContent  `json:"content_block,omitempty"`
+	Delta        *struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		Signature   string `json:"signature"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta,omitempty"`
+	Usage *anthropicUsage `json:"usage,omitempty"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func NewAnthropic(config *configs.ProviderInstanceConfig, keyCounter *uint64, modelCounter *uint64) (Provider, error) {
+	rateLimit, burst, err := rateUtil.ParseLimit(config.RateLimit)
+	if err != nil {
+		return nil, err
+	}
+	limiter := rate.NewLimiter(rateLimit, burst)
+
+	return &anthropicProvider{
+		config:       config,
+		rateLimiter:  limiter,
+		keyCounter:   keyCounter,
+		modelCounter: modelCounter,
+		httpClient:   newHTTPClient(config.Timeout),
+	}, nil
+}
+
+func (p *anthropicProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	model := req.Model
+	if model == "" {
+		modelIndex := atomic.AddUint64(p.modelCounter, 1) - 1
+		model = p.config.Models[modelIndex%uint64(len(p.config.Models))]
+	}
+	reportModel(ctx, model)
+
+	req, err := fitContext(p.config, model, req)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey, err := nextKey(ctx, p.config, p.keyCounter)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := p.buildRequest(model, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	var httpResp *http.Response
+	err = retry(ctx, p.config, keyRotation(ctx, p.config, p.keyCounter, &apiKey), func(ctx context.Context) (err error) {
+		httpResp, err = postJSON(ctx, p.httpClient, "anthropic", p.endpoint(), p.headers(apiKey), request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	var resp anthropicResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, err
+	}
+
+	message := Message{Role: RoleAssistant}
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			message.Content += block.Text
+		case "thinking":
+			message.ReasoningContent += block.Thinking
+			message.ReasoningBlocks = append(message.ReasoningBlocks, ReasoningBlock{Type: block.Type, Thinking: block.Thinking, Signature: block.Signature})
+		case "redacted_thinking":
+			message.ReasoningBlocks = append(message.ReasoningBlocks, ReasoningBlock{Type: block.Type, Data: block.Data})
+		case "tool_use":
+			arguments := string(block.Input)
+			if arguments == "" {
+				arguments = "{}"
+			}
+			message.ToolCalls = append(message.ToolCalls, ToolCall{
+				Index: len(message.ToolCalls),
+				ID:    block.ID,
+				Type:  ToolTypeFunction,
+				Function: FunctionCall{
+					Name:      block.Name,
+					Arguments: arguments,
+				},
+			})
+		}
+	}
+
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return &ChatResponse{
+		ID:      "chatcmpl-" + hex.EncodeToString(bytes),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   resp.Model,
+		Choices: []Choice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: anthropicFinishReason(resp.StopReason),
+		}},
+		Usage:    resp.Usage.toUsage(),
+		Provider: "anthropic",
+	}, nil
+}
+
+func (p *anthropicProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan *ChatStreamResponse, error) {
+	model := req.Model
+	if model == "" {
+		modelIndex := atomic.AddUint64(p.modelCounter, 1) - 1
+		model = p.config.Models[modelIndex%uint64(len(p.config.Models))]
+	}
+	reportModel(ctx, model)
+
+	req, err := fitContext(p.config, model, req)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey, err := nextKey(ctx, p.config, p.keyCounter)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := p.buildRequest(model, req)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Stream = true
+
+	if err := p.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	var httpResp *http.Response
+	err = retry(ctx, p.config, keyRotation(ctx, p.config, p.keyCounter, &apiKey), func(ctx context.Context) (err error) {
+		httpResp, err = postJSON(ctx, p.httpClient, "anthropic", p.endpoint(), p.headers(apiKey), request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *ChatStreamResponse)
+	go func() {
+		defer close(ch)
+		defer httpResp.Body.Close()
+
+		bytes := make([]byte, 16)
+		rand.Read(bytes)
+		id := "chatcmpl-" + hex.EncodeToString(bytes)
+
+		var usage anthropicUsage
+		toolIndexes := make(map[int]int)                 // content block index -> tool call index
+		reasoningBlocks := make(map[int]*ReasoningBlock) // content block index -> reasoning block being streamed
+
+		scanner := bufio.NewScanner(httpResp.Body)
+		scanner.Buffer(make([]byte, 64<<10), 1<<20)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+
+			var event anthropicStreamEvent
+			if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+				continue
+			}
+
+			var delta MessageDelta
+			var finishReason string
+			var streamUsage *Usage
+
+			switch event.Type {
+			case "message_start":
+				if event.Message != nil {
+					usage = event.Message.Usage
+					model = event.Message.Model
+				}
+				delta.Role = RoleAssistant
+			case "content_block_start":
+				if event.ContentBlock == nil {
+					continue
+				}
+				// Reasoning blocks are sent whole once complete, their signature arrives last
+				if block := event.ContentBlock; block.Type == "thinking" || block.Type == "redacted_thinking" {
+					reasoningBlocks[event.Index] = &ReasoningBlock{Type: block.Type, Thinking: block.Thinking, Signature: block.Signature, Data: block.Data}
+					continue
+				}
+				if event.ContentBlock.Type != "tool_use" {
+					continue
+				}
+				toolIndexes[event.Index] = len(toolIndexes)
+				delta.ToolCalls = []ToolCall{{
+					Index:    toolIndexes[event.Index],
+					ID:       event.ContentBlock.ID,
+					Type:     ToolTypeFunction,
+					Function: FunctionCall{Name: event.ContentBlock.Name},
+				}}
+			case "content_block_delta":
+				if event.Delta == nil {
+					continue
+				}
+				switch event.Delta.Type {
+				case "text_delta":
+					delta.Content = event.Delta.Text
+				case "thinking_delta":
+					delta.ReasoningContent = event.Delta.Thinking
+					if block, ok := reasoningBlocks[event.Index]; ok {
+						block.Thinking += event.Delta.Thinking
+					}
+				case "signature_delta":
+					if block, ok := reasoningBlocks[event.Index]; ok {
+						block.Signature += event.Delta.Signature
+					}
+					continue
+				case "input_json_delta":
+					delta.ToolCalls = []ToolCall{{
+						Index:    toolIndexes[event.Index],
+						Function: FunctionCall{Arguments: event.Delta.PartialJSON},
+					}}
+				default:
+					continue
+				}
+			case "content_block_stop":
+				block, ok := reasoningBlocks[event.Index]
+				if !ok {
+					continue
+				}
+				delete(reasoningBlocks, event.Index)
+				delta.ReasoningBlocks = []ReasoningBlock{*block}
+			case "message_delta":
+				if event.Delta != nil {
+					finishReason = anthropicFinishReason(event.Delta.StopReason)
+				}
+				if event.Usage != nil {
+					usage.OutputTokens = event.Usage.OutputTokens
+				}
+				total := usage.toUsage()
+				streamUsage = &total
+			case "error":
+				message := "stream error"
+				if event.Error != nil {
+					message = event.Error.Type + ": " + event.Error.Message
+				}
+				sendStreamError(ctx, ch, "anthropic", &APIError{Provider: "anthropic", Message: message})
+				return
+			case "message_stop":
+				return
+			default:
+				continue
+			}
+
+			streamResp := &ChatStreamResponse{
+				ID:      id,
+				Object:  "chat.completion.chunk",
+				Created: time.Now().Unix(),
+				Model:   model,
+				Choices: []StreamChoice{{
+					Index:        0,
+					Delta:        delta,
+					FinishReason: finishReason,
+				}},
+				Usage:    streamUsage,
+				Provider: "anthropic",
+			}
+
+			select {
+			case ch <- streamResp:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		// The stream ends with message_stop, reaching the end of the body without it means the stream broke off
+		err := scanner.Err()
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		sendStreamError(ctx, ch, "anthropic", err)
+	}()
+	return ch, nil
+}
+
+// endpoint returns the Messages API URL of the instance
+func (p *anthropicProvider) endpoint() string {
+	baseURL := p.config.BaseURL
+	if baseURL == "" {
+		baseURL = anthropicDefaultBaseURL
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/v1") + "/v1/messages"
+}
+
+func (p *anthropicProvider) headers(apiKey string) map[string]string {
+	return map[string]string{
+		"x-api-key":         apiKey,
+		"anthropic-version": anthropicAPIVersion,
+	}
+}
+
+// buildRequest converts a chat request to a Messages API request
+func (p *anthropicProvider) buildRequest(model string, req *ChatRequest) (*anthropicRequest, error) {
+	if err := req.ResponseFormat.validate(); err != nil {
+		return nil, err
+	}
+	if req.ResponseFormat.isJSON() {
+		return nil, fmt.Errorf("%w: anthropic does not support response format %s", errUnsupported, req.ResponseFormat.Type)
+	}
+
+	request := &anthropicRequest{
+		Model:         model,
+		MaxTokens:     p.config.MaxTokens,
+		TopK:          p.config.TopK,
+		StopSequences: req.Stop,
+	}
+	if req.MaxTokens > 0 {
+		request.MaxTokens = req.MaxTokens
+	}
+	if request.MaxTokens <= 0 {
+		request.MaxTokens = anthropicDefaultMaxTokens
+		// The default leaves room for the answer after the thinking budget
+		if p.config.ThinkingBudget > 0 {
+			request.MaxTokens += p.config.ThinkingBudget
+		}
+	}
+
+	if p.config.ThinkingBudget > 0 {
+		// budget_tokens counts towards max_tokens and must stay below it
+		if p.config.ThinkingBudget >= request.MaxTokens {
+			return nil, fmt.Errorf("%w: thinking budget %d must be less than max tokens %d", errInvalidRequest, p.config.ThinkingBudget, request.MaxTokens)
+		}
+		// Sampling parameters are not allowed together with extended thinking
+		request.Thinking = &anthropicThinking{Type: "enabled", BudgetTokens: p.config.ThinkingBudget}
+		request.TopK = 0
+	} else {
+		if p.config.Temperature != 0 {
+			request.Temperature = &p.config.Temperature
+		}
+		if p.config.TopP != 0 {
+			request.TopP = &p.config.TopP
+		}
+		if req.Temperature != nil {
+			request.Temperature = req.Temperature
+		}
+		if req.TopP != nil {
+			request.TopP = req.TopP
+		}
+		if req.TopK > 0 {
+			request.TopK = req.TopK
+		}
+	}
+
+	var system []string
+	for _, msg := range req.Messages {
+		var role string
+		var blocks []anthropicContent
+
+		switch msg.Role {
+		case RoleSystem:
+			texts, err := msg.systemTexts("anthropic")
+			if err != nil {
+				return nil, err
+			}
+			system = append(system, texts...)
+			continue
+		case RoleTool:
+			role = RoleUser
+			blocks = append(blocks, anthropicContent{
+				Type:      "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content:   msg.Content,
+			})
+		case RoleAssistant:
+			role = RoleAssistant
+			// With extended thinking the signed reasoning blocks must come back first and unchanged, or tool use
+			// turns are rejected
+			if request.Thinking != nil {
+				for _, block := range msg.ReasoningBlocks {
+					blocks = append(blocks, anthropicContent{Type: block.Type, Thinking: block.Thinking, Signature: block.Signature, Data: block.Data})
+				}
+			}
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicContent{Type: "text", Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				input := json.RawMessage(call.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, anthropicContent{
+					Type:  "tool_use",
+					ID:    call.ID,
+					Name:  call.Function.Name,
+					Input: input,
+				})
+			}
+		default:
+			role = RoleUser
+			userBlocks, err := toAnthropicBlocks(msg)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, userBlocks...)
+		}
+
+		// The Messages API requires alternating roles, so consecutive turns of one role are merged
+		if last := len(request.Messages) - 1; last >= 0 && request.Messages[last].Role == role {
+			request.Messages[last].Content = append(request.Messages[last].Content, blocks...)
+			continue
+		}
+		request.Messages = append(request.Messages, anthropicMessage{Role: role, Content: blocks})
+	}
+	request.System = strings.Join(system, "\n\n")
+
+	for _, tool := range req.Tools {
+		schema := tool.Function.Parameters
+		if schema == nil {
+			schema = map[string]any{"type": "object"}
+		}
+		request.Tools = append(request.Tools, anthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
+	}
+
+	if req.ToolChoice != nil {
+		switch req.ToolChoice.Type {
+		case ToolChoiceNone:
+			request.ToolChoice = &anthropicToolChoice{Type: "none"}
+		case ToolChoiceRequired:
+			request.ToolChoice = &anthropicToolChoice{Type: "any"}
+		case ToolChoiceFunction:
+			request.ToolChoice = &anthropicToolChoice{Type: "tool", Name: req.ToolChoice.Function}
+		default:
+			request.ToolChoice = &anthropicToolChoice{Type: "auto"}
+		}
+	}
+
+	return request, nil
+}
+
+// toAnthropicBlocks converts message content to text, image and document blocks
+func toAnthropicBlocks(msg Message) ([]anthropicContent, error) {
+	parts := msg.contentParts()
+	if parts == nil {
+		return []anthropicContent{{Type: "text", Text: msg.Content}}, nil
+	}
+
+	blocks := make([]anthropicContent, 0, len(parts))
+	for _, part := range parts {
+		if part.Type == PartTypeText {
+			blocks = append(blocks, anthropicContent{Type: "text", Text: part.Text})
+			continue
+		}
+
+		blockType := "image"
+		if !part.isImage() {
+			if part.mimeType() != "application/pdf" && !strings.HasPrefix(part.mimeType(), "text/") {
+				return nil, unsupportedPartError("anthropic", part)
+			}
+			blockType = "document"
+		}
+
+		source := &anthropicSource{Type: "url", URL: part.URL}
+		if mimeType, data, ok := part.inline(); ok {
+			source = &anthropicSource{Type: "base64", MediaType: mimeType, Data: data}
+		}
+		blocks = append(blocks, anthropicContent{Type: blockType, Source: source})
+	}
+	return blocks, nil
+}
+
+// toUsage converts Messages API usage, counting cached input as prompt tokens
+func (u anthropicUsage) toUsage() Usage {
+	prompt := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	return Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      prompt + u.OutputTokens,
+	}
+}
+
+// anthropicFinishReason maps Messages API stop reasons to OpenAI finish reasons
+func anthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	default:
+		return stopReason
+	}
+}
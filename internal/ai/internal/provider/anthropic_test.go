@@ -0,0 +1,314 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/Done-0/gin-scaffold/configs"
+)
+
+// newMockAnthropicServer starts a Messages API server that captures the decoded request
+func newMockAnthropicServer(t *testing.T, handler func(w http.ResponseWriter, body map[string]any)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`)
+			return
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		handler(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newMockAnthropicConfig(baseURL string) *configs.ProviderInstanceConfig {
+	return &configs.ProviderInstanceConfig{
+		Enabled:        true,
+		Name:           "mock",
+		BaseURL:        baseURL,
+		Keys:           []string{"test-key"},
+		Models:         []string{"claude-test"},
+		Timeout:        5,
+		RateLimit:      "100/s",
+		ThinkingBudget: 1024,
+	}
+}
+
+func TestAnthropicChat(t *testing.T) {
+	var captured map[string]any
+	server := newMockAnthropicServer(t, func(w http.ResponseWriter, body map[string]any) {
+		captured = body
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-test",
+			"content": [
+				{"type": "thinking", "thinking": "Need the weather tool.", "signature": "sig"},
+				{"type": "redacted_thinking", "data": "encrypted"},
+				{"type": "thinking", "thinking": " Paris it is.", "signature": "sig2"},
+				{"type": "text", "text": "Let me check."},
+				{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": {"city": "Paris"}}
+			],
+			"stop_reason": "tool_use",
+			"usage": {"input_tokens": 20, "output_tokens": 8, "cache_read_input_tokens": 5}
+		}`)
+	})
+
+	p, err := NewAnthropic(newMockAnthropicConfig(server.URL), new(uint64), new(uint64))
+	if err != nil {
+		t.Fatalf("NewAnthropic() failed: %v", err)
+	}
+
+	resp, err := p.Chat(context.Background(), &ChatRequest{
+		Messages: []Message{
+			{Role: RoleSystem, Content: "You are a weather bot."},
+			{Role: RoleUser, Content: "Weather in Rome?"},
+			{Role: RoleAssistant, ReasoningContent: "Use the tool.", ReasoningBlocks: []ReasoningBlock{
+				{Type: "thinking", Thinking: "Use the tool.", Signature: "sig0"},
+				{Type: "redacted_thinking", Data: "encrypted0"},
+			}, ToolCalls: []ToolCall{{ID: "toolu_0", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Rome"}`}}}},
+			{Role: RoleTool, ToolCallID: "toolu_0", Content: "sunny"},
+			{Role: RoleUser, Content: "And Paris?"},
+		},
+		Tools: []Tool{weatherTool},
+	})
+	if err != nil {
+		t.Fatalf("Chat() failed: %v", err)
+	}
+
+	if captured["system"] != "You are a weather bot." {
+		t.Errorf("system = %v, want system prompt", captured["system"])
+	}
+	if thinking, _ := captured["thinking"].(map[string]any); thinking["budget_tokens"] != float64(1024) {
+		t.Errorf("thinking = %v, want budget 1024", captured["thinking"])
+	}
+	if captured["max_tokens"] != float64(anthropicDefaultMaxTokens+1024) {
+		t.Errorf("max_tokens = %v, want the default plus the thinking budget", captured["max_tokens"])
+	}
+	if _, exists := captured["temperature"]; exists {
+		t.Error("temperature must not be sent with extended thinking")
+	}
+	messages, _ := captured["messages"].([]any)
+	if len(messages) != 3 {
+		t.Fatalf("messages = %d, want 3 (tool result merged with the following user turn)", len(messages))
+	}
+	assistantTurn, _ := messages[1].(map[string]any)
+	if blocks, _ := assistantTurn["content"].([]any); len(blocks) != 3 ||
+		blocks[0].(map[string]any)["type"] != "thinking" || blocks[0].(map[string]any)["signature"] != "sig0" ||
+		blocks[1].(map[string]any)["type"] != "redacted_thinking" || blocks[1].(map[string]any)["data"] != "encrypted0" ||
+		blocks[2].(map[string]any)["type"] != "tool_use" {
+		t.Errorf("assistant turn = %v, want the reasoning blocks in order before tool_use", assistantTurn)
+	}
+	lastTurn, _ := messages[2].(map[string]any)
+	if blocks, _ := lastTurn["content"].([]any); len(blocks) != 2 || blocks[0].(map[string]any)["type"] != "tool_result" {
+		t.Errorf("last turn = %v, want tool_result followed by text", lastTurn)
+	}
+	if tools, _ := captured["tools"].([]any); len(tools) != 1 || tools[0].(map[string]any)["input_schema"] == nil {
+		t.Errorf("tools = %v, want one tool with input_schema", captured["tools"])
+	}
+
+	msg := resp.Choices[0].Message
+	if msg.Content != "Let me check." || msg.ReasoningContent != "Need the weather tool. Paris it is." {
+		t.Errorf("message = %+v", msg)
+	}
+	wantBlocks := []ReasoningBlock{
+		{Type: "thinking", Thinking: "Need the weather tool.", Signature: "sig"},
+		{Type: "redacted_thinking", Data: "encrypted"},
+		{Type: "thinking", Thinking: " Paris it is.", Signature: "sig2"},
+	}
+	if !slices.Equal(msg.ReasoningBlocks, wantBlocks) {
+		t.Errorf("reasoning blocks = %+v, want %+v", msg.ReasoningBlocks, wantBlocks)
+	}
+	if len(msg.ToolCalls) != 1 || msg.ToolCalls[0].ID != "toolu_1" || msg.ToolCalls[0].Function.Arguments != `{"city": "Paris"}` {
+		t.Errorf("tool calls = %+v", msg.ToolCalls)
+	}
+	if resp.Choices[0].FinishReason != "tool_calls" {
+		t.Errorf("finish reason = %s, want tool_calls", resp.Choices[0].FinishReason)
+	}
+	if resp.Usage.PromptTokens != 25 || resp.Usage.CompletionTokens != 8 || resp.Usage.TotalTokens != 33 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestAnthropicChatStream(t *testing.T) {
+	server := newMockAnthropicServer(t, func(w http.ResponseWriter, body map[string]any) {
+		if body["stream"] != true {
+			t.Error("stream flag not set")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"type":"message_start","message":{"id":"msg_1","model":"claude-test","content":[],"usage":{"input_tokens":12,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Thinking..."}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"ping"}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"redacted_thinking","data":"encrypted"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"content_block_start","index":2,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"Hello"}}`,
+			`{"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":" world"}}`,
+			`{"type":"content_block_start","index":3,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+			`{"type":"content_block_delta","index":3,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+			`{"type":"content_block_delta","index":3,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":30}}`,
+			`{"type":"message_stop"}`,
+		}
+		for _, event := range events {
+			var typed struct {
+				Type string `json:"type"`
+			}
+			json.Unmarshal([]byte(event), &typed)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typed.Type, event)
+		}
+	})
+
+	p, err := NewAnthropic(newMockAnthropicConfig(server.URL), new(uint64), new(uint64))
+	if err != nil {
+		t.Fatalf("NewAnthropic() failed: %v", err)
+	}
+
+	stream, err := p.ChatStream(context.Background(), &ChatRequest{
+		Messages: []Message{{Role: RoleUser, Content: "Hi"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatStream() failed: %v", err)
+	}
+
+	var content, reasoning, toolID, arguments, finishReason string
+	var usage *Usage
+	var acc StreamAccumulator
+	for resp := range stream {
+		acc.Add(resp)
+		delta := resp.Choices[0].Delta
+		content += delta.Content
+		reasoning += delta.ReasoningContent
+		for _, call := range delta.ToolCalls {
+			if call.ID != "" {
+				toolID = call.ID
+			}
+			arguments += call.Function.Arguments
+		}
+		if resp.Choices[0].FinishReason != "" {
+			finishReason = resp.Choices[0].FinishReason
+		}
+		if resp.Usage != nil {
+			usage = resp.Usage
+		}
+	}
+
+	if content != "Hello world" || reasoning != "Thinking..." {
+		t.Errorf("content = %q, reasoning = %q", content, reasoning)
+	}
+	if toolID != "toolu_1" || arguments != `{"city":"Paris"}` {
+		t.Errorf("tool call id = %s, arguments = %s", toolID, arguments)
+	}
+	if finishReason != "tool_calls" {
+		t.Errorf("finish reason = %s, want tool_calls", finishReason)
+	}
+	if usage == nil || usage.PromptTokens != 12 || usage.CompletionTokens != 30 || usage.TotalTokens != 42 {
+		t.Errorf("usage = %+v", usage)
+	}
+	wantBlocks := []ReasoningBlock{{Type: "thinking", Thinking: "Thinking...", Signature: "sig"}, {Type: "redacted_thinking", Data: "encrypted"}}
+	if resp, _ := acc.Response(); resp == nil || !slices.Equal(resp.Choices[0].Message.ReasoningBlocks, wantBlocks) {
+		t.Errorf("accumulated response = %+v, want reasoning blocks %+v", resp, wantBlocks)
+	}
+}
+
+func TestAnthropicThinkingBudget(t *testing.T) {
+	server := newMockAnthropicServer(t, func(w http.ResponseWriter, body map[string]any) {
+		t.Error("request with an invalid thinking budget must not be sent")
+	})
+
+	p, err := NewAnthropic(newMockAnthropicConfig(server.URL), new(uint64), new(uint64))
+	if err != nil {
+		t.Fatalf("NewAnthropic() failed: %v", err)
+	}
+
+	_, err = p.Chat(context.Background(), &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Hi"}}, MaxTokens: 1024})
+	if !errors.Is(err, errInvalidRequest) {
+		t.Errorf("Chat() error = %v, want errInvalidRequest", err)
+	}
+}
+
+func TestAnthropicError(t *testing.T) {
+	server := newMockAnthropicServer(t, nil)
+
+	config := newMockAnthropicConfig(server.URL)
+	config.Keys = []string{"wrong-key"}
+	p, err := NewAnthropic(config, new(uint64), new(uint64))
+	if err != nil {
+		t.Fatalf("NewAnthropic() failed: %v", err)
+	}
+
+	_, err = p.Chat(context.Background(), &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Hi"}}})
+	if status := httpStatus(err); status != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401 (err: %v)", status, err)
+	}
+	if apiErr := err.(*APIError); apiErr.Message != "invalid x-api-key" {
+		t.Errorf("message = %q, want parsed error message", apiErr.Message)
+	}
+}
+
+func TestAnthropicContentBlocks(t *testing.T) {
+	blocks, err := toAnthropicBlocks(Message{
+		Role:    RoleUser,
+		Content: "Summarize",
+		Parts: []ContentPart{
+			{Type: PartTypeImageURL, URL: "data:image/webp;base64,aGVsbG8="},
+			{Type: PartTypeFile, URL: "https://example.com/report.pdf"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("toAnthropicBlocks() failed: %v", err)
+	}
+	if len(blocks) != 3 || blocks[0].Text != "Summarize" {
+		t.Fatalf("blocks = %+v", blocks)
+	}
+	if source := blocks[1].Source; blocks[1].Type != "image" || source.Type != "base64" || source.MediaType != "image/webp" || source.Data != "aGVsbG8=" {
+		t.Errorf("image block = %+v %+v", blocks[1], source)
+	}
+	if source := blocks[2].Source; blocks[2].Type != "document" || source.Type != "url" || source.URL != "https://example.com/report.pdf" {
+		t.Errorf("document block = %+v %+v", blocks[2], source)
+	}
+
+	if _, err := toAnthropicBlocks(Message{Parts: []ContentPart{{Type: PartTypeFile, Filename: "data.xlsx", Data: "aGVsbG8="}}}); err == nil {
+		t.Error("toAnthropicBlocks() should reject unsupported document types")
+	}
+}
+
+func TestAnthropicSystemParts(t *testing.T) {
+	p := &anthropicProvider{config: &configs.ProviderInstanceConfig{}}
+
+	request, err := p.buildRequest("claude-test", &ChatRequest{Messages: []Message{
+		{Role: RoleSystem, Content: "Be brief.", Parts: []ContentPart{{Type: PartTypeText, Text: "Answer in French."}}},
+		{Role: RoleUser, Content: "Hi"},
+	}})
+	if err != nil {
+		t.Fatalf("buildRequest() failed: %v", err)
+	}
+	if request.System != "Be brief.\n\nAnswer in French." {
+		t.Errorf("system = %q, want the content and text parts", request.System)
+	}
+
+	if _, err := p.buildRequest("claude-test", &ChatRequest{Messages: []Message{
+		{Role: RoleSystem, Parts: []ContentPart{{Type: PartTypeFile, URL: "https://example.com/report.pdf"}}},
+	}}); !errors.Is(err, errInvalidRequest) {
+		t.Errorf("buildRequest() with a file in a system message = %v, want errInvalidRequest", err)
+	}
+}
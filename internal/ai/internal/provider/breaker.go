@@ -0,0 +1,109 @@
+// Package provider implements AI provider interfaces
+// Author: Done-0
+// Created: 2025-08-31
+package provider
+
+import (
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"    // Instance is healthy and receives traffic
+	BreakerOpen     = "open"      // Instance is tripped and skipped until cooldown expires
+	BreakerHalfOpen = "half_open" // Instance is being probed by a single request
+)
+
+// Circuit breaker defaults, used when not configured
+const (
+	defaultFailureThreshold = 5
+	defaultCooldown         = 30 * time.Second
+)
+
+// BreakerState snapshot of an instance circuit breaker
+type BreakerState struct {
+	Provider            string     `json:"provider"`
+	Instance            string     `json:"instance"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"` // Nil while the breaker is closed
+}
+
+type breaker struct {
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+func newBreaker() *breaker {
+	return &breaker{state: BreakerClosed}
+}
+
+// allow reports whether a request may be sent, moving an open breaker to half-open after cooldown
+func (b *breaker) allow(cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		return true
+	case BreakerHalfOpen:
+		// Only one probe at a time, the probe result decides the next state
+		return false
+	default:
+		return true
+	}
+}
+
+// success closes the breaker and returns the previous state
+func (b *breaker) success() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	prev := b.state
+	b.state = BreakerClosed
+	b.failures = 0
+	b.openedAt = time.Time{}
+	return prev
+}
+
+// failure records a failure and returns the new state
+func (b *breaker) failure(threshold int) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= threshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+	return b.state
+}
+
+// release returns a half-open breaker to open when its probe ends without a verdict,
+// keeping the original open time so the next request probes again immediately
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.state = BreakerOpen
+	}
+}
+
+func (b *breaker) snapshot() (state string, failures int, openedAt *time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.openedAt.IsZero() {
+		at := b.openedAt
+		openedAt = &at
+	}
+	return b.state, b.failures, openedAt
+}
@@ -0,0 +1,73 @@
+// Package provider implements AI provider interfaces
+// Author: Done-0
+// Created: 2025-08-31
+package provider
+
+import (
+	"context"
+	"time"
+)
+
+// Call kinds
+const (
+	CallChat       = "chat"
+	CallChatStream = "chat_stream"
+	CallEmbed      = "embed"
+)
+
+// Call one attempt of a request against a provider instance, reported to observers when it finishes
+type Call struct {
+	Kind     string        // chat, chat_stream or embed
+	Provider string        // Provider type, e.g. "openai"
+	Instance string        // Instance name
+	Model    string        // Model requested from the provider
+	KeyIndex int           // Position of the API key in the instance KEYS, -1 when no key was used
+	Usage    Usage         // Token usage, zero when the call failed or the provider did not report it
+	Latency  time.Duration // Until the response, or until the end of the stream
+	Err      error         // Nil when the call succeeded
+}
+
+// CallObserver is notified of every finished call, it must not block
+type CallObserver func(ctx context.Context, call *Call)
+
+type callInfoKey struct{}
+
+// callInfo collects what a provider picked for a call, read back by the pool when the call finishes
+type callInfo struct {
+	model    string
+	keyIndex int
+	keys     *keyHealth // Health of the instance API keys, skipped and updated by the provider
+}
+
+// withCallInfo returns a context the provider reports its picks into
+func withCallInfo(ctx context.Context, keys *keyHealth) (context.Context, *callInfo) {
+	info := &callInfo{keyIndex: -1, keys: keys}
+	return context.WithValue(ctx, callInfoKey{}, info), info
+}
+
+// call builds the call report of a finished attempt
+func (info *callInfo) call(kind string, selected providerInstance, start time.Time, err error) *Call {
+	return &Call{
+		Kind:     kind,
+		Provider: selected.name,
+		Instance: selected.instance.Name,
+		Model:    info.model,
+		KeyIndex: info.keyIndex,
+		Latency:  time.Since(start),
+		Err:      err,
+	}
+}
+
+// reportModel records the model a provider picked
+func reportModel(ctx context.Context, model string) {
+	if info, ok := ctx.Value(callInfoKey{}).(*callInfo); ok {
+		info.model = model
+	}
+}
+
+// reportKey records the position of the API key a provider picked
+func reportKey(ctx context.Context, keyIndex uint64, keys int) {
+	if info, ok := ctx.Value(callInfoKey{}).(*callInfo); ok {
+		info.keyIndex = int(keyIndex % uint64(keys))
+	}
+}
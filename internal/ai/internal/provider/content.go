@@ -0,0 +1,118 @@
+// Package provider implements AI provider interfaces
+// Author: Done-0
+// Created: 2025-08-31
+package provider
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// contentParts returns the multimodal parts of a message, with Content as the leading text part,
+// or nil when the message is plain text
+func (m Message) contentParts() []ContentPart {
+	if len(m.Parts) == 0 {
+		return nil
+	}
+
+	parts := make([]ContentPart, 0, len(m.Parts)+1)
+	if m.Content != "" {
+		parts = append(parts, ContentPart{Type: PartTypeText, Text: m.Content})
+	}
+	return append(parts, m.Parts...)
+}
+
+// inline returns the MIME type and base64 data of an inline part, including data URLs
+func (part ContentPart) inline() (mimeType, data string, ok bool) {
+	if part.Data != "" {
+		return part.mimeType(), part.Data, true
+	}
+	return parseDataURL(part.URL)
+}
+
+// dataURL returns the part as a URL, encoding inline data as a data URL
+func (part ContentPart) dataURL() string {
+	if part.Data == "" {
+		return part.URL
+	}
+	return "data:" + part.mimeType() + ";base64," + part.Data
+}
+
+// isImage reports whether a part carries an image
+func (part ContentPart) isImage() bool {
+	switch part.Type {
+	case PartTypeImage, PartTypeImageURL:
+		return true
+	case PartTypeFile:
+		return strings.HasPrefix(part.mimeType(), "image/")
+	default:
+		return false
+	}
+}
+
+// mimeType returns the declared MIME type, guessing it from the data URL or file extension when missing
+func (part ContentPart) mimeType() string {
+	if part.MIMEType != "" {
+		return part.MIMEType
+	}
+	if mimeType, _, ok := parseDataURL(part.URL); ok {
+		return mimeType
+	}
+
+	name := part.Filename
+	if name == "" {
+		if u, err := url.Parse(part.URL); err == nil {
+			name = u.Path
+		}
+	}
+	if mimeType := mime.TypeByExtension(path.Ext(name)); mimeType != "" {
+		mimeType, _, _ = strings.Cut(mimeType, ";")
+		return mimeType
+	}
+
+	if part.Type == PartTypeFile {
+		return "application/octet-stream"
+	}
+	return "image/jpeg"
+}
+
+// parseDataURL splits a base64 data URL into its MIME type and data
+func parseDataURL(dataURL string) (mimeType, data string, ok bool) {
+	rest, found := strings.CutPrefix(dataURL, "data:")
+	if !found {
+		return "", "", false
+	}
+	meta, data, found := strings.Cut(rest, ",")
+	if !found {
+		return "", "", false
+	}
+	mimeType, found = strings.CutSuffix(meta, ";base64")
+	if !found {
+		return "", "", false
+	}
+	return mimeType, data, true
+}
+
+// systemTexts returns the texts of a system message, Content first. Providers take the system prompt as text, so
+// other parts are rejected.
+func (m Message) systemTexts(providerName string) ([]string, error) {
+	var texts []string
+	if m.Content != "" {
+		texts = append(texts, m.Content)
+	}
+	for _, part := range m.Parts {
+		if part.Type != PartTypeText {
+			return nil, fmt.Errorf("%w: %s system messages accept only text content parts, got %s", errInvalidRequest, providerName, part.Type)
+		}
+		texts = append(texts, part.Text)
+	}
+	return texts, nil
+}
+
+// unsupportedPartError reports a content part a provider cannot send
+func unsupportedPartError(providerName string, part ContentPart) error {
+	return fmt.Errorf("%w: %s does not accept %s content parts of type %s", errUnsupported, providerName, part.Type, part.mimeType())
+}
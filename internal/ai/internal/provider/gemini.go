@@ -6,7 +6,13 @@ package provider
 import (
 	"context"
 	"crypto/rand"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -23,6 +29,9 @@ type geminiProvider struct {
 	rateLimiter  *rate.Limiter
 	keyCounter   *uint64
 	modelCounter *uint64
+	httpClient   *http.Client             // Shared by all keys of the instance
+	clients      map[string]*genai.Client // key: API key, value: client
+	mu           sync.Mutex               // Guards clients
 }
 
 func NewGemini(config *configs.ProviderInstanceConfig, keyCounter *uint64, modelCounter *uint64) (Provider, error) {
@@ -37,80 +46,87 @@ func NewGemini(config *configs.ProviderInstanceConfig, keyCounter *uint64, model
 		rateLimiter:  limiter,
 		keyCounter:   keyCounter,
 		modelCounter: modelCounter,
+		httpClient:   newHTTPClient(config.Timeout),
+		clients:      make(map[string]*genai.Client),
 	}, nil
 }
 
+// client returns the cached client for an API key
+func (p *geminiProvider) client(ctx context.Context, apiKey string) (*genai.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if client, exists := p.clients[apiKey]; exists {
+		return client, nil
+	}
+
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:      apiKey,
+		Backend:     genai.BackendGeminiAPI,
+		HTTPClient:  p.httpClient,
+		HTTPOptions: genai.HTTPOptions{BaseURL: p.config.BaseURL}, // Empty uses the official endpoint
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.clients[apiKey] = client
+	return client, nil
+}
+
 func (p *geminiProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
 	model := req.Model
 	if model == "" {
 		modelIndex := atomic.AddUint64(p.modelCounter, 1) - 1
 		model = p.config.Models[modelIndex%uint64(len(p.config.Models))]
 	}
+	reportModel(ctx, model)
 
-	keyIndex := atomic.AddUint64(p.keyCounter, 1) - 1
-	apiKey := p.config.Keys[keyIndex%uint64(len(p.config.Keys))]
-
-	if err := p.rateLimiter.Wait(ctx); err != nil {
+	req, err := fitContext(p.config, model, req)
+	if err != nil {
 		return nil, err
 	}
 
-	clientConfig := &genai.ClientConfig{
-		APIKey:  apiKey,
-		Backend: genai.BackendGeminiAPI,
+	apiKey, err := nextKey(ctx, p.config, p.keyCounter)
+	if err != nil {
+		return nil, err
 	}
 
-	client, err := genai.NewClient(ctx, clientConfig)
+	contents, err := toGeminiContents(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.ResponseFormat.validate(); err != nil {
+		return nil, err
+	}
+	config, err := p.buildConfig(req)
 	if err != nil {
 		return nil, err
 	}
 
-	var prompt string
-	for _, msg := range req.Messages {
-		prompt += msg.Content + "\n"
+	if err := p.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
 	}
 
 	var resp *genai.GenerateContentResponse
-	for attempt := 0; attempt <= p.config.MaxRetries; attempt++ {
-		resp, err = client.Models.GenerateContent(
-			ctx,
-			model,
-			genai.Text(prompt),
-			&genai.GenerateContentConfig{
-				Temperature:     &p.config.Temperature,
-				MaxOutputTokens: int32(p.config.MaxTokens),
-				TopP:            &p.config.TopP,
-				TopK:            func() *float32 { v := float32(p.config.TopK); return &v }(),
-				ThinkingConfig: &genai.ThinkingConfig{
-					IncludeThoughts: true,
-				},
-			},
-		)
-		if err == nil {
-			break
+	err = retry(ctx, p.config, keyRotation(ctx, p.config, p.keyCounter, &apiKey), func(ctx context.Context) error {
+		client, err := p.client(ctx, apiKey)
+		if err != nil {
+			return err
 		}
-		if attempt < p.config.MaxRetries {
-			time.Sleep(time.Duration(attempt+1) * time.Second)
+		resp, err = client.Models.GenerateContent(ctx, model, contents, config)
+		if err == nil && len(resp.Candidates) == 0 {
+			err = geminiNoCandidatesError(resp)
 		}
-	}
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	var content, reasoningContent string
-	if len(resp.Candidates) > 0 {
-		candidate := resp.Candidates[0]
-		if candidate.Content != nil {
-			for _, part := range candidate.Content.Parts {
-				if len(part.Text) == 0 {
-					continue
-				}
-				if part.Thought {
-					reasoningContent += part.Text
-				} else {
-					content += part.Text
-				}
-			}
-		}
+	var toolCalls []ToolCall
+	if resp.Candidates[0].Content != nil {
+		content, reasoningContent, toolCalls = fromGeminiParts(resp.Candidates[0].Content.Parts, 0)
 	}
 
 	now := time.Now()
@@ -127,6 +143,7 @@ func (p *geminiProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatRespo
 				Role:             "assistant",
 				Content:          content,
 				ReasoningContent: reasoningContent,
+				ToolCalls:        toolCalls,
 			},
 			FinishReason: string(resp.Candidates[0].FinishReason),
 		}},
@@ -134,12 +151,8 @@ func (p *geminiProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatRespo
 		Provider:          "gemini",
 	}
 
-	if resp.Candidates[0].FinishReason != "" {
-		chatResp.Usage = Usage{
-			PromptTokens:     int(resp.UsageMetadata.PromptTokenCount),
-			CompletionTokens: int(resp.UsageMetadata.CandidatesTokenCount),
-			TotalTokens:      int(resp.UsageMetadata.TotalTokenCount),
-		}
+	if usage := fromGeminiUsage(resp.UsageMetadata); usage != nil {
+		chatResp.Usage = *usage
 	}
 
 	return chatResp, nil
@@ -151,125 +164,121 @@ func (p *geminiProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-ch
 		modelIndex := atomic.AddUint64(p.modelCounter, 1) - 1
 		model = p.config.Models[modelIndex%uint64(len(p.config.Models))]
 	}
+	reportModel(ctx, model)
 
-	keyIndex := atomic.AddUint64(p.keyCounter, 1) - 1
-	apiKey := p.config.Keys[keyIndex%uint64(len(p.config.Keys))]
+	req, err := fitContext(p.config, model, req)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := p.rateLimiter.Wait(ctx); err != nil {
+	apiKey, err := nextKey(ctx, p.config, p.keyCounter)
+	if err != nil {
 		return nil, err
 	}
 
-	clientConfig := &genai.ClientConfig{
-		APIKey:  apiKey,
-		Backend: genai.BackendGeminiAPI,
+	contents, err := toGeminiContents(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.ResponseFormat.validate(); err != nil {
+		return nil, err
+	}
+	config, err := p.buildConfig(req)
+	if err != nil {
+		return nil, err
 	}
 
-	contents := make([]*genai.Content, len(req.Messages))
-	for i, msg := range req.Messages {
-		role := genai.RoleUser
-		if msg.Role == "assistant" {
-			role = genai.RoleModel
-		}
-		contents[i] = &genai.Content{
-			Parts: []*genai.Part{{Text: msg.Content}},
-			Role:  role,
-		}
+	if err := p.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
 	}
 
-	var client *genai.Client
-	var err error
-	for attempt := 0; attempt <= p.config.MaxRetries; attempt++ {
-		client, err = genai.NewClient(ctx, clientConfig)
-		if err == nil {
-			break
+	// The stream sends its request on the first pull, so the first chunk is part of the retried attempt and
+	// failures such as 401 and 429 rotate keys and back off like any other call
+	var next func() (*genai.GenerateContentResponse, error, bool)
+	var stop func()
+	var first *genai.GenerateContentResponse
+	var firstOK bool
+	err = retry(ctx, p.config, keyRotation(ctx, p.config, p.keyCounter, &apiKey), func(ctx context.Context) error {
+		client, err := p.client(ctx, apiKey)
+		if err != nil {
+			return err
 		}
-		if attempt < p.config.MaxRetries {
-			time.Sleep(time.Duration(attempt+1) * time.Second)
+		next, stop = iter.Pull2(client.Models.GenerateContentStream(ctx, model, contents, config))
+		first, err, firstOK = next()
+		if err != nil {
+			stop()
 		}
-	}
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	stream := client.Models.GenerateContentStream(
-		ctx,
-		model,
-		contents,
-		&genai.GenerateContentConfig{
-			Temperature:     &p.config.Temperature,
-			MaxOutputTokens: int32(p.config.MaxTokens),
-			TopP:            &p.config.TopP,
-			TopK:            func() *float32 { v := float32(p.config.TopK); return &v }(),
-			ThinkingConfig: &genai.ThinkingConfig{
-				IncludeThoughts: true,
-			},
-		},
-	)
-
 	ch := make(chan *ChatStreamResponse)
 	go func() {
 		defer close(ch)
+		defer stop()
 
-		if stream == nil {
-			return
-		}
-
-		for chunk := range stream {
+		toolCallIndex := 0
+		for chunk, err, ok := first, error(nil), firstOK; ok; chunk, err, ok = next() {
+			if err != nil {
+				sendStreamError(ctx, ch, "gemini", err)
+				return
+			}
 			if chunk == nil {
 				continue
 			}
 
-			if len(chunk.Candidates) == 0 {
-				continue
-			}
+			now := time.Now()
 
-			candidate := chunk.Candidates[0]
-			if candidate.Content == nil || len(candidate.Content.Parts) == 0 {
-				continue
+			bytes := make([]byte, 16)
+			rand.Read(bytes)
+			streamResp := &ChatStreamResponse{
+				ID:                "chatcmpl-" + hex.EncodeToString(bytes),
+				Object:            "chat.completion.chunk",
+				Created:           now.Unix(),
+				Model:             model,
+				SystemFingerprint: "",
+				Provider:          "gemini",
 			}
 
-			var normalContent, thinkingContent string
-
-			for _, part := range candidate.Content.Parts {
-				if part.Text == "" {
+			var finishReason genai.FinishReason
+			if len(chunk.Candidates) == 0 {
+				if chunk.PromptFeedback != nil && chunk.PromptFeedback.BlockReason != "" {
+					sendStreamError(ctx, ch, "gemini", geminiNoCandidatesError(chunk))
+					return
+				}
+				// Usage may come in a chunk of its own, it is cumulative so only the last one counts
+				if streamResp.Usage = fromGeminiUsage(chunk.UsageMetadata); streamResp.Usage == nil {
 					continue
 				}
-
-				if part.Thought {
-					thinkingContent += part.Text
-				} else {
-					normalContent += part.Text
+			} else {
+				candidate := chunk.Candidates[0]
+				finishReason = candidate.FinishReason
+
+				var normalContent, thinkingContent string
+				var toolCalls []ToolCall
+				if candidate.Content != nil {
+					normalContent, thinkingContent, toolCalls = fromGeminiParts(candidate.Content.Parts, toolCallIndex)
+					toolCallIndex += len(toolCalls)
+				}
+				// The last chunk often carries only the finish reason and the usage, e.g. on MAX_TOKENS or SAFETY
+				if finishReason != "" {
+					streamResp.Usage = fromGeminiUsage(chunk.UsageMetadata)
+				} else if normalContent == "" && thinkingContent == "" && len(toolCalls) == 0 {
+					continue
 				}
-			}
-
-			now := time.Now()
 
-			bytes := make([]byte, 16)
-			rand.Read(bytes)
-			streamResp := &ChatStreamResponse{
-				ID:      "chatcmpl-" + hex.EncodeToString(bytes),
-				Object:  "chat.completion.chunk",
-				Created: now.Unix(),
-				Model:   model,
-				Choices: []StreamChoice{{
+				streamResp.Choices = []StreamChoice{{
 					Index: 0,
 					Delta: MessageDelta{
 						Role:             "assistant",
 						Content:          normalContent,
 						ReasoningContent: thinkingContent,
+						ToolCalls:        toolCalls,
 					},
-					FinishReason: string(candidate.FinishReason),
-				}},
-				SystemFingerprint: "",
-				Provider:          "gemini",
-			}
-
-			if candidate.FinishReason != "" {
-				streamResp.Usage = &Usage{
-					PromptTokens:     int(chunk.UsageMetadata.PromptTokenCount),
-					CompletionTokens: int(chunk.UsageMetadata.CandidatesTokenCount),
-					TotalTokens:      int(chunk.UsageMetadata.TotalTokenCount),
-				}
+					FinishReason: string(finishReason),
+				}}
 			}
 
 			select {
@@ -278,10 +287,347 @@ func (p *geminiProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-ch
 				return
 			}
 
-			if candidate.FinishReason == genai.FinishReasonStop || candidate.FinishReason == genai.FinishReasonMaxTokens {
+			if finishReason == genai.FinishReasonStop || finishReason == genai.FinishReasonMaxTokens {
 				break
 			}
 		}
 	}()
 	return ch, nil
 }
+
+// geminiNoCandidatesError reports a response without candidates. A blocked prompt is a request no instance can
+// serve, any other empty response is retried like a failed call.
+func geminiNoCandidatesError(resp *genai.GenerateContentResponse) error {
+	if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+		return fmt.Errorf("%w: gemini blocked the prompt: %s", errInvalidRequest, resp.PromptFeedback.BlockReason)
+	}
+	return &APIError{Provider: "gemini", Message: "response has no candidates"}
+}
+
+// fromGeminiUsage converts the usage metadata of a response, nil when it has none
+func fromGeminiUsage(metadata *genai.GenerateContentResponseUsageMetadata) *Usage {
+	if metadata == nil {
+		return nil
+	}
+	return &Usage{
+		PromptTokens:     int(metadata.PromptTokenCount),
+		CompletionTokens: int(metadata.CandidatesTokenCount),
+		TotalTokens:      int(metadata.TotalTokenCount),
+	}
+}
+
+// buildConfig converts the generation parameters of a chat request, per-request values taking precedence over the instance config
+func (p *geminiProvider) buildConfig(req *ChatRequest) (*genai.GenerateContentConfig, error) {
+	systemInstruction, err := toGeminiSystemInstruction(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &genai.GenerateContentConfig{
+		SystemInstruction: systemInstruction,
+		Temperature:       genai.Ptr(p.config.Temperature),
+		MaxOutputTokens:   int32(p.config.MaxTokens),
+		TopP:              genai.Ptr(p.config.TopP),
+		StopSequences:     req.Stop,
+		ThinkingConfig: &genai.ThinkingConfig{
+			IncludeThoughts: p.config.IncludeThoughts == nil || *p.config.IncludeThoughts,
+		},
+		Tools:      toGeminiTools(req.Tools),
+		ToolConfig: toGeminiToolConfig(req.ToolChoice),
+	}
+
+	// TOP_K=0 means unlimited, so it is left to the model default rather than sent as 0
+	if p.config.TopK > 0 {
+		config.TopK = genai.Ptr(float32(p.config.TopK))
+	}
+
+	if req.MaxTokens > 0 {
+		config.MaxOutputTokens = int32(req.MaxTokens)
+	}
+	if req.Temperature != nil {
+		config.Temperature = genai.Ptr(*req.Temperature)
+	}
+	if req.TopP != nil {
+		config.TopP = genai.Ptr(*req.TopP)
+	}
+	if req.TopK > 0 {
+		config.TopK = genai.Ptr(float32(req.TopK))
+	}
+	if req.Seed != nil {
+		config.Seed = genai.Ptr(int32(*req.Seed))
+	}
+
+	if req.ResponseFormat.isJSON() {
+		config.ResponseMIMEType = "application/json"
+		if req.ResponseFormat.Type == ResponseFormatJSONSchema {
+			// ResponseJsonSchema accepts full JSON schema, ResponseSchema only the OpenAPI subset
+			config.ResponseJsonSchema = req.ResponseFormat.Schema
+		}
+	}
+	return config, nil
+}
+
+// toGeminiSystemInstruction joins the texts of the system messages into the system instruction, nil when there are none
+func toGeminiSystemInstruction(msgs []Message) (*genai.Content, error) {
+	var parts []*genai.Part
+	for _, msg := range msgs {
+		if msg.Role != RoleSystem {
+			continue
+		}
+		texts, err := msg.systemTexts("gemini")
+		if err != nil {
+			return nil, err
+		}
+		for _, text := range texts {
+			parts = append(parts, &genai.Part{Text: text})
+		}
+	}
+	if len(parts) == 0 {
+		return nil, nil
+	}
+	return &genai.Content{Parts: parts}, nil
+}
+
+// toGeminiContents converts messages to Gemini contents, merging consecutive tool results into one turn
+// System messages are sent separately as the system instruction
+func toGeminiContents(msgs []Message) ([]*genai.Content, error) {
+	var contents []*genai.Content
+	callNames := make(map[string]string) // tool call ID -> function name
+
+	for _, msg := range msgs {
+		switch msg.Role {
+		case RoleSystem:
+			continue
+
+		case RoleTool:
+			name := msg.Name
+			if name == "" {
+				name = callNames[msg.ToolCallID]
+			}
+
+			response := make(map[string]any)
+			if err := json.Unmarshal([]byte(msg.Content), &response); err != nil {
+				response = map[string]any{"output": msg.Content}
+			}
+
+			part := &genai.Part{FunctionResponse: &genai.FunctionResponse{
+				ID:       msg.ToolCallID,
+				Name:     name,
+				Response: response,
+			}}
+
+			if last := len(contents) - 1; last >= 0 && contents[last].Role == genai.RoleUser && contents[last].Parts[0].FunctionResponse != nil {
+				contents[last].Parts = append(contents[last].Parts, part)
+				continue
+			}
+			contents = append(contents, &genai.Content{Role: genai.RoleUser, Parts: []*genai.Part{part}})
+
+		case RoleAssistant:
+			content := &genai.Content{Role: genai.RoleModel}
+			if msg.Content != "" {
+				content.Parts = append(content.Parts, &genai.Part{Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				callNames[call.ID] = call.Function.Name
+
+				args := make(map[string]any)
+				if call.Function.Arguments != "" {
+					json.Unmarshal([]byte(call.Function.Arguments), &args)
+				}
+				content.Parts = append(content.Parts, &genai.Part{FunctionCall: &genai.FunctionCall{
+					ID:   call.ID,
+					Name: call.Function.Name,
+					Args: args,
+				}})
+			}
+			contents = append(contents, content)
+
+		default:
+			parts, err := toGeminiParts(msg)
+			if err != nil {
+				return nil, err
+			}
+			contents = append(contents, &genai.Content{
+				Role:  genai.RoleUser,
+				Parts: parts,
+			})
+		}
+	}
+	return contents, nil
+}
+
+// toGeminiParts converts message content to Gemini parts, sending inline data as bytes and URLs as file data
+func toGeminiParts(msg Message) ([]*genai.Part, error) {
+	contentParts := msg.contentParts()
+	if contentParts == nil {
+		return []*genai.Part{{Text: msg.Content}}, nil
+	}
+
+	parts := make([]*genai.Part, 0, len(contentParts))
+	for _, part := range contentParts {
+		if part.Type == PartTypeText {
+			parts = append(parts, &genai.Part{Text: part.Text})
+			continue
+		}
+
+		if mimeType, data, ok := part.inline(); ok {
+			decoded, err := base64.StdEncoding.DecodeString(data)
+			if err != nil {
+				return nil, fmt.Errorf("%w: failed to decode %s content part: %v", errInvalidRequest, part.Type, err)
+			}
+			parts = append(parts, &genai.Part{InlineData: &genai.Blob{MIMEType: mimeType, Data: decoded}})
+			continue
+		}
+
+		parts = append(parts, &genai.Part{FileData: &genai.FileData{FileURI: part.URL, MIMEType: part.mimeType()}})
+	}
+	return parts, nil
+}
+
+// toGeminiTools converts tool definitions to Gemini function declarations
+func toGeminiTools(tools []Tool) []*genai.Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+
+	declarations := make([]*genai.FunctionDeclaration, len(tools))
+	for i, tool := range tools {
+		declarations[i] = &genai.FunctionDeclaration{
+			Name:                 tool.Function.Name,
+			Description:          tool.Function.Description,
+			ParametersJsonSchema: tool.Function.Parameters,
+		}
+	}
+	return []*genai.Tool{{FunctionDeclarations: declarations}}
+}
+
+// toGeminiToolConfig converts tool choice to Gemini function calling config
+func toGeminiToolConfig(choice *ToolChoice) *genai.ToolConfig {
+	if choice == nil {
+		return nil
+	}
+
+	config := &genai.FunctionCallingConfig{}
+	switch choice.Type {
+	case ToolChoiceNone:
+		config.Mode = genai.FunctionCallingConfigModeNone
+	case ToolChoiceRequired:
+		config.Mode = genai.FunctionCallingConfigModeAny
+	case ToolChoiceFunction:
+		config.Mode = genai.FunctionCallingConfigModeAny
+		config.AllowedFunctionNames = []string{choice.Function}
+	default:
+		config.Mode = genai.FunctionCallingConfigModeAuto
+	}
+	return &genai.ToolConfig{FunctionCallingConfig: config}
+}
+
+// fromGeminiParts splits Gemini parts into content, reasoning content and tool calls
+func fromGeminiParts(parts []*genai.Part, indexOffset int) (content, reasoningContent string, toolCalls []ToolCall) {
+	for _, part := range parts {
+		if part == nil {
+			continue
+		}
+
+		if part.FunctionCall != nil {
+			id := part.FunctionCall.ID
+			if id == "" {
+				bytes := make([]byte, 12)
+				rand.Read(bytes)
+				id = "call_" + hex.EncodeToString(bytes)
+			}
+
+			args := []byte("{}")
+			if len(part.FunctionCall.Args) > 0 {
+				args, _ = json.Marshal(part.FunctionCall.Args)
+			}
+			toolCalls = append(toolCalls, ToolCall{
+				Index: indexOffset + len(toolCalls),
+				ID:    id,
+				Type:  ToolTypeFunction,
+				Function: FunctionCall{
+					Name:      part.FunctionCall.Name,
+					Arguments: string(args),
+				},
+			})
+			continue
+		}
+
+		if part.Text == "" {
+			continue
+		}
+		if part.Thought {
+			reasoningContent += part.Text
+		} else {
+			content += part.Text
+		}
+	}
+	return content, reasoningContent, toolCalls
+}
+
+// geminiEmbeddingBatchSize is the maximum number of contents per batchEmbedContents request
+const geminiEmbeddingBatchSize = 100
+
+func (p *geminiProvider) Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	model := req.Model
+	if model == "" {
+		modelIndex := atomic.AddUint64(p.modelCounter, 1) - 1
+		model = p.config.EmbeddingModels[modelIndex%uint64(len(p.config.EmbeddingModels))]
+	}
+	reportModel(ctx, model)
+
+	config := &genai.EmbedContentConfig{}
+	if req.Dimensions > 0 {
+		config.OutputDimensionality = genai.Ptr(int32(req.Dimensions))
+	}
+
+	embedResp := &EmbeddingResponse{
+		Object:   "list",
+		Model:    model,
+		Provider: "gemini",
+	}
+
+	for offset, batch := range splitBatches(req.Input, geminiEmbeddingBatchSize) {
+		apiKey, err := nextKey(ctx, p.config, p.keyCounter)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.rateLimiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		contents := make([]*genai.Content, len(batch))
+		for i, text := range batch {
+			contents[i] = genai.NewContentFromText(text, genai.RoleUser)
+		}
+
+		var resp *genai.EmbedContentResponse
+		err = retry(ctx, p.config, keyRotation(ctx, p.config, p.keyCounter, &apiKey), func(ctx context.Context) error {
+			client, err := p.client(ctx, apiKey)
+			if err != nil {
+				return err
+			}
+			resp, err = client.Models.EmbedContent(ctx, model, contents, config)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for i, embedding := range resp.Embeddings {
+			embedResp.Data = append(embedResp.Data, Embedding{
+				Object:    "embedding",
+				Index:     offset + i,
+				Embedding: embedding.Values,
+			})
+			// Token statistics are only reported by some backends
+			if embedding.Statistics != nil {
+				embedResp.Usage.PromptTokens += int(embedding.Statistics.TokenCount)
+				embedResp.Usage.TotalTokens += int(embedding.Statistics.TokenCount)
+			}
+		}
+	}
+
+	return embedResp, nil
+}
@@ -0,0 +1,97 @@
+// Package provider implements AI provider interfaces
+// Author: Done-0
+// Created: 2025-08-31
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIError error returned by providers that talk to their API over plain HTTP
+type APIError struct {
+	Provider   string // Provider type, e.g. "anthropic"
+	StatusCode int    // HTTP status code, 0 for errors reported inside a stream
+	Message    string // Error message from the response body
+}
+
+// Error error string representation
+func (e *APIError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%s API error: %s", e.Provider, e.Message)
+	}
+	return fmt.Sprintf("%s API error, status %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// newHTTPClient creates the HTTP client shared by all keys of an instance
+func newHTTPClient(timeout int) *http.Client {
+	return &http.Client{
+		Timeout: time.Duration(timeout) * time.Second,
+		Transport: &retryAfterTransport{base: &http.Transport{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 20,
+			MaxConnsPerHost:     100,
+			IdleConnTimeout:     90 * time.Second,
+			DisableKeepAlives:   false,
+		}},
+	}
+}
+
+// postJSON sends a JSON request and returns the response, converting non-2xx responses to *APIError
+func postJSON(ctx context.Context, client *http.Client, providerName, url string, headers map[string]string, body any) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return nil, &APIError{
+			Provider:   providerName,
+			StatusCode: resp.StatusCode,
+			Message:    errorMessage(data),
+		}
+	}
+	return resp, nil
+}
+
+// errorMessage extracts the message from common JSON error bodies, falling back to the raw body
+func errorMessage(data []byte) string {
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(data, &body); err == nil && len(body.Error) > 0 {
+		var nested struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(body.Error, &nested); err == nil && nested.Message != "" {
+			return nested.Message
+		}
+		var message string
+		if err := json.Unmarshal(body.Error, &message); err == nil && message != "" {
+			return message
+		}
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,189 @@
+// Package provider implements AI provider interfaces
+// Author: Done-0
+// Created: 2025-08-31
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Done-0/gin-scaffold/configs"
+)
+
+// API key states
+const (
+	KeyHealthy     = "healthy"     // Key is picked in Round Robin order
+	KeyQuarantined = "quarantined" // Key is skipped until its quarantine ends or the config is reloaded
+)
+
+// Key quarantine defaults, used when not configured
+const (
+	defaultKeyFailureThreshold = 3
+	defaultKeyCooldown         = 5 * time.Minute
+)
+
+// KeyState snapshot of an instance API key, only the key prefix is shown
+type KeyState struct {
+	Provider            string     `json:"provider"`
+	Instance            string     `json:"instance"`
+	Key                 string     `json:"key"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	QuarantinedUntil    *time.Time `json:"quarantined_until,omitempty"` // Nil while healthy or until the config is reloaded
+}
+
+// keyStatus health of one API key
+type keyStatus struct {
+	failures    int
+	lastError   string
+	quarantined bool
+	until       time.Time // Zero means until the config is reloaded
+}
+
+// keyHealth tracks the API keys of an instance by their position in KEYS, it is replaced when the instance config changes
+type keyHealth struct {
+	mu   sync.Mutex
+	keys map[int]*keyStatus
+}
+
+func newKeyHealth() *keyHealth {
+	return &keyHealth{keys: make(map[int]*keyStatus)}
+}
+
+// available reports whether a key may be picked, ending an expired quarantine
+func (h *keyHealth) available(config *configs.ProviderInstanceConfig, index int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	status, exists := h.keys[index]
+	if !exists || !status.quarantined {
+		return true
+	}
+	if status.until.IsZero() || time.Now().Before(status.until) {
+		return false
+	}
+
+	status.quarantined = false
+	status.failures = 0
+	log.Printf("API key %s of instance %s released from quarantine", maskKey(config.Keys[index]), config.Name)
+	return true
+}
+
+// record updates a key with the result of an attempt. Rejected keys are quarantined until the config is reloaded,
+// exhausted quotas and repeated rate limits for the cooldown.
+func (h *keyHealth) record(config *configs.ProviderInstanceConfig, index int, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	status, exists := h.keys[index]
+	if !exists {
+		status = &keyStatus{}
+		h.keys[index] = status
+	}
+	if err == nil {
+		status.failures = 0
+		return
+	}
+
+	class := classifyError(err)
+	if class != errorAuth && class != errorRateLimited {
+		return
+	}
+	status.failures++
+	status.lastError = err.Error()
+	if status.quarantined {
+		return
+	}
+
+	threshold, cooldown := keyQuarantineConfig()
+	switch {
+	case class == errorAuth:
+		status.quarantined, status.until = true, time.Time{}
+		log.Printf("API key %s of instance %s quarantined until the config is reloaded: %v", maskKey(config.Keys[index]), config.Name, err)
+	case isQuotaExhausted(err) || status.failures >= threshold:
+		status.quarantined, status.until = true, time.Now().Add(cooldown)
+		log.Printf("API key %s of instance %s quarantined for %s after %d failures: %v", maskKey(config.Keys[index]), config.Name, cooldown, status.failures, err)
+	}
+}
+
+// snapshot returns the state of every key of the instance
+func (h *keyHealth) snapshot(name string, config configs.ProviderInstanceConfig) []KeyState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	states := make([]KeyState, len(config.Keys))
+	for i, key := range config.Keys {
+		states[i] = KeyState{Provider: name, Instance: config.Name, Key: maskKey(key), State: KeyHealthy}
+		status, exists := h.keys[i]
+		if !exists {
+			continue
+		}
+		states[i].ConsecutiveFailures = status.failures
+		states[i].LastError = status.lastError
+		if status.quarantined && (status.until.IsZero() || time.Now().Before(status.until)) {
+			states[i].State = KeyQuarantined
+			if !status.until.IsZero() {
+				until := status.until
+				states[i].QuarantinedUntil = &until
+			}
+		}
+	}
+	return states
+}
+
+// keyQuarantineConfig reads the key quarantine threshold and cooldown, filling in defaults
+func keyQuarantineConfig() (int, time.Duration) {
+	threshold, cooldown := defaultKeyFailureThreshold, defaultKeyCooldown
+	if cfg, err := configs.GetConfig(); err == nil {
+		if cfg.AI.KeyQuarantine.FailureThreshold > 0 {
+			threshold = cfg.AI.KeyQuarantine.FailureThreshold
+		}
+		if cfg.AI.KeyQuarantine.Cooldown > 0 {
+			cooldown = time.Duration(cfg.AI.KeyQuarantine.Cooldown) * time.Second
+		}
+	}
+	return threshold, cooldown
+}
+
+// isQuotaExhausted reports whether a rate limit error means the key has no quota left rather than being throttled
+func isQuotaExhausted(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "insufficient_quota") || strings.Contains(message, "exceeded your current quota")
+}
+
+// maskKey returns the prefix of an API key, enough to tell keys apart in logs
+func maskKey(key string) string {
+	visible := min(len(key)/3, 8)
+	return key[:visible] + "****"
+}
+
+// nextKey picks the next available API key of an instance in Round Robin order and reports it for the call,
+// failing over to another instance when every key is quarantined
+func nextKey(ctx context.Context, config *configs.ProviderInstanceConfig, keyCounter *uint64) (string, error) {
+	info, _ := ctx.Value(callInfoKey{}).(*callInfo)
+	for range config.Keys {
+		keyIndex := atomic.AddUint64(keyCounter, 1) - 1
+		index := int(keyIndex % uint64(len(config.Keys)))
+		if info != nil && info.keys != nil && !info.keys.available(config, index) {
+			continue
+		}
+		reportKey(ctx, keyIndex, len(config.Keys))
+		return config.Keys[index], nil
+	}
+	return "", fmt.Errorf("%w: all API keys of instance %s are quarantined", errUnsupported, config.Name)
+}
+
+// recordKey records the result of an attempt against the key the provider reported last
+func recordKey(ctx context.Context, config *configs.ProviderInstanceConfig, err error) {
+	info, ok := ctx.Value(callInfoKey{}).(*callInfo)
+	if !ok || info.keys == nil || info.keyIndex < 0 || info.keyIndex >= len(config.Keys) {
+		return
+	}
+	info.keys.record(config, info.keyIndex, err)
+}
@@ -0,0 +1,170 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyQuarantine(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		mu.Lock()
+		hits[key]++
+		mu.Unlock()
+
+		switch key {
+		case "revoked-key":
+			http.Error(w, `{"error":{"message":"invalid api key","type":"invalid_request_error"}}`, http.StatusUnauthorized)
+		case "drained-key":
+			http.Error(w, `{"error":{"message":"You exceeded your current quota","type":"insufficient_quota"}}`, http.StatusTooManyRequests)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, mockChatCompletion)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	instanceYAML := func(keys string, timeout int) string {
+		return fmt.Sprintf(`AI:
+  KEY_QUARANTINE:
+    COOLDOWN: 60
+  PROVIDERS:
+    openai:
+      ENABLED: true
+      INSTANCES:
+        - NAME: "keys"
+          ENABLED: true
+          BASE_URL: %q
+          KEYS: [%s]
+          MODELS: ["mock-model"]
+          TIMEOUT: %d
+          MAX_RETRIES: 2
+          RETRY:
+            BASE_DELAY: 1
+          RATE_LIMIT: "100/s"`, server.URL, keys, timeout)
+	}
+
+	initTestConfig(t, instanceYAML(`"revoked-key", "drained-key", "good-key-1"`, 5))
+	pool := New()
+	req := &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}}
+	for i := 0; i < 6; i++ {
+		if _, err := pool.Chat(context.Background(), req); err != nil {
+			t.Fatalf("request %d: Chat() failed: %v", i, err)
+		}
+	}
+	if hits["revoked-key"] != 1 || hits["drained-key"] != 1 || hits["good-key-1"] != 6 {
+		t.Errorf("hits = %v, want each bad key tried once", hits)
+	}
+
+	states := pool.KeyStates()
+	if len(states) != 3 {
+		t.Fatalf("key states = %d, want 3", len(states))
+	}
+	want := []struct {
+		key, state string
+		timed      bool
+	}{
+		{"rev****", KeyQuarantined, false},
+		{"dra****", KeyQuarantined, true},
+		{"goo****", KeyHealthy, false},
+	}
+	for i, w := range want {
+		state := states[i]
+		if state.Key != w.key || state.State != w.state || (state.QuarantinedUntil != nil) != w.timed {
+			t.Errorf("key %d = %+v, want key %s in state %s", i, state, w.key, w.state)
+		}
+	}
+	if until := states[1].QuarantinedUntil; until == nil || until.Before(time.Now().Add(50*time.Second)) {
+		t.Errorf("quota quarantine ends at %v, want the 60s cooldown", until)
+	}
+
+	// Every key quarantined, the instance is skipped
+	initTestConfig(t, instanceYAML(`"revoked-key"`, 6))
+	pool.Chat(context.Background(), req)
+	_, err := pool.Chat(context.Background(), req)
+	if err == nil || !strings.Contains(err.Error(), "all API keys of instance keys are quarantined") {
+		t.Errorf("Chat() error = %v, want all keys quarantined", err)
+	}
+	if hits["revoked-key"] != 2 {
+		t.Errorf("revoked key hits = %d, want 2 (once per config)", hits["revoked-key"])
+	}
+
+	// A reloaded config lifts the quarantine
+	initTestConfig(t, instanceYAML(`"revoked-key"`, 7))
+	pool.Chat(context.Background(), req)
+	if hits["revoked-key"] != 3 {
+		t.Errorf("revoked key hits = %d, want 3 after the reload", hits["revoked-key"])
+	}
+}
+
+func TestMaskKey(t *testing.T) {
+	tests := map[string]string{
+		"sk-proj-abcdefghijklmnopqrstuvwxyz": "sk-proj-****",
+		"short":                              "s****",
+		"":                                   "****",
+	}
+	for key, want := range tests {
+		if got := maskKey(key); got != want {
+			t.Errorf("maskKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestKeyQuarantineGeminiStream(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	server := newMockGeminiStreamServer(t, func(key string) int {
+		mu.Lock()
+		hits[key]++
+		mu.Unlock()
+		if key == "revoked-key" {
+			return http.StatusUnauthorized
+		}
+		return 0
+	})
+
+	initTestConfig(t, fmt.Sprintf(`AI:
+  PROVIDERS:
+    gemini:
+      ENABLED: true
+      INSTANCES:
+        - NAME: "gemini-keys"
+          ENABLED: true
+          BASE_URL: %q
+          KEYS: ["revoked-key", "good-key"]
+          MODELS: ["mock-model"]
+          TIMEOUT: 5
+          MAX_RETRIES: 2
+          RETRY:
+            BASE_DELAY: 1
+          RATE_LIMIT: "100/s"`, server.URL))
+	pool := New()
+	req := &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}}
+	for i := 0; i < 3; i++ {
+		stream, err := pool.ChatStream(context.Background(), req)
+		if err != nil {
+			t.Fatalf("request %d: ChatStream() failed: %v", i, err)
+		}
+		for chunk := range stream {
+			if chunk.Err != nil {
+				t.Fatalf("request %d: stream error: %v", i, chunk.Err)
+			}
+		}
+	}
+	if hits["revoked-key"] != 1 || hits["good-key"] != 3 {
+		t.Errorf("hits = %v, want the revoked key tried once", hits)
+	}
+
+	states := pool.KeyStates()
+	if len(states) != 2 || states[0].Key != "rev****" || states[0].State != KeyQuarantined || states[1].State != KeyHealthy {
+		t.Errorf("key states = %+v, want the revoked key quarantined", states)
+	}
+}
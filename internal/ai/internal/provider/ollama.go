@@ -0,0 +1,456 @@
+// Package provider implements AI provider interfaces
+// Author: Done-0
+// Created: 2025-08-31
+package provider
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/Done-0/gin-scaffold/configs"
+
+	rateUtil "github.com/Done-0/gin-scaffold/internal/utils/rate"
+)
+
+const ollamaDefaultBaseURL = "http://localhost:11434"
+
+type ollamaProvider struct {
+	config       *configs.ProviderInstanceConfig
+	rateLimiter  *rate.Limiter
+	keyCounter   *uint64
+	modelCounter *uint64
+	httpClient   *http.Client
+}
+
+// Native /api/chat wire types
+type ollamaRequest struct {
+	Model     string          `json:"model"`
+	Messages  []ollamaMessage `json:"messages"`
+	Stream    bool            `json:"stream"`
+	Tools     []Tool          `json:"tools,omitempty"`
+	Format    any             `json:"format,omitempty"` // "json" or a JSON schema
+	Options   map[string]any  `json:"options,omitempty"`
+	KeepAlive any             `json:"keep_alive,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Images    []string         `json:"images,omitempty"` // Base64 encoded images
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// ollamaResponse is both the non-stream response and a single NDJSON stream line
+type ollamaResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+func NewOllama(config *configs.ProviderInstanceConfig, keyCounter *uint64, modelCounter *uint64) (Provider, error) {
+	rateLimit, burst, err := rateUtil.ParseLimit(config.RateLimit)
+	if err != nil {
+		return nil, err
+	}
+	limiter := rate.NewLimiter(rateLimit, burst)
+
+	return &ollamaProvider{
+		config:       config,
+		rateLimiter:  limiter,
+		keyCounter:   keyCounter,
+		modelCounter: modelCounter,
+		httpClient:   newHTTPClient(config.Timeout),
+	}, nil
+}
+
+func (p *ollamaProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	model := req.Model
+	if model == "" {
+		modelIndex := atomic.AddUint64(p.modelCounter, 1) - 1
+		model = p.config.Models[modelIndex%uint64(len(p.config.Models))]
+	}
+	reportModel(ctx, model)
+
+	req, err := fitContext(p.config, model, req)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := p.buildRequest(model, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	var apiKey string
+	if len(p.config.Keys) > 0 {
+		if apiKey, err = nextKey(ctx, p.config, p.keyCounter); err != nil {
+			return nil, err
+		}
+	}
+
+	var httpResp *http.Response
+	err = retry(ctx, p.config, keyRotation(ctx, p.config, p.keyCounter, &apiKey), func(ctx context.Context) (err error) {
+		httpResp, err = postJSON(ctx, p.httpClient, "ollama", p.endpoint(), p.headers(apiKey), request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	var resp ollamaResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, &APIError{Provider: "ollama", StatusCode: httpResp.StatusCode, Message: resp.Error}
+	}
+
+	toolCalls := fromOllamaToolCalls(resp.Message.ToolCalls, 0)
+	return &ChatResponse{
+		ID:      newOllamaID("chatcmpl-"),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   resp.Model,
+		Choices: []Choice{{
+			Index: 0,
+			Message: Message{
+				Role:             RoleAssistant,
+				Content:          resp.Message.Content,
+				ReasoningContent: resp.Message.Thinking,
+				ToolCalls:        toolCalls,
+			},
+			FinishReason: ollamaFinishReason(resp.DoneReason, len(toolCalls) > 0),
+		}},
+		Usage:    resp.toUsage(),
+		Provider: "ollama",
+	}, nil
+}
+
+func (p *ollamaProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan *ChatStreamResponse, error) {
+	model := req.Model
+	if model == "" {
+		modelIndex := atomic.AddUint64(p.modelCounter, 1) - 1
+		model = p.config.Models[modelIndex%uint64(len(p.config.Models))]
+	}
+	reportModel(ctx, model)
+
+	req, err := fitContext(p.config, model, req)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := p.buildRequest(model, req)
+	if err != nil {
+		return nil, err
+	}
+	request.Stream = true
+
+	if err := p.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	var apiKey string
+	if len(p.config.Keys) > 0 {
+		if apiKey, err = nextKey(ctx, p.config, p.keyCounter); err != nil {
+			return nil, err
+		}
+	}
+
+	var httpResp *http.Response
+	err = retry(ctx, p.config, keyRotation(ctx, p.config, p.keyCounter, &apiKey), func(ctx context.Context) (err error) {
+		httpResp, err = postJSON(ctx, p.httpClient, "ollama", p.endpoint(), p.headers(apiKey), request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *ChatStreamResponse)
+	go func() {
+		defer close(ch)
+		defer httpResp.Body.Close()
+
+		id := newOllamaID("chatcmpl-")
+		toolCallCount := 0
+
+		// Each line of the body is a complete JSON object, the last one has done=true
+		scanner := bufio.NewScanner(httpResp.Body)
+		scanner.Buffer(make([]byte, 64<<10), 1<<20)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+
+			var chunk ollamaResponse
+			if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+				continue
+			}
+			if chunk.Error != "" {
+				sendStreamError(ctx, ch, "ollama", &APIError{Provider: "ollama", Message: chunk.Error})
+				return
+			}
+
+			toolCalls := fromOllamaToolCalls(chunk.Message.ToolCalls, toolCallCount)
+			toolCallCount += len(toolCalls)
+
+			streamResp := &ChatStreamResponse{
+				ID:      id,
+				Object:  "chat.completion.chunk",
+				Created: time.Now().Unix(),
+				Model:   chunk.Model,
+				Choices: []StreamChoice{{
+					Index: 0,
+					Delta: MessageDelta{
+						Role:             chunk.Message.Role,
+						Content:          chunk.Message.Content,
+						ReasoningContent: chunk.Message.Thinking,
+						ToolCalls:        toolCalls,
+					},
+				}},
+				Provider: "ollama",
+			}
+			if chunk.Done {
+				usage := chunk.toUsage()
+				streamResp.Usage = &usage
+				streamResp.Choices[0].FinishReason = ollamaFinishReason(chunk.DoneReason, toolCallCount > 0)
+			}
+
+			select {
+			case ch <- streamResp:
+			case <-ctx.Done():
+				return
+			}
+
+			if chunk.Done {
+				return
+			}
+		}
+
+		// The last line has done=true, reaching the end of the body without it means the stream broke off
+		err := scanner.Err()
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		sendStreamError(ctx, ch, "ollama", err)
+	}()
+	return ch, nil
+}
+
+// endpoint returns the /api/chat URL of the instance
+func (p *ollamaProvider) endpoint() string {
+	baseURL := p.config.BaseURL
+	if baseURL == "" {
+		baseURL = ollamaDefaultBaseURL
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/api") + "/api/chat"
+}
+
+// headers returns the request headers, keys are optional and only needed behind an authenticating proxy
+func (p *ollamaProvider) headers(apiKey string) map[string]string {
+	if apiKey == "" {
+		return nil
+	}
+	return map[string]string{
+		"Authorization": "Bearer " + apiKey,
+	}
+}
+
+// buildRequest converts a chat request to a native /api/chat request
+func (p *ollamaProvider) buildRequest(model string, req *ChatRequest) (*ollamaRequest, error) {
+	if err := req.ResponseFormat.validate(); err != nil {
+		return nil, err
+	}
+
+	request := &ollamaRequest{
+		Model:     model,
+		Tools:     req.Tools,
+		Options:   p.options(model, req),
+		KeepAlive: ollamaKeepAlive(p.config.KeepAlive),
+	}
+	if req.ResponseFormat.isJSON() {
+		request.Format = "json"
+		if req.ResponseFormat.Type == ResponseFormatJSONSchema {
+			request.Format = req.ResponseFormat.Schema
+		}
+	}
+
+	toolNames := make(map[string]string) // tool call ID -> function name
+	for _, msg := range req.Messages {
+		message := ollamaMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+		if parts := msg.contentParts(); parts != nil {
+			// Text parts are joined into content, images are only accepted inline
+			var texts []string
+			for _, part := range parts {
+				if part.Type == PartTypeText {
+					texts = append(texts, part.Text)
+					continue
+				}
+				_, data, ok := part.inline()
+				if !ok || !part.isImage() {
+					return nil, unsupportedPartError("ollama", part)
+				}
+				message.Images = append(message.Images, data)
+			}
+			message.Content = strings.Join(texts, "\n")
+		}
+		for _, call := range msg.ToolCalls {
+			toolNames[call.ID] = call.Function.Name
+			arguments := json.RawMessage(call.Function.Arguments)
+			if !json.Valid(arguments) {
+				arguments = json.RawMessage("{}")
+			}
+			var toolCall ollamaToolCall
+			toolCall.Function.Name = call.Function.Name
+			toolCall.Function.Arguments = arguments
+			message.ToolCalls = append(message.ToolCalls, toolCall)
+		}
+		if msg.Role == RoleTool {
+			message.ToolName = msg.Name
+			if message.ToolName == "" {
+				message.ToolName = toolNames[msg.ToolCallID]
+			}
+		}
+		request.Messages = append(request.Messages, message)
+	}
+
+	return request, nil
+}
+
+// options merges the instance sampling parameters, the per-model options and the per-request parameters, later ones taking precedence
+func (p *ollamaProvider) options(model string, req *ChatRequest) map[string]any {
+	options := make(map[string]any)
+	if p.config.MaxTokens > 0 {
+		options["num_predict"] = p.config.MaxTokens
+	}
+	if p.config.Temperature != 0 {
+		options["temperature"] = p.config.Temperature
+	}
+	if p.config.TopP != 0 {
+		options["top_p"] = p.config.TopP
+	}
+	if p.config.TopK > 0 {
+		options["top_k"] = p.config.TopK
+	}
+
+	for _, modelOptions := range p.config.ModelOptions {
+		if modelOptions.Model == model {
+			maps.Copy(options, modelOptions.Options)
+		}
+	}
+
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	}
+	if req.Temperature != nil {
+		options["temperature"] = *req.Temperature
+	}
+	if req.TopP != nil {
+		options["top_p"] = *req.TopP
+	}
+	if req.TopK > 0 {
+		options["top_k"] = req.TopK
+	}
+	if len(req.Stop) > 0 {
+		options["stop"] = req.Stop
+	}
+	if req.Seed != nil {
+		options["seed"] = *req.Seed
+	}
+
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
+
+// ollamaKeepAlive sends plain numbers as seconds, other values as duration strings
+func ollamaKeepAlive(keepAlive string) any {
+	if keepAlive == "" {
+		return nil
+	}
+	if seconds, err := strconv.Atoi(keepAlive); err == nil {
+		return seconds
+	}
+	return keepAlive
+}
+
+// fromOllamaToolCalls converts tool calls, generating the IDs Ollama does not provide
+func fromOllamaToolCalls(calls []ollamaToolCall, indexOffset int) []ToolCall {
+	var toolCalls []ToolCall
+	for i, call := range calls {
+		arguments := string(call.Function.Arguments)
+		if arguments == "" || arguments == "null" {
+			arguments = "{}"
+		}
+		toolCalls = append(toolCalls, ToolCall{
+			Index: indexOffset + i,
+			ID:    newOllamaID(fmt.Sprintf("call_%d_", indexOffset+i)),
+			Type:  ToolTypeFunction,
+			Function: FunctionCall{
+				Name:      call.Function.Name,
+				Arguments: arguments,
+			},
+		})
+	}
+	return toolCalls
+}
+
+func (r *ollamaResponse) toUsage() Usage {
+	return Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+// ollamaFinishReason maps done reasons to OpenAI finish reasons
+func ollamaFinishReason(doneReason string, hasToolCalls bool) string {
+	if hasToolCalls {
+		return "tool_calls"
+	}
+	if doneReason == "" {
+		return "stop"
+	}
+	return doneReason
+}
+
+func newOllamaID(prefix string) string {
+	bytes := make([]byte, 12)
+	rand.Read(bytes)
+	return prefix + hex.EncodeToString(bytes)
+}
@@ -0,0 +1,172 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newMockOllamaServer starts an /api/chat server that captures the decoded request
+func newMockOllamaServer(t *testing.T, handler func(w http.ResponseWriter, body map[string]any)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		handler(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOllamaChatStream(t *testing.T) {
+	var captured map[string]any
+	server := newMockOllamaServer(t, func(w http.ResponseWriter, body map[string]any) {
+		captured = body
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		lines := []string{
+			`{"model":"qwen3:8b","message":{"role":"assistant","content":"","thinking":"Hmm"},"done":false}`,
+			`{"model":"qwen3:8b","message":{"role":"assistant","content":"Hello"},"done":false}`,
+			`{"model":"qwen3:8b","message":{"role":"assistant","content":" world"},"done":false}`,
+			`{"model":"qwen3:8b","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":7,"eval_count":3}`,
+		}
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+	})
+
+	initTestConfig(t, fmt.Sprintf(`AI:
+  PROVIDERS:
+    ollama:
+      ENABLED: true
+      INSTANCES:
+        - NAME: "local"
+          ENABLED: true
+          BASE_URL: %q
+          MODELS: ["qwen3:8b"]
+          TEMPERATURE: 0.5
+          KEEP_ALIVE: "-1"
+          MODEL_OPTIONS:
+            - MODEL: "qwen3:8b"
+              OPTIONS:
+                num_ctx: 32768
+                temperature: 0.2
+            - MODEL: "other"
+              OPTIONS:
+                num_ctx: 2048
+          TIMEOUT: 5
+          RATE_LIMIT: "100/s"`, server.URL))
+
+	stream, err := New().ChatStream(context.Background(), &ChatRequest{
+		Messages: []Message{{Role: RoleUser, Content: "Hi"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatStream() failed: %v", err)
+	}
+
+	var content, reasoning, finishReason string
+	var usage *Usage
+	for resp := range stream {
+		if resp.Provider != "ollama" {
+			t.Errorf("provider = %s, want ollama", resp.Provider)
+		}
+		content += resp.Choices[0].Delta.Content
+		reasoning += resp.Choices[0].Delta.ReasoningContent
+		if resp.Choices[0].FinishReason != "" {
+			finishReason = resp.Choices[0].FinishReason
+		}
+		if resp.Usage != nil {
+			usage = resp.Usage
+		}
+	}
+
+	if content != "Hello world" || reasoning != "Hmm" {
+		t.Errorf("content = %q, reasoning = %q", content, reasoning)
+	}
+	if finishReason != "stop" {
+		t.Errorf("finish reason = %s, want stop", finishReason)
+	}
+	if usage == nil || usage.PromptTokens != 7 || usage.CompletionTokens != 3 || usage.TotalTokens != 10 {
+		t.Errorf("usage = %+v", usage)
+	}
+
+	if captured["stream"] != true || captured["keep_alive"] != float64(-1) {
+		t.Errorf("stream = %v, keep_alive = %v", captured["stream"], captured["keep_alive"])
+	}
+	options, _ := captured["options"].(map[string]any)
+	if options["num_ctx"] != float64(32768) || options["temperature"] != 0.2 {
+		t.Errorf("options = %v, want per-model options to override instance parameters", options)
+	}
+}
+
+func TestOllamaToolCalls(t *testing.T) {
+	var captured map[string]any
+	server := newMockOllamaServer(t, func(w http.ResponseWriter, body map[string]any) {
+		captured = body
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"model": "qwen3:8b",
+			"message": {"role": "assistant", "content": "", "tool_calls": [{"function": {"name": "get_weather", "arguments": {"city": "Paris"}}}]},
+			"done": true, "done_reason": "stop", "prompt_eval_count": 30, "eval_count": 12
+		}`)
+	})
+
+	config := newMockOpenAIConfig(server.URL)
+	config.KeepAlive = "10m"
+	p, err := NewOllama(config, new(uint64), new(uint64))
+	if err != nil {
+		t.Fatalf("NewOllama() failed: %v", err)
+	}
+
+	resp, err := p.Chat(context.Background(), &ChatRequest{
+		Messages: []Message{
+			{Role: RoleUser, Content: "Weather in Rome?"},
+			{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call_0", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Rome"}`}}}},
+			{Role: RoleTool, ToolCallID: "call_0", Content: "sunny"},
+		},
+		Tools: []Tool{weatherTool},
+	})
+	if err != nil {
+		t.Fatalf("Chat() failed: %v", err)
+	}
+
+	if captured["keep_alive"] != "10m" || captured["stream"] != false {
+		t.Errorf("keep_alive = %v, stream = %v", captured["keep_alive"], captured["stream"])
+	}
+	messages, _ := captured["messages"].([]any)
+	if len(messages) != 3 {
+		t.Fatalf("messages = %d, want 3", len(messages))
+	}
+	assistant, _ := messages[1].(map[string]any)
+	calls, _ := assistant["tool_calls"].([]any)
+	if len(calls) != 1 {
+		t.Fatalf("assistant tool calls = %v", assistant["tool_calls"])
+	}
+	if arguments := calls[0].(map[string]any)["function"].(map[string]any)["arguments"]; arguments.(map[string]any)["city"] != "Rome" {
+		t.Errorf("arguments = %v, want a JSON object", arguments)
+	}
+	if tool, _ := messages[2].(map[string]any); tool["tool_name"] != "get_weather" {
+		t.Errorf("tool message = %v, want tool_name resolved from the call ID", tool)
+	}
+
+	choice := resp.Choices[0]
+	if choice.FinishReason != "tool_calls" || len(choice.Message.ToolCalls) != 1 {
+		t.Fatalf("choice = %+v", choice)
+	}
+	call := choice.Message.ToolCalls[0]
+	if call.ID == "" || call.Function.Name != "get_weather" || call.Function.Arguments != `{"city": "Paris"}` {
+		t.Errorf("tool call = %+v", call)
+	}
+	if resp.Usage.TotalTokens != 42 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
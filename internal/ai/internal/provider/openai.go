@@ -7,10 +7,13 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"io"
+	"math"
 	"net/http"
+	"sync"
 	"sync/atomic"
-	"time"
 
 	"github.com/sashabaranov/go-openai"
 	"golang.org/x/time/rate"
@@ -25,6 +28,9 @@ type openAIProvider struct {
 	rateLimiter  *rate.Limiter
 	keyCounter   *uint64
 	modelCounter *uint64
+	httpClient   *http.Client              // Shared by all keys of the instance
+	clients      map[string]*openai.Client // key: API key, value: client
+	mu           sync.Mutex                // Guards clients
 }
 
 func NewOpenAI(config *configs.ProviderInstanceConfig, keyCounter *uint64, modelCounter *uint64) (Provider, error) {
@@ -39,64 +45,68 @@ func NewOpenAI(config *configs.ProviderInstanceConfig, keyCounter *uint64, model
 		rateLimiter:  limiter,
 		keyCounter:   keyCounter,
 		modelCounter: modelCounter,
+		httpClient:   newHTTPClient(config.Timeout),
+		clients:      make(map[string]*openai.Client),
 	}, nil
 }
 
+// client returns the cached client for an API key
+func (p *openAIProvider) client(apiKey string) *openai.Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	client, exists := p.clients[apiKey]
+	if !exists {
+		config := openai.DefaultConfig(apiKey)
+		config.BaseURL = p.config.BaseURL
+		config.HTTPClient = p.httpClient
+		client = openai.NewClientWithConfig(config)
+		p.clients[apiKey] = client
+	}
+	return client
+}
+
 func (p *openAIProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
 	model := req.Model
 	if model == "" {
 		modelIndex := atomic.AddUint64(p.modelCounter, 1) - 1
 		model = p.config.Models[modelIndex%uint64(len(p.config.Models))]
 	}
+	reportModel(ctx, model)
 
-	keyIndex := atomic.AddUint64(p.keyCounter, 1) - 1
-	apiKey := p.config.Keys[keyIndex%uint64(len(p.config.Keys))]
+	req, err := fitContext(p.config, model, req)
+	if err != nil {
+		return nil, err
+	}
 
-	config := openai.DefaultConfig(apiKey)
-	config.BaseURL = p.config.BaseURL
-	config.HTTPClient = &http.Client{
-		Timeout: time.Duration(p.config.Timeout) * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 20,
-			MaxConnsPerHost:     100,
-			IdleConnTimeout:     90 * time.Second,
-			DisableKeepAlives:   false,
-		},
+	apiKey, err := nextKey(ctx, p.config, p.keyCounter)
+	if err != nil {
+		return nil, err
 	}
-	client := openai.NewClientWithConfig(config)
 
-	messages := make([]openai.ChatCompletionMessage, len(req.Messages))
-	for i, msg := range req.Messages {
-		messages[i] = openai.ChatCompletionMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		}
+	messages, err := toOpenAIMessages(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.ResponseFormat.validate(); err != nil {
+		return nil, err
 	}
 
 	if err := p.rateLimiter.Wait(ctx); err != nil {
 		return nil, err
 	}
 
-	request := openai.ChatCompletionRequest{
-		Model:       model,
-		Messages:    messages,
-		MaxTokens:   p.config.MaxTokens,
-		Temperature: p.config.Temperature,
-		TopP:        p.config.TopP,
-	}
+	request := p.buildRequest(model, messages, req)
 
 	var resp openai.ChatCompletionResponse
-	var err error
-	for attempt := 0; attempt <= p.config.MaxRetries; attempt++ {
-		resp, err = client.CreateChatCompletion(ctx, request)
-		if err == nil {
-			break
-		}
-		if attempt < p.config.MaxRetries {
-			time.Sleep(time.Duration(attempt+1) * time.Second)
+	err = retry(ctx, p.config, keyRotation(ctx, p.config, p.keyCounter, &apiKey), func(ctx context.Context) (err error) {
+		resp, err = p.client(apiKey).CreateChatCompletion(ctx, request)
+		if err == nil && len(resp.Choices) == 0 {
+			// An empty response is retried like a failed call
+			err = &APIError{Provider: "openai", Message: "response has no choices"}
 		}
-	}
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -114,6 +124,7 @@ func (p *openAIProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatRespo
 				Role:             resp.Choices[0].Message.Role,
 				Content:          resp.Choices[0].Message.Content,
 				ReasoningContent: resp.Choices[0].Message.ReasoningContent,
+				ToolCalls:        fromOpenAIToolCalls(resp.Choices[0].Message.ToolCalls),
 			},
 			FinishReason: string(resp.Choices[0].FinishReason),
 		}},
@@ -138,57 +149,39 @@ func (p *openAIProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-ch
 		modelIndex := atomic.AddUint64(p.modelCounter, 1) - 1
 		model = p.config.Models[modelIndex%uint64(len(p.config.Models))]
 	}
+	reportModel(ctx, model)
 
-	keyIndex := atomic.AddUint64(p.keyCounter, 1) - 1
-	apiKey := p.config.Keys[keyIndex%uint64(len(p.config.Keys))]
-
-	config := openai.DefaultConfig(apiKey)
-	config.BaseURL = p.config.BaseURL
-	config.HTTPClient = &http.Client{
-		Timeout: time.Duration(p.config.Timeout) * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 20,
-			MaxConnsPerHost:     100,
-			IdleConnTimeout:     90 * time.Second,
-			DisableKeepAlives:   false,
-		},
+	req, err := fitContext(p.config, model, req)
+	if err != nil {
+		return nil, err
 	}
-	client := openai.NewClientWithConfig(config)
 
-	if err := p.rateLimiter.Wait(ctx); err != nil {
+	apiKey, err := nextKey(ctx, p.config, p.keyCounter)
+	if err != nil {
 		return nil, err
 	}
 
-	messages := make([]openai.ChatCompletionMessage, len(req.Messages))
-	for i, msg := range req.Messages {
-		messages[i] = openai.ChatCompletionMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		}
+	messages, err := toOpenAIMessages(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.ResponseFormat.validate(); err != nil {
+		return nil, err
 	}
 
-	request := openai.ChatCompletionRequest{
-		Model:         model,
-		Messages:      messages,
-		Stream:        true,
-		MaxTokens:     p.config.MaxTokens,
-		Temperature:   float32(p.config.Temperature),
-		TopP:          float32(p.config.TopP),
-		StreamOptions: &openai.StreamOptions{IncludeUsage: true},
+	if err := p.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
 	}
 
+	request := p.buildRequest(model, messages, req)
+	request.Stream = true
+	request.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+
 	var stream *openai.ChatCompletionStream
-	var err error
-	for attempt := 0; attempt <= p.config.MaxRetries; attempt++ {
-		stream, err = client.CreateChatCompletionStream(ctx, request)
-		if err == nil {
-			break
-		}
-		if attempt < p.config.MaxRetries {
-			time.Sleep(time.Duration(attempt+1) * time.Second)
-		}
-	}
+	err = retry(ctx, p.config, keyRotation(ctx, p.config, p.keyCounter, &apiKey), func(ctx context.Context) (err error) {
+		stream, err = p.client(apiKey).CreateChatCompletionStream(ctx, request)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -200,11 +193,12 @@ func (p *openAIProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-ch
 
 		for {
 			response, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return
+			}
 			if err != nil {
-				if err == io.EOF {
-					break
-				}
-				break
+				sendStreamError(ctx, ch, "openai", err)
+				return
 			}
 
 			bytes := make([]byte, 16)
@@ -225,6 +219,7 @@ func (p *openAIProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-ch
 						Role:             response.Choices[0].Delta.Role,
 						Content:          response.Choices[0].Delta.Content,
 						ReasoningContent: response.Choices[0].Delta.ReasoningContent,
+						ToolCalls:        fromOpenAIToolCalls(response.Choices[0].Delta.ToolCalls),
 					},
 					FinishReason: string(response.Choices[0].FinishReason),
 				}}
@@ -238,8 +233,226 @@ func (p *openAIProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-ch
 				}
 			}
 
-			ch <- streamResp
+			select {
+			case ch <- streamResp:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 	return ch, nil
 }
+
+// buildRequest converts a chat request to an OpenAI request, per-request parameters taking precedence over the instance config
+func (p *openAIProvider) buildRequest(model string, messages []openai.ChatCompletionMessage, req *ChatRequest) openai.ChatCompletionRequest {
+	request := openai.ChatCompletionRequest{
+		Model:          model,
+		Messages:       messages,
+		MaxTokens:      p.config.MaxTokens,
+		Temperature:    p.config.Temperature,
+		TopP:           p.config.TopP,
+		Stop:           req.Stop,
+		Seed:           req.Seed,
+		ResponseFormat: toOpenAIResponseFormat(req.ResponseFormat),
+		Tools:          toOpenAITools(req.Tools),
+		ToolChoice:     toOpenAIToolChoice(req.ToolChoice),
+	}
+
+	if req.MaxTokens > 0 {
+		request.MaxTokens = req.MaxTokens
+	}
+	if req.Temperature != nil {
+		request.Temperature = *req.Temperature
+		if request.Temperature == 0 {
+			// go-openai drops a zero temperature, the smallest float is its documented stand-in for 0
+			request.Temperature = math.SmallestNonzeroFloat32
+		}
+	}
+	if req.TopP != nil {
+		request.TopP = *req.TopP
+	}
+	return request
+}
+
+// toOpenAIResponseFormat converts the response format to OpenAI response_format
+func toOpenAIResponseFormat(format *ResponseFormat) *openai.ChatCompletionResponseFormat {
+	if format == nil || format.Type == "" {
+		return nil
+	}
+
+	result := &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatType(format.Type)}
+	if format.Type == ResponseFormatJSONSchema {
+		schema, _ := json.Marshal(format.Schema)
+		result.JSONSchema = &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   format.schemaName(),
+			Schema: json.RawMessage(schema),
+			Strict: format.Strict,
+		}
+	}
+	return result
+}
+
+// toOpenAIMessages converts messages to OpenAI chat messages
+func toOpenAIMessages(msgs []Message) ([]openai.ChatCompletionMessage, error) {
+	messages := make([]openai.ChatCompletionMessage, len(msgs))
+	for i, msg := range msgs {
+		messages[i] = openai.ChatCompletionMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			Name:       msg.Name,
+			ToolCallID: msg.ToolCallID,
+		}
+		if parts := msg.contentParts(); parts != nil {
+			messages[i].Content = ""
+			for _, part := range parts {
+				switch {
+				case part.Type == PartTypeText:
+					messages[i].MultiContent = append(messages[i].MultiContent, openai.ChatMessagePart{
+						Type: openai.ChatMessagePartTypeText,
+						Text: part.Text,
+					})
+				case part.isImage():
+					messages[i].MultiContent = append(messages[i].MultiContent, openai.ChatMessagePart{
+						Type: openai.ChatMessagePartTypeImageURL,
+						ImageURL: &openai.ChatMessageImageURL{
+							URL:    part.dataURL(),
+							Detail: openai.ImageURLDetail(part.Detail),
+						},
+					})
+				default:
+					return nil, unsupportedPartError("openai", part)
+				}
+			}
+		}
+		for _, call := range msg.ToolCalls {
+			messages[i].ToolCalls = append(messages[i].ToolCalls, openai.ToolCall{
+				ID:   call.ID,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      call.Function.Name,
+					Arguments: call.Function.Arguments,
+				},
+			})
+		}
+	}
+	return messages, nil
+}
+
+// toOpenAITools converts tool definitions to OpenAI tools
+func toOpenAITools(tools []Tool) []openai.Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+
+	result := make([]openai.Tool, len(tools))
+	for i, tool := range tools {
+		result[i] = openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  tool.Function.Parameters,
+			},
+		}
+	}
+	return result
+}
+
+// toOpenAIToolChoice converts tool choice to the OpenAI string or object form
+func toOpenAIToolChoice(choice *ToolChoice) any {
+	if choice == nil {
+		return nil
+	}
+
+	if choice.Type == ToolChoiceFunction {
+		return openai.ToolChoice{
+			Type:     openai.ToolTypeFunction,
+			Function: openai.ToolFunction{Name: choice.Function},
+		}
+	}
+	return choice.Type
+}
+
+// fromOpenAIToolCalls converts OpenAI tool calls, including stream deltas
+func fromOpenAIToolCalls(calls []openai.ToolCall) []ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+
+	result := make([]ToolCall, len(calls))
+	for i, call := range calls {
+		index := i
+		if call.Index != nil {
+			index = *call.Index
+		}
+		result[i] = ToolCall{
+			Index: index,
+			ID:    call.ID,
+			Type:  string(call.Type),
+			Function: FunctionCall{
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			},
+		}
+	}
+	return result
+}
+
+// openAIEmbeddingBatchSize is the maximum number of inputs per embeddings request
+const openAIEmbeddingBatchSize = 2048
+
+func (p *openAIProvider) Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	model := req.Model
+	if model == "" {
+		modelIndex := atomic.AddUint64(p.modelCounter, 1) - 1
+		model = p.config.EmbeddingModels[modelIndex%uint64(len(p.config.EmbeddingModels))]
+	}
+	reportModel(ctx, model)
+
+	embedResp := &EmbeddingResponse{
+		Object:   "list",
+		Model:    model,
+		Provider: "openai",
+	}
+
+	for offset, batch := range splitBatches(req.Input, openAIEmbeddingBatchSize) {
+		apiKey, err := nextKey(ctx, p.config, p.keyCounter)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.rateLimiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		request := openai.EmbeddingRequestStrings{
+			Input:      batch,
+			Model:      openai.EmbeddingModel(model),
+			Dimensions: req.Dimensions,
+		}
+
+		var resp openai.EmbeddingResponse
+		err = retry(ctx, p.config, keyRotation(ctx, p.config, p.keyCounter, &apiKey), func(ctx context.Context) (err error) {
+			resp, err = p.client(apiKey).CreateEmbeddings(ctx, request)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, data := range resp.Data {
+			embedResp.Data = append(embedResp.Data, Embedding{
+				Object:    "embedding",
+				Index:     offset + data.Index,
+				Embedding: data.Embedding,
+			})
+		}
+		if resp.Model != "" {
+			embedResp.Model = string(resp.Model)
+		}
+		embedResp.Usage.PromptTokens += resp.Usage.PromptTokens
+		embedResp.Usage.TotalTokens += resp.Usage.TotalTokens
+	}
+
+	return embedResp, nil
+}
@@ -5,55 +5,293 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"iter"
 	"log"
-	"sync/atomic"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"google.golang.org/genai"
 
 	"github.com/Done-0/gin-scaffold/configs"
 )
 
+var (
+	errUnsupported    = errors.New("unsupported by provider") // The instance cannot serve the request, another instance may
+	errInvalidRequest = errors.New("invalid request")         // The request is malformed, no instance can serve it
+)
+
 type provider struct {
-	instanceCounter uint64             // Round Robin selection
-	keyCounters     map[string]*uint64 // key: "provider:instance", value: counter pointer
-	modelCounters   map[string]*uint64 // key: "provider:instance", value: counter pointer
+	instanceCounter uint64                     // Round Robin selection
+	currentWeights  map[string]int             // key: "provider:instance", value: smooth weighted round robin weight
+	latencies       map[string]time.Duration   // key: "kind:provider:instance", value: moving average latency
+	keyCounters     map[string]*uint64         // key: "provider:instance", value: counter pointer
+	modelCounters   map[string]*uint64         // key: "provider:instance", value: counter pointer
+	breakers        map[string]*breaker        // key: "provider:instance", value: circuit breaker
+	keyHealth       map[string]*keyHealth      // key: "provider:instance", value: API key health, reset with the client
+	clients         map[string]*cachedProvider // key: "provider:instance", value: instance client
+	mu              sync.Mutex                 // Guards the maps above
+	observers       []CallObserver             // Notified of every finished call
 }
 
-func New() Provider {
+// cachedProvider keeps an instance client, its rate limiter and connections alive across requests
+type cachedProvider struct {
+	config   configs.ProviderInstanceConfig // Config snapshot the client was built from
+	provider Provider
+}
+
+type providerInstance struct {
+	name     string
+	instance configs.ProviderInstanceConfig
+}
+
+// key returns the "provider:instance" identifier
+func (pi providerInstance) key() string {
+	return fmt.Sprintf("%s:%s", pi.name, pi.instance.Name)
+}
+
+func New(observers ...CallObserver) Pool {
 	return &provider{
-		keyCounters:   make(map[string]*uint64),
-		modelCounters: make(map[string]*uint64),
+		keyCounters:    make(map[string]*uint64),
+		currentWeights: make(map[string]int),
+		latencies:      make(map[string]time.Duration),
+		modelCounters:  make(map[string]*uint64),
+		breakers:       make(map[string]*breaker),
+		keyHealth:      make(map[string]*keyHealth),
+		clients:        make(map[string]*cachedProvider),
+		observers:      observers,
 	}
 }
 
 func (p *provider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
-	client, err := p.getProvider()
+	candidates, breakerConfig, err := p.candidates(CallChat, req.Model)
 	if err != nil {
 		return nil, err
 	}
-	return client.Chat(ctx, req)
+
+	var errs []error
+	for _, candidate := range candidates {
+		b := p.breaker(candidate.key())
+		if !b.allow(breakerConfig.cooldown) {
+			continue
+		}
+
+		client, err := p.getProvider(candidate)
+		if err != nil {
+			b.release()
+			errs = append(errs, fmt.Errorf("%s: %w", candidate.key(), err))
+			continue
+		}
+
+		callCtx, info := withCallInfo(ctx, p.keys(candidate.key()))
+		start := time.Now()
+		resp, err := client.Chat(callCtx, req)
+		call := info.call(CallChat, candidate, start, err)
+		if err == nil {
+			call.Usage = resp.Usage
+		}
+		p.notify(ctx, call)
+		if err != nil {
+			if !p.recordFailure(ctx, CallChat, candidate, b, breakerConfig, start, err) {
+				return nil, err
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", candidate.key(), err))
+			continue
+		}
+
+		p.observeLatency(CallChat, candidate, call.Latency)
+		p.recordSuccess(candidate, b)
+		return resp, nil
+	}
+
+	return nil, noInstanceError(errs)
 }
 
 func (p *provider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan *ChatStreamResponse, error) {
-	client, err := p.getProvider()
+	candidates, breakerConfig, err := p.candidates(CallChatStream, req.Model)
 	if err != nil {
 		return nil, err
 	}
-	return client.ChatStream(ctx, req)
+
+	var errs []error
+	for _, candidate := range candidates {
+		b := p.breaker(candidate.key())
+		if !b.allow(breakerConfig.cooldown) {
+			continue
+		}
+
+		client, err := p.getProvider(candidate)
+		if err != nil {
+			b.release()
+			errs = append(errs, fmt.Errorf("%s: %w", candidate.key(), err))
+			continue
+		}
+
+		callCtx, info := withCallInfo(ctx, p.keys(candidate.key()))
+		start := time.Now()
+		stream, err := client.ChatStream(callCtx, req)
+		if err != nil {
+			p.notify(ctx, info.call(CallChatStream, candidate, start, err))
+			if !p.recordFailure(ctx, CallChatStream, candidate, b, breakerConfig, start, err) {
+				return nil, err
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", candidate.key(), err))
+			continue
+		}
+
+		// Wait for the first chunk so an instance that fails before sending anything can still be skipped
+		first, ok := <-stream
+		if !ok || first.Err != nil {
+			err := fmt.Errorf("stream closed before any response")
+			if ok {
+				err = first.Err
+			}
+			p.notify(ctx, info.call(CallChatStream, candidate, start, err))
+			if !p.recordFailure(ctx, CallChatStream, candidate, b, breakerConfig, start, err) {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				return nil, err
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", candidate.key(), err))
+			continue
+		}
+
+		// Time to the first chunk, the length of the whole stream depends on the reply
+		p.observeLatency(CallChatStream, candidate, time.Since(start))
+		p.recordSuccess(candidate, b)
+		return forwardStream(ctx, first, stream, func(usage *Usage, err error) {
+			call := info.call(CallChatStream, candidate, start, err)
+			if usage != nil {
+				call.Usage = *usage
+			}
+			p.notify(ctx, call)
+		}), nil
+	}
+
+	return nil, noInstanceError(errs)
+}
+
+// Embed generates embeddings on instances that have EMBEDDING_MODELS configured
+func (p *provider) Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	if len(req.Input) == 0 {
+		return nil, fmt.Errorf("embedding input is empty")
+	}
+
+	candidates, breakerConfig, err := p.candidates(CallEmbed, req.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, candidate := range candidates {
+		b := p.breaker(candidate.key())
+		if !b.allow(breakerConfig.cooldown) {
+			continue
+		}
+
+		client, err := p.getProvider(candidate)
+		if err != nil {
+			b.release()
+			errs = append(errs, fmt.Errorf("%s: %w", candidate.key(), err))
+			continue
+		}
+
+		embedder, ok := client.(Embedder)
+		if !ok {
+			b.release()
+			errs = append(errs, fmt.Errorf("%s: provider does not support embeddings", candidate.key()))
+			continue
+		}
+
+		callCtx, info := withCallInfo(ctx, p.keys(candidate.key()))
+		start := time.Now()
+		resp, err := embedder.Embed(callCtx, req)
+		call := info.call(CallEmbed, candidate, start, err)
+		if err == nil {
+			call.Usage = resp.Usage
+		}
+		p.notify(ctx, call)
+		if err != nil {
+			if !p.recordFailure(ctx, CallEmbed, candidate, b, breakerConfig, start, err) {
+				return nil, err
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", candidate.key(), err))
+			continue
+		}
+
+		p.observeLatency(CallEmbed, candidate, call.Latency)
+		p.recordSuccess(candidate, b)
+		return resp, nil
+	}
+
+	return nil, noInstanceError(errs)
+}
+
+// BreakerStates returns the circuit breaker state of every configured instance
+func (p *provider) BreakerStates() []BreakerState {
+	candidates, _, err := p.instances()
+	if err != nil {
+		return nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].key() < candidates[j].key() })
+
+	states := make([]BreakerState, len(candidates))
+	for i, candidate := range candidates {
+		state, failures, openedAt := p.breaker(candidate.key()).snapshot()
+		states[i] = BreakerState{
+			Provider:            candidate.name,
+			Instance:            candidate.instance.Name,
+			State:               state,
+			ConsecutiveFailures: failures,
+			OpenedAt:            openedAt,
+		}
+	}
+	return states
+}
+
+// KeyStates returns the health of every API key of every configured instance, with the keys masked
+func (p *provider) KeyStates() []KeyState {
+	candidates, _, err := p.instances()
+	if err != nil {
+		return nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].key() < candidates[j].key() })
+
+	var states []KeyState
+	for _, candidate := range candidates {
+		states = append(states, p.keys(candidate.key()).snapshot(candidate.name, candidate.instance)...)
+	}
+	return states
+}
+
+type breakerConfig struct {
+	threshold int
+	cooldown  time.Duration
 }
 
-func (p *provider) getProvider() (Provider, error) {
+// instances returns the enabled instances in config order
+func (p *provider) instances() ([]providerInstance, *configs.Config, error) {
 	cfg, err := configs.GetConfig()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config: %w", err)
+		return nil, nil, fmt.Errorf("failed to get config: %w", err)
 	}
 
-	type providerInstance struct {
-		name     string
-		instance configs.ProviderInstanceConfig
+	names := make([]string, 0, len(cfg.AI.Providers))
+	for name := range cfg.AI.Providers {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
 	var instances []providerInstance
-	for name, prov := range cfg.AI.Providers {
+	for _, name := range names {
+		prov := cfg.AI.Providers[name]
 		if !prov.Enabled {
 			continue
 		}
@@ -68,12 +306,94 @@ func (p *provider) getProvider() (Provider, error) {
 		}
 	}
 
-	// Round Robin selection
-	selected := instances[atomic.AddUint64(&p.instanceCounter, 1)%uint64(len(instances))]
+	if len(instances) == 0 {
+		return nil, cfg, fmt.Errorf("no enabled provider instance")
+	}
+	return instances, cfg, nil
+}
+
+// candidates returns the enabled instances that serve the model, ordered by the routing strategy
+func (p *provider) candidates(kind, model string) ([]providerInstance, breakerConfig, error) {
+	instances, cfg, err := p.instances()
+	if err != nil {
+		return nil, breakerConfig{}, err
+	}
 
+	bc := breakerConfig{
+		threshold: cfg.AI.CircuitBreaker.FailureThreshold,
+		cooldown:  time.Duration(cfg.AI.CircuitBreaker.Cooldown) * time.Second,
+	}
+	if bc.threshold <= 0 {
+		bc.threshold = defaultFailureThreshold
+	}
+	if bc.cooldown <= 0 {
+		bc.cooldown = defaultCooldown
+	}
+
+	serving := instances[:0:0]
+	for _, candidate := range instances {
+		models := candidate.instance.Models
+		if kind == CallEmbed {
+			models = candidate.instance.EmbeddingModels
+			if len(models) == 0 {
+				continue
+			}
+		}
+		if servesModel(models, model) {
+			serving = append(serving, candidate)
+		}
+	}
+
+	switch {
+	case len(serving) > 0:
+		return p.route(cfg.AI.Routing.Strategy, kind, serving), bc, nil
+	case model != "":
+		return nil, bc, fmt.Errorf("no enabled provider instance serves model %s", model)
+	default:
+		return nil, bc, fmt.Errorf("no enabled provider instance with embedding models")
+	}
+}
+
+func (p *provider) breaker(key string) *breaker {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b, exists := p.breakers[key]
+	if !exists {
+		b = newBreaker()
+		p.breakers[key] = b
+	}
+	return b
+}
+
+// keys returns the API key health of an instance
+func (p *provider) keys(key string) *keyHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	h, exists := p.keyHealth[key]
+	if !exists {
+		h = newKeyHealth()
+		p.keyHealth[key] = h
+	}
+	return h
+}
+
+// getProvider returns the cached client of an instance, rebuilding it when the instance config has changed
+func (p *provider) getProvider(selected providerInstance) (Provider, error) {
 	log.Printf("Using %s provider, instance: %s", selected.name, selected.instance.Name)
 
-	counterKey := fmt.Sprintf("%s:%s", selected.name, selected.instance.Name)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	counterKey := selected.key()
+
+	if cached, exists := p.clients[counterKey]; exists {
+		if reflect.DeepEqual(cached.config, selected.instance) {
+			return cached.provider, nil
+		}
+		log.Printf("Config changed for %s provider, instance: %s, rebuilding client", selected.name, selected.instance.Name)
+	}
 
 	keyCounter, exists := p.keyCounters[counterKey]
 	if !exists {
@@ -87,12 +407,162 @@ func (p *provider) getProvider() (Provider, error) {
 		p.modelCounters[counterKey] = modelCounter
 	}
 
-	switch selected.name {
+	config := selected.instance
+	client, err := newClient(selected.name, &config, keyCounter, modelCounter)
+	if err != nil {
+		return nil, err
+	}
+
+	p.clients[counterKey] = &cachedProvider{config: selected.instance, provider: client}
+	// A reloaded config lifts every quarantine, the keys may have been replaced or topped up
+	p.keyHealth[counterKey] = newKeyHealth()
+	return client, nil
+}
+
+// newClient builds the client of a provider type
+func newClient(name string, config *configs.ProviderInstanceConfig, keyCounter *uint64, modelCounter *uint64) (Provider, error) {
+	switch name {
 	case "openai":
-		return NewOpenAI(&selected.instance, keyCounter, modelCounter)
+		return NewOpenAI(config, keyCounter, modelCounter)
 	case "gemini":
-		return NewGemini(&selected.instance, keyCounter, modelCounter)
+		return NewGemini(config, keyCounter, modelCounter)
+	case "anthropic":
+		return NewAnthropic(config, keyCounter, modelCounter)
+	case "ollama":
+		return NewOllama(config, keyCounter, modelCounter)
+	case "replay":
+		return NewReplay(config, keyCounter, modelCounter)
+	default:
+		return nil, fmt.Errorf("unsupported provider: %s", name)
+	}
+}
+
+// recordSuccess closes the instance breaker
+func (p *provider) recordSuccess(selected providerInstance, b *breaker) {
+	if prev := b.success(); prev != BreakerClosed {
+		log.Printf("Circuit breaker closed for %s provider, instance: %s", selected.name, selected.instance.Name)
+	}
+}
+
+// recordFailure updates the instance breaker and latency and reports whether the next instance should be tried
+func (p *provider) recordFailure(ctx context.Context, kind string, selected providerInstance, b *breaker, bc breakerConfig, start time.Time, err error) bool {
+	if errors.Is(err, errUnsupported) {
+		// Capability gaps are not health problems, move on to an instance that may support the request
+		b.release()
+		return true
+	}
+	if ctx.Err() != nil || !isInstanceFailure(err) {
+		// Caller cancellation and invalid requests say nothing about instance health
+		b.release()
+		return false
 	}
 
-	return nil, fmt.Errorf("unsupported provider: %s", selected.name)
+	log.Printf("Request to %s provider, instance: %s failed: %v", selected.name, selected.instance.Name, err)
+	p.observeFailure(kind, selected, time.Since(start))
+	if state := b.failure(bc.threshold); state == BreakerOpen {
+		log.Printf("Circuit breaker open for %s provider, instance: %s", selected.name, selected.instance.Name)
+	}
+	return true
+}
+
+// isInstanceFailure reports whether an error indicates an unhealthy instance rather than a bad request
+func isInstanceFailure(err error) bool {
+	return classifyError(err) != errorBadRequest
+}
+
+// httpStatus extracts the HTTP status code from provider errors, 0 when unknown
+func httpStatus(err error) int {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode
+	}
+
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.HTTPStatusCode
+	}
+
+	var genaiErr genai.APIError
+	if errors.As(err, &genaiErr) {
+		return genaiErr.Code
+	}
+
+	var httpErr *APIError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode
+	}
+
+	return 0
+}
+
+// noInstanceError builds the error returned when every candidate instance failed or was tripped
+func noInstanceError(errs []error) error {
+	if len(errs) == 0 {
+		return fmt.Errorf("no healthy provider instance available: all circuit breakers are open")
+	}
+	return fmt.Errorf("all provider instances failed: %w", errors.Join(errs...))
+}
+
+// notify reports a finished call to the observers
+func (p *provider) notify(ctx context.Context, call *Call) {
+	for _, observer := range p.observers {
+		observer(ctx, call)
+	}
+}
+
+// forwardStream re-emits a peeked first chunk followed by the rest of the stream, calling done with the last usage and error once it ends
+func forwardStream(ctx context.Context, first *ChatStreamResponse, stream <-chan *ChatStreamResponse, done func(usage *Usage, err error)) <-chan *ChatStreamResponse {
+	ch := make(chan *ChatStreamResponse)
+	go func() {
+		defer close(ch)
+
+		var usage *Usage
+		var err error
+		defer func() { done(usage, err) }()
+
+		resp, ok := first, true
+		for ok {
+			if resp.Usage != nil {
+				usage = resp.Usage
+			}
+			if resp.Err != nil {
+				err = resp.Err
+			}
+
+			select {
+			case ch <- resp:
+			case <-ctx.Done():
+				err = ctx.Err()
+				// Drain so the upstream goroutine can exit
+				for range stream {
+				}
+				return
+			}
+			resp, ok = <-stream
+		}
+	}()
+	return ch
+}
+
+// sendStreamError emits the terminal error element of a stream, unless the caller has already gone away
+func sendStreamError(ctx context.Context, ch chan<- *ChatStreamResponse, providerName string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	select {
+	case ch <- &ChatStreamResponse{Object: "chat.completion.chunk", Created: time.Now().Unix(), Provider: providerName, Err: err}:
+	case <-ctx.Done():
+	}
+}
+
+// splitBatches yields consecutive batches of at most size inputs together with the offset of their first input
+func splitBatches(input []string, size int) iter.Seq2[int, []string] {
+	return func(yield func(int, []string) bool) {
+		for offset := 0; offset < len(input); offset += size {
+			if !yield(offset, input[offset:min(offset+size, len(input))]) {
+				return
+			}
+		}
+	}
 }
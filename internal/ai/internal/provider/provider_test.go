@@ -1,10 +1,24 @@
 package provider
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync/atomic"
 	"testing"
+	"time"
+
+	"google.golang.org/genai"
 
 	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/types/errno"
+	"github.com/Done-0/gin-scaffold/internal/utils/errorx"
 )
 
 func TestNewOpenAI(t *testing.T) {
@@ -398,3 +412,1174 @@ func TestConfigShrinkage(t *testing.T) {
 		t.Errorf("final keyCounter = %d, want 15", *keyCounter)
 	}
 }
+
+// newMockOpenAIServer starts an OpenAI-compatible server that captures the decoded request body
+func newMockOpenAIServer(t *testing.T, handler func(w http.ResponseWriter, body map[string]any)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		handler(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newMockOpenAIConfig(baseURL string) *configs.ProviderInstanceConfig {
+	return &configs.ProviderInstanceConfig{
+		Enabled:    true,
+		Name:       "mock",
+		BaseURL:    baseURL,
+		Keys:       []string{"test-key"},
+		Models:     []string{"mock-model"},
+		Timeout:    5,
+		MaxRetries: 0,
+		RateLimit:  "100/s",
+	}
+}
+
+var weatherTool = Tool{
+	Type: ToolTypeFunction,
+	Function: FunctionDefinition{
+		Name:        "get_weather",
+		Description: "Get the current weather",
+		Parameters: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"city": map[string]any{"type": "string"}},
+			"required":   []string{"city"},
+		},
+	},
+}
+
+func TestOpenAIToolCalls(t *testing.T) {
+	var captured map[string]any
+	server := newMockOpenAIServer(t, func(w http.ResponseWriter, body map[string]any) {
+		captured = body
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"id": "chatcmpl-1", "object": "chat.completion", "created": 1, "model": "mock-model",
+			"choices": [{"index": 0, "finish_reason": "tool_calls", "message": {
+				"role": "assistant", "content": "",
+				"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}]
+			}}],
+			"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+		}`)
+	})
+
+	p, err := NewOpenAI(newMockOpenAIConfig(server.URL), new(uint64), new(uint64))
+	if err != nil {
+		t.Fatalf("NewOpenAI() failed: %v", err)
+	}
+
+	resp, err := p.Chat(context.Background(), &ChatRequest{
+		Messages: []Message{
+			{Role: RoleUser, Content: "Weather in Paris?"},
+			{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call_0", Type: ToolTypeFunction, Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Rome"}`}}}},
+			{Role: RoleTool, ToolCallID: "call_0", Content: `{"temp":21}`},
+		},
+		Tools:      []Tool{weatherTool},
+		ToolChoice: &ToolChoice{Type: ToolChoiceFunction, Function: "get_weather"},
+	})
+	if err != nil {
+		t.Fatalf("Chat() failed: %v", err)
+	}
+
+	tools, _ := captured["tools"].([]any)
+	if len(tools) != 1 {
+		t.Fatalf("request tools = %v, want 1 tool", captured["tools"])
+	}
+	choice, _ := captured["tool_choice"].(map[string]any)
+	if fn, _ := choice["function"].(map[string]any); fn["name"] != "get_weather" {
+		t.Errorf("request tool_choice = %v, want get_weather", captured["tool_choice"])
+	}
+	messages, _ := captured["messages"].([]any)
+	if toolMsg, _ := messages[2].(map[string]any); toolMsg["role"] != RoleTool || toolMsg["tool_call_id"] != "call_0" {
+		t.Errorf("request tool message = %v", messages[2])
+	}
+	if assistantMsg, _ := messages[1].(map[string]any); assistantMsg["tool_calls"] == nil {
+		t.Errorf("request assistant message lost tool_calls: %v", messages[1])
+	}
+
+	calls := resp.Choices[0].Message.ToolCalls
+	if len(calls) != 1 || calls[0].ID != "call_1" || calls[0].Function.Name != "get_weather" || calls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("response tool calls = %+v", calls)
+	}
+	if resp.Choices[0].FinishReason != "tool_calls" {
+		t.Errorf("finish reason = %s, want tool_calls", resp.Choices[0].FinishReason)
+	}
+}
+
+func TestOpenAIChatWithoutChoices(t *testing.T) {
+	server := newMockOpenAIServer(t, func(w http.ResponseWriter, body map[string]any) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id": "chatcmpl-1", "object": "chat.completion", "created": 1, "model": "mock-model", "choices": []}`)
+	})
+
+	p, err := NewOpenAI(newMockOpenAIConfig(server.URL), new(uint64), new(uint64))
+	if err != nil {
+		t.Fatalf("NewOpenAI() failed: %v", err)
+	}
+
+	_, err = p.Chat(context.Background(), &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Hi"}}})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Provider != "openai" {
+		t.Fatalf("Chat() error = %v, want an openai API error", err)
+	}
+}
+
+func TestOpenAIToolCallsStream(t *testing.T) {
+	server := newMockOpenAIServer(t, func(w http.ResponseWriter, body map[string]any) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunks := []string{
+			`{"id":"1","object":"chat.completion.chunk","model":"mock-model","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+			`{"id":"1","object":"chat.completion.chunk","model":"mock-model","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+			`{"id":"1","object":"chat.completion.chunk","model":"mock-model","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+			`{"id":"1","object":"chat.completion.chunk","model":"mock-model","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		}
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	p, err := NewOpenAI(newMockOpenAIConfig(server.URL), new(uint64), new(uint64))
+	if err != nil {
+		t.Fatalf("NewOpenAI() failed: %v", err)
+	}
+
+	stream, err := p.ChatStream(context.Background(), &ChatRequest{
+		Messages: []Message{{Role: RoleUser, Content: "Weather in Paris?"}},
+		Tools:    []Tool{weatherTool},
+	})
+	if err != nil {
+		t.Fatalf("ChatStream() failed: %v", err)
+	}
+
+	var id, name, arguments, finishReason string
+	for resp := range stream {
+		if len(resp.Choices) == 0 {
+			continue
+		}
+		for _, call := range resp.Choices[0].Delta.ToolCalls {
+			if call.Index != 0 {
+				t.Errorf("tool call index = %d, want 0", call.Index)
+			}
+			if call.ID != "" {
+				id = call.ID
+			}
+			name += call.Function.Name
+			arguments += call.Function.Arguments
+		}
+		if resp.Choices[0].FinishReason != "" {
+			finishReason = resp.Choices[0].FinishReason
+		}
+	}
+
+	if id != "call_1" || name != "get_weather" || arguments != `{"city":"Paris"}` {
+		t.Errorf("merged tool call = id:%s name:%s args:%s", id, name, arguments)
+	}
+	if finishReason != "tool_calls" {
+		t.Errorf("finish reason = %s, want tool_calls", finishReason)
+	}
+}
+
+func TestGeminiToolContents(t *testing.T) {
+	contents, err := toGeminiContents([]Message{
+		{Role: RoleUser, Content: "Weather in Paris and Rome?"},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{
+			{ID: "call_1", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+			{ID: "call_2", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Rome"}`}},
+		}},
+		{Role: RoleTool, ToolCallID: "call_1", Content: `{"temp":18}`},
+		{Role: RoleTool, ToolCallID: "call_2", Content: "sunny"},
+	})
+	if err != nil {
+		t.Fatalf("toGeminiContents() failed: %v", err)
+	}
+
+	if len(contents) != 3 {
+		t.Fatalf("contents = %d, want 3 (tool results merged into one turn)", len(contents))
+	}
+	if contents[1].Role != genai.RoleModel || len(contents[1].Parts) != 2 || contents[1].Parts[0].FunctionCall.Args["city"] != "Paris" {
+		t.Errorf("model turn = %+v", contents[1])
+	}
+
+	results := contents[2].Parts
+	if len(results) != 2 || results[0].FunctionResponse.Name != "get_weather" || results[0].FunctionResponse.Response["temp"] != float64(18) {
+		t.Errorf("tool result turn = %+v", results)
+	}
+	if results[1].FunctionResponse.Response["output"] != "sunny" {
+		t.Errorf("plain text tool result = %v, want wrapped output", results[1].FunctionResponse.Response)
+	}
+
+	config := toGeminiToolConfig(&ToolChoice{Type: ToolChoiceFunction, Function: "get_weather"})
+	if config.FunctionCallingConfig.Mode != genai.FunctionCallingConfigModeAny || config.FunctionCallingConfig.AllowedFunctionNames[0] != "get_weather" {
+		t.Errorf("tool config = %+v", config.FunctionCallingConfig)
+	}
+}
+
+// initTestConfig writes a config.local.yml into a temp working directory and loads it
+func initTestConfig(t *testing.T, content string) {
+	t.Helper()
+	testDir := t.TempDir()
+	configDir := filepath.Join(testDir, "configs")
+	os.MkdirAll(configDir, 0755)
+	os.WriteFile(filepath.Join(configDir, "config.local.yml"), []byte(content), 0644)
+
+	oldDir, _ := os.Getwd()
+	os.Chdir(testDir)
+	t.Cleanup(func() { os.Chdir(oldDir) })
+
+	if err := configs.New(); err != nil {
+		t.Fatalf("Failed to initialize config: %v", err)
+	}
+}
+
+// openAIInstanceYAML renders one OpenAI-compatible instance entry for initTestConfig
+func openAIInstanceYAML(name, baseURL string) string {
+	return fmt.Sprintf(`
+        - NAME: %q
+          ENABLED: true
+          BASE_URL: %q
+          KEYS: ["test-key"]
+          MODELS: ["mock-model"]
+          TIMEOUT: 5
+          MAX_RETRIES: 0
+          RATE_LIMIT: "100/s"`, name, baseURL)
+}
+
+const mockChatCompletion = `{"id":"1","object":"chat.completion","model":"mock-model","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`
+
+func TestFailoverAndCircuitBreaker(t *testing.T) {
+	var badHits int32
+	bad := newMockOpenAIServer(t, func(w http.ResponseWriter, body map[string]any) {
+		atomic.AddInt32(&badHits, 1)
+		http.Error(w, `{"error":{"message":"upstream down","type":"server_error"}}`, http.StatusBadGateway)
+	})
+	good := newMockOpenAIServer(t, func(w http.ResponseWriter, body map[string]any) {
+		if body["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"ok\"}}]}\n\ndata: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, mockChatCompletion)
+	})
+
+	initTestConfig(t, `AI:
+  CIRCUIT_BREAKER:
+    FAILURE_THRESHOLD: 1
+    COOLDOWN: 60
+  PROVIDERS:
+    openai:
+      ENABLED: true
+      INSTANCES:`+openAIInstanceYAML("bad", bad.URL)+openAIInstanceYAML("good", good.URL))
+
+	pool := New()
+	ctx := context.Background()
+	req := &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}}
+
+	for i := 0; i < 4; i++ {
+		resp, err := pool.Chat(ctx, req)
+		if err != nil {
+			t.Fatalf("request %d: Chat() failed: %v", i, err)
+		}
+		if resp.Choices[0].Message.Content != "ok" {
+			t.Errorf("request %d: content = %s, want ok", i, resp.Choices[0].Message.Content)
+		}
+	}
+
+	stream, err := pool.ChatStream(ctx, req)
+	if err != nil {
+		t.Fatalf("ChatStream() failed: %v", err)
+	}
+	var content string
+	for resp := range stream {
+		if len(resp.Choices) > 0 {
+			content += resp.Choices[0].Delta.Content
+		}
+	}
+	if content != "ok" {
+		t.Errorf("stream content = %s, want ok", content)
+	}
+
+	if hits := atomic.LoadInt32(&badHits); hits != 1 {
+		t.Errorf("bad instance hits = %d, want 1 (skipped while breaker is open)", hits)
+	}
+
+	states := pool.BreakerStates()
+	if len(states) != 2 {
+		t.Fatalf("breaker states = %d, want 2", len(states))
+	}
+	for _, state := range states {
+		want := BreakerClosed
+		if state.Instance == "bad" {
+			want = BreakerOpen
+		}
+		if state.State != want {
+			t.Errorf("instance %s state = %s, want %s", state.Instance, state.State, want)
+		}
+		if (state.OpenedAt != nil) != (want == BreakerOpen) {
+			t.Errorf("instance %s opened_at = %v, want it only while open", state.Instance, state.OpenedAt)
+		}
+	}
+}
+
+func TestFailoverSkipsBadRequest(t *testing.T) {
+	var hits int32
+	handler := func(w http.ResponseWriter, body map[string]any) {
+		atomic.AddInt32(&hits, 1)
+		http.Error(w, `{"error":{"message":"invalid messages","type":"invalid_request_error"}}`, http.StatusBadRequest)
+	}
+	first := newMockOpenAIServer(t, handler)
+	second := newMockOpenAIServer(t, handler)
+
+	initTestConfig(t, `AI:
+  PROVIDERS:
+    openai:
+      ENABLED: true
+      INSTANCES:`+openAIInstanceYAML("first", first.URL)+openAIInstanceYAML("second", second.URL))
+
+	pool := New()
+	_, err := pool.Chat(context.Background(), &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}})
+	if err == nil || !strings.Contains(err.Error(), "invalid messages") {
+		t.Fatalf("Chat() error = %v, want bad request error", err)
+	}
+	if hits != 1 {
+		t.Errorf("hits = %d, want 1 (bad requests are not retried on other instances)", hits)
+	}
+	for _, state := range pool.BreakerStates() {
+		if state.State != BreakerClosed || state.ConsecutiveFailures != 0 {
+			t.Errorf("instance %s = %+v, want closed breaker", state.Instance, state)
+		}
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	b := newBreaker()
+	cooldown := 20 * time.Millisecond
+
+	b.failure(2)
+	if !b.allow(cooldown) {
+		t.Fatal("breaker should stay closed below threshold")
+	}
+	if state := b.failure(2); state != BreakerOpen {
+		t.Fatalf("state = %s, want open at threshold", state)
+	}
+	if b.allow(cooldown) {
+		t.Fatal("open breaker should reject during cooldown")
+	}
+
+	time.Sleep(cooldown)
+	if !b.allow(cooldown) {
+		t.Fatal("breaker should allow a probe after cooldown")
+	}
+	if b.allow(cooldown) {
+		t.Fatal("half-open breaker should allow only one probe")
+	}
+	if state := b.failure(2); state != BreakerOpen {
+		t.Fatalf("failed probe state = %s, want open", state)
+	}
+
+	time.Sleep(cooldown)
+	b.allow(cooldown)
+	if prev := b.success(); prev != BreakerHalfOpen {
+		t.Errorf("previous state = %s, want half_open", prev)
+	}
+	if state, failures, _ := b.snapshot(); state != BreakerClosed || failures != 0 {
+		t.Errorf("state = %s failures = %d, want closed and reset", state, failures)
+	}
+}
+
+func TestPoolReusesInstanceClients(t *testing.T) {
+	server := newMockOpenAIServer(t, func(w http.ResponseWriter, body map[string]any) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, mockChatCompletion)
+	})
+
+	instanceYAML := func(rateLimit string) string {
+		return fmt.Sprintf(`AI:
+  PROVIDERS:
+    openai:
+      ENABLED: true
+      INSTANCES:
+        - NAME: "limited"
+          ENABLED: true
+          BASE_URL: %q
+          KEYS: ["test-key"]
+          MODELS: ["mock-model"]
+          TIMEOUT: 5
+          RATE_LIMIT: %q`, server.URL, rateLimit)
+	}
+	initTestConfig(t, instanceYAML("1/min"))
+
+	pool := New().(*provider)
+	req := &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}}
+
+	if _, err := pool.Chat(context.Background(), req); err != nil {
+		t.Fatalf("first Chat() failed: %v", err)
+	}
+	first := pool.clients["openai:limited"].provider
+
+	// The limiter survives between calls, so the second request within the minute must wait
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Chat(ctx, req); err == nil {
+		t.Fatal("second Chat() should be throttled by the persistent rate limiter")
+	}
+	if pool.clients["openai:limited"].provider != first {
+		t.Error("client should be reused while the instance config is unchanged")
+	}
+
+	// A config reload for the instance rebuilds the client and its limiter
+	initTestConfig(t, instanceYAML("100/s"))
+	if _, err := pool.Chat(context.Background(), req); err != nil {
+		t.Fatalf("Chat() after config change failed: %v", err)
+	}
+	if pool.clients["openai:limited"].provider == first {
+		t.Error("client should be rebuilt after the instance config changed")
+	}
+}
+
+func TestPoolEmbed(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&requests, 1)
+
+		var body struct {
+			Input      []string `json:"input"`
+			Model      string   `json:"model"`
+			Dimensions int      `json:"dimensions"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Model != "mock-embedding" || body.Dimensions != 8 {
+			t.Errorf("model = %s, dimensions = %d", body.Model, body.Dimensions)
+		}
+
+		data := make([]map[string]any, len(body.Input))
+		for i, input := range body.Input {
+			var n float32
+			fmt.Sscanf(input, "text-%f", &n)
+			data[i] = map[string]any{"object": "embedding", "index": i, "embedding": []float32{n}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"object": "list",
+			"model":  body.Model,
+			"data":   data,
+			"usage":  map[string]int{"prompt_tokens": len(body.Input), "total_tokens": len(body.Input)},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	// The chat-only instance must never receive embedding requests
+	initTestConfig(t, fmt.Sprintf(`AI:
+  PROVIDERS:
+    openai:
+      ENABLED: true
+      INSTANCES:%s%s
+          EMBEDDING_MODELS: ["mock-embedding"]`, openAIInstanceYAML("chat-only", "http://127.0.0.1:1"), openAIInstanceYAML("embedder", server.URL)))
+
+	input := make([]string, openAIEmbeddingBatchSize+10)
+	for i := range input {
+		input[i] = fmt.Sprintf("text-%d", i)
+	}
+
+	pool := New()
+	for range 2 {
+		resp, err := pool.Embed(context.Background(), &EmbeddingRequest{Input: input, Dimensions: 8})
+		if err != nil {
+			t.Fatalf("Embed() failed: %v", err)
+		}
+		if len(resp.Data) != len(input) || resp.Usage.TotalTokens != len(input) {
+			t.Fatalf("data = %d, usage = %+v, want %d embeddings", len(resp.Data), resp.Usage, len(input))
+		}
+		for i, embedding := range resp.Data {
+			if embedding.Index != i || embedding.Embedding[0] != float32(i) {
+				t.Fatalf("embedding %d = index %d, value %v", i, embedding.Index, embedding.Embedding)
+			}
+		}
+	}
+	if requests != 4 {
+		t.Errorf("requests = %d, want 4 (two batches per call)", requests)
+	}
+
+	if _, err := pool.Embed(context.Background(), &EmbeddingRequest{}); err == nil {
+		t.Error("Embed() with empty input should fail")
+	}
+}
+
+func TestMultimodalContent(t *testing.T) {
+	msg := Message{
+		Role:    RoleUser,
+		Content: "Describe these",
+		Parts: []ContentPart{
+			{Type: PartTypeImageURL, URL: "https://example.com/cat.png", Detail: "low"},
+			{Type: PartTypeImage, MIMEType: "image/png", Data: "aGVsbG8="},
+			{Type: PartTypeFile, URL: "gs://bucket/report.pdf"},
+		},
+	}
+
+	// OpenAI chat completions accept images only
+	if _, err := toOpenAIMessages([]Message{msg}); !errors.Is(err, errUnsupported) {
+		t.Errorf("toOpenAIMessages() with a PDF part = %v, want errUnsupported", err)
+	}
+	messages, err := toOpenAIMessages([]Message{{Role: RoleUser, Content: "Describe these", Parts: msg.Parts[:2]}, {Role: RoleUser, Content: "plain"}})
+	if err != nil {
+		t.Fatalf("toOpenAIMessages() failed: %v", err)
+	}
+	parts := messages[0].MultiContent
+	if messages[0].Content != "" || len(parts) != 3 || parts[0].Text != "Describe these" {
+		t.Fatalf("multi content = %+v", parts)
+	}
+	if parts[1].ImageURL.URL != "https://example.com/cat.png" || parts[1].ImageURL.Detail != "low" || parts[2].ImageURL.URL != "data:image/png;base64,aGVsbG8=" {
+		t.Errorf("image parts = %+v, %+v", parts[1].ImageURL, parts[2].ImageURL)
+	}
+	if messages[1].Content != "plain" || messages[1].MultiContent != nil {
+		t.Errorf("plain message = %+v, want string content", messages[1])
+	}
+
+	contents, err := toGeminiContents([]Message{msg})
+	if err != nil {
+		t.Fatalf("toGeminiContents() failed: %v", err)
+	}
+	geminiParts := contents[0].Parts
+	if len(geminiParts) != 4 || geminiParts[0].Text != "Describe these" {
+		t.Fatalf("gemini parts = %d, want 4", len(geminiParts))
+	}
+	if geminiParts[1].FileData.FileURI != "https://example.com/cat.png" || geminiParts[1].FileData.MIMEType != "image/png" {
+		t.Errorf("image url part = %+v", geminiParts[1].FileData)
+	}
+	if string(geminiParts[2].InlineData.Data) != "hello" || geminiParts[2].InlineData.MIMEType != "image/png" {
+		t.Errorf("inline image part = %+v", geminiParts[2].InlineData)
+	}
+	if geminiParts[3].FileData.MIMEType != "application/pdf" {
+		t.Errorf("file part = %+v, want MIME type guessed from extension", geminiParts[3].FileData)
+	}
+
+	if _, err := toGeminiContents([]Message{{Role: RoleUser, Parts: []ContentPart{{Type: PartTypeImage, Data: "not base64!"}}}}); !errors.Is(err, errInvalidRequest) {
+		t.Errorf("toGeminiContents() with bad base64 = %v, want errInvalidRequest", err)
+	}
+}
+
+func TestFailoverSkipsUnsupportedContent(t *testing.T) {
+	server := newMockOpenAIServer(t, func(w http.ResponseWriter, body map[string]any) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, mockChatCompletion)
+	})
+
+	initTestConfig(t, fmt.Sprintf(`AI:
+  PROVIDERS:
+    ollama:
+      ENABLED: true
+      INSTANCES:
+        - NAME: "local"
+          ENABLED: true
+          BASE_URL: "http://127.0.0.1:1"
+          MODELS: ["llava"]
+          TIMEOUT: 5
+          RATE_LIMIT: "100/s"
+    openai:
+      ENABLED: true
+      INSTANCES:%s`, openAIInstanceYAML("vision", server.URL)))
+
+	pool := New()
+	req := &ChatRequest{Messages: []Message{{
+		Role:  RoleUser,
+		Parts: []ContentPart{{Type: PartTypeImageURL, URL: "https://example.com/cat.png"}},
+	}}}
+
+	// Whichever instance the rotation starts from, the image URL must end up on the OpenAI instance
+	for range 4 {
+		if _, err := pool.Chat(context.Background(), req); err != nil {
+			t.Fatalf("Chat() failed: %v", err)
+		}
+	}
+	for _, state := range pool.BreakerStates() {
+		if state.ConsecutiveFailures != 0 {
+			t.Errorf("%s:%s failures = %d, unsupported content must not count as a failure", state.Provider, state.Instance, state.ConsecutiveFailures)
+		}
+	}
+}
+
+func TestOpenAIGenerationParams(t *testing.T) {
+	var captured map[string]any
+	server := newMockOpenAIServer(t, func(w http.ResponseWriter, body map[string]any) {
+		captured = body
+		w.Header().Set("Content-Type", "application/json")
+		// Models sometimes wrap JSON output in a markdown code fence
+		content, _ := json.Marshal("```json\n{\"city\":\"Paris\",\"temp\":18}\n```")
+		fmt.Fprintf(w, `{"id":"1","object":"chat.completion","model":"mock-model","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":%s}}]}`, content)
+	})
+
+	config := newMockOpenAIConfig(server.URL)
+	config.MaxTokens = 1000
+	config.Temperature = 0.7
+	p, err := NewOpenAI(config, new(uint64), new(uint64))
+	if err != nil {
+		t.Fatalf("NewOpenAI() failed: %v", err)
+	}
+
+	seed := 42
+	resp, err := p.Chat(context.Background(), &ChatRequest{
+		Messages:    []Message{{Role: RoleUser, Content: "Weather in Paris as JSON"}},
+		MaxTokens:   64,
+		Temperature: new(float32),
+		Stop:        []string{"END"},
+		Seed:        &seed,
+		ResponseFormat: &ResponseFormat{
+			Type:   ResponseFormatJSONSchema,
+			Name:   "weather",
+			Schema: map[string]any{"type": "object", "properties": map[string]any{"city": map[string]any{"type": "string"}}},
+			Strict: true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Chat() failed: %v", err)
+	}
+
+	if captured["max_tokens"] != float64(64) || captured["seed"] != float64(42) {
+		t.Errorf("max_tokens = %v, seed = %v, want per-request overrides", captured["max_tokens"], captured["seed"])
+	}
+	if temperature, _ := captured["temperature"].(float64); captured["temperature"] == nil || temperature > 1e-6 {
+		t.Errorf("temperature = %v, want an explicit zero", captured["temperature"])
+	}
+	if stop, _ := captured["stop"].([]any); len(stop) != 1 || stop[0] != "END" {
+		t.Errorf("stop = %v", captured["stop"])
+	}
+	format, _ := captured["response_format"].(map[string]any)
+	schema, _ := format["json_schema"].(map[string]any)
+	if format["type"] != ResponseFormatJSONSchema || schema["name"] != "weather" || schema["strict"] != true || schema["schema"] == nil {
+		t.Errorf("response_format = %v", captured["response_format"])
+	}
+
+	type weather struct {
+		City string `json:"city" validate:"required"`
+		Temp int    `json:"temp" validate:"gte=-100,lte=100"`
+	}
+	result, err := DecodeJSON[weather](resp)
+	if err != nil {
+		t.Fatalf("DecodeJSON() failed: %v", err)
+	}
+	if result.City != "Paris" || result.Temp != 18 {
+		t.Errorf("decoded = %+v", result)
+	}
+
+	resp.Choices[0].Message.Content = `{"temp":18}`
+	if _, err := DecodeJSON[weather](resp); err == nil {
+		t.Error("DecodeJSON() should fail validation without the required city")
+	}
+
+	if _, err := p.Chat(context.Background(), &ChatRequest{
+		Messages:       []Message{{Role: RoleUser, Content: "hi"}},
+		ResponseFormat: &ResponseFormat{Type: ResponseFormatJSONSchema},
+	}); !errors.Is(err, errInvalidRequest) {
+		t.Errorf("Chat() with a schemaless json_schema format = %v, want errInvalidRequest", err)
+	}
+}
+
+func TestGeminiGenerationConfig(t *testing.T) {
+	p := &geminiProvider{config: &configs.ProviderInstanceConfig{MaxTokens: 1000, Temperature: 0.7, TopP: 0.9, TopK: 40}}
+
+	config, err := p.buildConfig(&ChatRequest{})
+	if err != nil {
+		t.Fatalf("buildConfig() failed: %v", err)
+	}
+	if *config.Temperature != 0.7 || config.MaxOutputTokens != 1000 || *config.TopK != 40 || config.ResponseMIMEType != "" {
+		t.Errorf("default config = %+v, want instance values", config)
+	}
+
+	seed := 7
+	schema := map[string]any{"type": "object"}
+	config, err = p.buildConfig(&ChatRequest{
+		MaxTokens:      64,
+		Temperature:    new(float32),
+		TopK:           5,
+		Stop:           []string{"END"},
+		Seed:           &seed,
+		ResponseFormat: &ResponseFormat{Type: ResponseFormatJSONSchema, Schema: schema},
+	})
+	if err != nil {
+		t.Fatalf("buildConfig() failed: %v", err)
+	}
+	if *config.Temperature != 0 || config.MaxOutputTokens != 64 || *config.TopK != 5 || *config.TopP != 0.9 {
+		t.Errorf("override config = %+v", config)
+	}
+	if *config.Seed != 7 || len(config.StopSequences) != 1 {
+		t.Errorf("seed = %v, stop = %v", *config.Seed, config.StopSequences)
+	}
+	if config.ResponseMIMEType != "application/json" || config.ResponseJsonSchema == nil {
+		t.Errorf("response mime type = %q, schema = %v", config.ResponseMIMEType, config.ResponseJsonSchema)
+	}
+}
+
+func TestGeminiSystemInstruction(t *testing.T) {
+	p := &geminiProvider{config: &configs.ProviderInstanceConfig{}}
+
+	config, err := p.buildConfig(&ChatRequest{Messages: []Message{
+		{Role: RoleSystem, Content: "Be brief.", Parts: []ContentPart{{Type: PartTypeText, Text: "Answer in French."}}},
+		{Role: RoleSystem, Parts: []ContentPart{{Type: PartTypeText, Text: "No emojis."}}},
+		{Role: RoleUser, Content: "Hi"},
+	}})
+	if err != nil {
+		t.Fatalf("buildConfig() failed: %v", err)
+	}
+	var texts []string
+	for _, part := range config.SystemInstruction.Parts {
+		texts = append(texts, part.Text)
+	}
+	if got := strings.Join(texts, "|"); got != "Be brief.|Answer in French.|No emojis." {
+		t.Errorf("system instruction = %s", got)
+	}
+
+	if _, err := p.buildConfig(&ChatRequest{Messages: []Message{
+		{Role: RoleSystem, Parts: []ContentPart{{Type: PartTypeImageURL, URL: "https://example.com/cat.png"}}},
+	}}); !errors.Is(err, errInvalidRequest) {
+		t.Errorf("buildConfig() with an image in a system message = %v, want errInvalidRequest", err)
+	}
+}
+
+func TestOpenAIStreamError(t *testing.T) {
+	server := newMockOpenAIServer(t, func(w http.ResponseWriter, body map[string]any) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"id":"1","object":"chat.completion.chunk","model":"mock-model","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"error":{"message":"upstream overloaded","type":"server_error"}}`+"\n\n")
+	})
+
+	p, err := NewOpenAI(newMockOpenAIConfig(server.URL), new(uint64), new(uint64))
+	if err != nil {
+		t.Fatalf("NewOpenAI() failed: %v", err)
+	}
+
+	stream, err := p.ChatStream(context.Background(), &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Hi"}}})
+	if err != nil {
+		t.Fatalf("ChatStream() failed: %v", err)
+	}
+
+	var content string
+	var last *ChatStreamResponse
+	for resp := range stream {
+		if len(resp.Choices) > 0 {
+			content += resp.Choices[0].Delta.Content
+		}
+		last = resp
+	}
+
+	if content != "Hel" {
+		t.Errorf("content = %q, want the chunks sent before the error", content)
+	}
+	if last == nil || last.Err == nil || !strings.Contains(last.Err.Error(), "upstream overloaded") {
+		t.Fatalf("last element = %+v, want the stream error", last)
+	}
+}
+
+func TestOpenAIStreamCancel(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	server := newMockOpenAIServer(t, func(w http.ResponseWriter, body map[string]any) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for range 3 {
+			fmt.Fprint(w, `data: {"id":"1","object":"chat.completion.chunk","model":"mock-model","choices":[{"index":0,"delta":{"content":"Hi"}}]}`+"\n\n")
+		}
+		w.(http.Flusher).Flush()
+		<-done
+	})
+
+	p, err := NewOpenAI(newMockOpenAIConfig(server.URL), new(uint64), new(uint64))
+	if err != nil {
+		t.Fatalf("NewOpenAI() failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := p.ChatStream(ctx, &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Hi"}}})
+	if err != nil {
+		t.Fatalf("ChatStream() failed: %v", err)
+	}
+	<-stream
+
+	// A consumer gone with its context must not leave the stream blocked on the next chunk
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := <-stream; ok {
+		t.Errorf("stream still open after the context was canceled")
+	}
+}
+
+func TestFailoverSkipsFailedStream(t *testing.T) {
+	ollama := newMockOllamaServer(t, func(w http.ResponseWriter, body map[string]any) {
+		fmt.Fprintln(w, `{"error":"model runner crashed"}`)
+	})
+	openAI := newMockOpenAIServer(t, func(w http.ResponseWriter, body map[string]any) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"id":"1","object":"chat.completion.chunk","model":"mock-model","choices":[{"index":0,"delta":{"content":"ok"},"finish_reason":"stop"}]}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	initTestConfig(t, fmt.Sprintf(`AI:
+  PROVIDERS:
+    ollama:
+      ENABLED: true
+      INSTANCES:
+        - NAME: "local"
+          ENABLED: true
+          BASE_URL: %q
+          MODELS: ["llama3"]
+          TIMEOUT: 5
+          RATE_LIMIT: "100/s"
+    openai:
+      ENABLED: true
+      INSTANCES:%s`, ollama.URL, openAIInstanceYAML("remote", openAI.URL)))
+
+	pool := New()
+	// Whichever instance the rotation starts from, the stream must be served by the OpenAI instance
+	for range 2 {
+		stream, err := pool.ChatStream(context.Background(), &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Hi"}}})
+		if err != nil {
+			t.Fatalf("ChatStream() failed: %v", err)
+		}
+		for resp := range stream {
+			if resp.Err != nil || resp.Provider != "openai" {
+				t.Errorf("stream element = %+v, want openai content", resp)
+			}
+		}
+	}
+
+	for _, state := range pool.BreakerStates() {
+		if state.Provider == "ollama" && state.ConsecutiveFailures == 0 {
+			t.Error("ollama stream error should count as an instance failure")
+		}
+	}
+}
+
+func TestGeminiChat(t *testing.T) {
+	var captured map[string]any
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&captured)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"candidates": [{"content": {"role": "model", "parts": [{"text": "Bonjour"}]}, "finishReason": "STOP"}],
+			"usageMetadata": {"promptTokenCount": 12, "candidatesTokenCount": 2, "totalTokenCount": 14}
+		}`)
+	}))
+	t.Cleanup(server.Close)
+
+	includeThoughts := false
+	config := newMockOpenAIConfig(server.URL)
+	config.IncludeThoughts = &includeThoughts
+	p, err := NewGemini(config, new(uint64), new(uint64))
+	if err != nil {
+		t.Fatalf("NewGemini() failed: %v", err)
+	}
+
+	resp, err := p.Chat(context.Background(), &ChatRequest{
+		Messages: []Message{
+			{Role: RoleSystem, Content: "Answer in French"},
+			{Role: RoleUser, Content: "Hello"},
+			{Role: RoleAssistant, Content: "Salut"},
+			{Role: RoleUser, Content: "Say hello again"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Chat() failed: %v", err)
+	}
+
+	if path != "/v1beta/models/mock-model:generateContent" {
+		t.Errorf("path = %s, want the request sent to BASE_URL", path)
+	}
+	if resp.Choices[0].Message.Content != "Bonjour" || resp.Usage.TotalTokens != 14 {
+		t.Errorf("response = %+v", resp)
+	}
+
+	system, _ := captured["systemInstruction"].(map[string]any)
+	if parts, _ := system["parts"].([]any); len(parts) != 1 || parts[0].(map[string]any)["text"] != "Answer in French" {
+		t.Errorf("systemInstruction = %v", captured["systemInstruction"])
+	}
+	contents, _ := captured["contents"].([]any)
+	var roles []string
+	for _, content := range contents {
+		roles = append(roles, content.(map[string]any)["role"].(string))
+	}
+	if strings.Join(roles, ",") != "user,model,user" {
+		t.Errorf("content roles = %v, want user,model,user without the system message", roles)
+	}
+
+	generation, _ := captured["generationConfig"].(map[string]any)
+	if _, exists := generation["topK"]; exists {
+		t.Errorf("topK = %v, want it omitted when TOP_K is 0", generation["topK"])
+	}
+	if thinking, _ := generation["thinkingConfig"].(map[string]any); thinking["includeThoughts"] == true {
+		t.Errorf("thinkingConfig = %v, want thoughts disabled", thinking)
+	}
+}
+
+func TestPoolCallObserver(t *testing.T) {
+	server := newMockOpenAIServer(t, func(w http.ResponseWriter, body map[string]any) {
+		if body["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, `data: {"id":"1","object":"chat.completion.chunk","model":"mock-model","choices":[{"index":0,"delta":{"content":"ok"},"finish_reason":"stop"}]}`+"\n\n")
+			fmt.Fprint(w, `data: {"id":"1","object":"chat.completion.chunk","model":"mock-model","choices":[],"usage":{"prompt_tokens":4,"completion_tokens":2,"total_tokens":6}}`+"\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"mock-model","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`)
+	})
+
+	initTestConfig(t, fmt.Sprintf(`AI:
+  PROVIDERS:
+    openai:
+      ENABLED: true
+      INSTANCES:
+        - NAME: "official"
+          ENABLED: true
+          BASE_URL: %q
+          KEYS: ["key-0", "key-1"]
+          MODELS: ["mock-model"]
+          TIMEOUT: 5
+          RATE_LIMIT: "100/s"`, server.URL))
+
+	calls := make(chan *Call, 2)
+	pool := New(func(ctx context.Context, call *Call) { calls <- call })
+
+	if _, err := pool.Chat(context.Background(), &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Hi"}}}); err != nil {
+		t.Fatalf("Chat() failed: %v", err)
+	}
+	call := <-calls
+	if call.Kind != CallChat || call.Provider != "openai" || call.Instance != "official" || call.Model != "mock-model" || call.KeyIndex != 0 {
+		t.Errorf("chat call = %+v", call)
+	}
+	if call.Usage.TotalTokens != 4 || call.Err != nil || call.Latency <= 0 {
+		t.Errorf("chat call usage = %+v, err = %v, latency = %v", call.Usage, call.Err, call.Latency)
+	}
+
+	stream, err := pool.ChatStream(context.Background(), &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Hi"}}})
+	if err != nil {
+		t.Fatalf("ChatStream() failed: %v", err)
+	}
+	for range stream {
+	}
+	call = <-calls
+	if call.Kind != CallChatStream || call.KeyIndex != 1 || call.Usage.TotalTokens != 6 || call.Err != nil {
+		t.Errorf("stream call = %+v, want the final stream usage on the second key", call)
+	}
+}
+
+func TestContextGuard(t *testing.T) {
+	var hits int32
+	var captured map[string]any
+	server := newMockOpenAIServer(t, func(w http.ResponseWriter, body map[string]any) {
+		atomic.AddInt32(&hits, 1)
+		captured = body
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, mockChatCompletion)
+	})
+
+	guardYAML := func(policy string) string {
+		return `AI:
+  CONTEXT_GUARD:
+    POLICY: "` + policy + `"
+  PROVIDERS:
+    openai:
+      ENABLED: true
+      INSTANCES:` + openAIInstanceYAML("small", server.URL) + `
+          CONTEXT_WINDOWS:
+            - MODEL: "mock"
+              TOKENS: 40`
+	}
+
+	// Without a tokenizer file every 40 runes count 10 tokens, plus 3 per message and 3 for the reply: 46 in total
+	turn := strings.Repeat("a", 40)
+	req := &ChatRequest{Messages: []Message{
+		{Role: RoleSystem, Content: "sys"},
+		{Role: RoleUser, Content: turn},
+		{Role: RoleAssistant, Content: turn},
+		{Role: RoleUser, Content: turn},
+	}}
+
+	initTestConfig(t, guardYAML(ContextPolicyReject))
+	_, err := New().Chat(context.Background(), req)
+	var statusErr errorx.StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code() != errno.ErrAIContextTooLong {
+		t.Fatalf("Chat() error = %v, want ErrAIContextTooLong", err)
+	}
+	if params := statusErr.Params(); params["tokens"] != "46" || params["limit"] != "40" {
+		t.Errorf("error params = %v, want 46 tokens over a limit of 40", params)
+	}
+	if hits != 0 {
+		t.Errorf("hits = %d, want 0 (rejected before dispatch)", hits)
+	}
+
+	initTestConfig(t, guardYAML(ContextPolicyTruncate))
+	if _, err := New().Chat(context.Background(), req); err != nil {
+		t.Fatalf("Chat() with truncation failed: %v", err)
+	}
+	messages, _ := captured["messages"].([]any)
+	if len(messages) != 3 {
+		t.Fatalf("sent %d messages, want 3 after dropping the oldest turn", len(messages))
+	}
+	if first := messages[0].(map[string]any); first["role"] != RoleSystem {
+		t.Errorf("first message role = %v, want the system message kept", first["role"])
+	}
+	if second := messages[1].(map[string]any); second["role"] != RoleAssistant {
+		t.Errorf("second message role = %v, want the oldest user turn dropped", second["role"])
+	}
+	if len(req.Messages) != 4 {
+		t.Errorf("caller messages = %d, want the request left untouched", len(req.Messages))
+	}
+}
+
+// newMockGeminiStreamServer serves Gemini streams, fail decides the status of a request by its API key, 0 streams
+func newMockGeminiStreamServer(t *testing.T, fail func(key string) int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status := fail(r.Header.Get("x-goog-api-key")); status != 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			fmt.Fprintf(w, `{"error":{"code":%d,"message":"rejected","status":"FAILED"}}`, status)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Bon"}]}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"candidates":[{"content":{"role":"model","parts":[{"text":"jour"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":3,"candidatesTokenCount":2,"totalTokenCount":5}}`+"\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGeminiChatStreamRetry(t *testing.T) {
+	var hits int32
+	server := newMockGeminiStreamServer(t, func(key string) int {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			return http.StatusTooManyRequests
+		}
+		return 0
+	})
+
+	config := newMockOpenAIConfig(server.URL)
+	config.MaxRetries = 1
+	config.Retry.BaseDelay = 1
+	p, err := NewGemini(config, new(uint64), new(uint64))
+	if err != nil {
+		t.Fatalf("NewGemini() failed: %v", err)
+	}
+
+	stream, err := p.ChatStream(context.Background(), &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Hello"}}})
+	if err != nil {
+		t.Fatalf("ChatStream() failed: %v, want the 429 on the first chunk retried", err)
+	}
+	var content string
+	var usage *Usage
+	for chunk := range stream {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		content += chunk.Choices[0].Delta.Content
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+	if content != "Bonjour" || usage == nil || usage.TotalTokens != 5 {
+		t.Errorf("content = %q, usage = %+v, want Bonjour with 5 tokens", content, usage)
+	}
+	if hits != 2 {
+		t.Errorf("hits = %d, want 2", hits)
+	}
+
+	// Without retries left the first chunk error is returned by ChatStream rather than inside the stream
+	atomic.StoreInt32(&hits, 0)
+	config.MaxRetries = 0
+	if _, err := p.ChatStream(context.Background(), &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Hello"}}}); classifyError(err) != errorRateLimited {
+		t.Errorf("ChatStream() error = %v, want the 429", err)
+	}
+}
+
+// newMockGeminiServer serves body to generateContent calls and the SSE chunks to streamGenerateContent calls
+func newMockGeminiServer(t *testing.T, body string, chunks ...string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ":streamGenerateContent") {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, chunk := range chunks {
+				fmt.Fprintf(w, "data: %s\n\n", chunk)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGeminiIncompleteResponses(t *testing.T) {
+	ctx := context.Background()
+	req := &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Hello"}}}
+	newProvider := func(server *httptest.Server) Provider {
+		p, err := NewGemini(newMockOpenAIConfig(server.URL), new(uint64), new(uint64))
+		if err != nil {
+			t.Fatalf("NewGemini() failed: %v", err)
+		}
+		return p
+	}
+
+	t.Run("BlockedPrompt", func(t *testing.T) {
+		blocked := `{"promptFeedback":{"blockReason":"SAFETY"}}`
+		p := newProvider(newMockGeminiServer(t, blocked, blocked))
+		if _, err := p.Chat(ctx, req); !errors.Is(err, errInvalidRequest) {
+			t.Errorf("Chat() error = %v, want errInvalidRequest", err)
+		}
+
+		stream, err := p.ChatStream(ctx, req)
+		if err != nil {
+			t.Fatalf("ChatStream() failed: %v", err)
+		}
+		var streamErr error
+		for chunk := range stream {
+			streamErr = chunk.Err
+		}
+		if !errors.Is(streamErr, errInvalidRequest) {
+			t.Errorf("stream error = %v, want errInvalidRequest", streamErr)
+		}
+	})
+
+	t.Run("NoUsage", func(t *testing.T) {
+		p := newProvider(newMockGeminiServer(t, `{"candidates":[{"content":{"role":"model","parts":[{"text":"Hi"}]},"finishReason":"STOP"}]}`))
+		resp, err := p.Chat(ctx, req)
+		if err != nil {
+			t.Fatalf("Chat() failed: %v", err)
+		}
+		if resp.Choices[0].Message.Content != "Hi" || resp.Usage.TotalTokens != 0 {
+			t.Errorf("response = %+v", resp)
+		}
+	})
+
+	t.Run("FinishChunkWithoutParts", func(t *testing.T) {
+		p := newProvider(newMockGeminiServer(t, "",
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":"Bon"}]}}]}`,
+			`{"candidates":[{"finishReason":"MAX_TOKENS"}],"usageMetadata":{"promptTokenCount":3,"candidatesTokenCount":4,"totalTokenCount":7}}`,
+		))
+		stream, err := p.ChatStream(ctx, req)
+		if err != nil {
+			t.Fatalf("ChatStream() failed: %v", err)
+		}
+		var acc StreamAccumulator
+		for chunk := range stream {
+			acc.Add(chunk)
+		}
+		resp, ok := acc.Response()
+		if !ok || resp.Choices[0].Message.Content != "Bon" || resp.Choices[0].FinishReason != string(genai.FinishReasonMaxTokens) || resp.Usage.TotalTokens != 7 {
+			t.Errorf("accumulated response = %+v, want the finish reason and usage of the last chunk", resp)
+		}
+	})
+}
@@ -0,0 +1,213 @@
+// Package provider implements AI provider interfaces
+// Author: Done-0
+// Created: 2025-08-31
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/utils/file"
+)
+
+// Replay modes
+const (
+	ReplayModeRecord = "record" // Forward calls to the upstream provider and write fixtures
+	ReplayModeReplay = "replay" // Serve calls from fixtures only, without network
+)
+
+// replayProvider records calls of an upstream provider to fixtures and serves them back offline
+type replayProvider struct {
+	config   *configs.ProviderInstanceConfig
+	mode     string
+	upstream Provider // Set in record mode
+}
+
+// replayFixture one recorded request and its response, the file name is derived from the kind and request
+type replayFixture struct {
+	Kind      string                `json:"kind"`
+	Request   any                   `json:"request"`
+	Response  *ChatResponse         `json:"response,omitempty"`  // chat
+	Stream    []*ChatStreamResponse `json:"stream,omitempty"`    // chat_stream, chunks in order
+	Embedding *EmbeddingResponse    `json:"embedding,omitempty"` // embed
+}
+
+func NewReplay(config *configs.ProviderInstanceConfig, keyCounter *uint64, modelCounter *uint64) (Provider, error) {
+	if config.FixtureDir == "" {
+		return nil, fmt.Errorf("replay provider requires FIXTURE_DIR")
+	}
+
+	p := &replayProvider{config: config, mode: config.Mode}
+	switch p.mode {
+	case "", ReplayModeReplay:
+		p.mode = ReplayModeReplay
+	case ReplayModeRecord:
+		if config.Upstream == "" || config.Upstream == "replay" {
+			return nil, fmt.Errorf("replay provider in record mode requires an UPSTREAM provider type, got %q", config.Upstream)
+		}
+		upstream, err := newClient(config.Upstream, config, keyCounter, modelCounter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create upstream %s provider: %w", config.Upstream, err)
+		}
+		p.upstream = upstream
+	default:
+		return nil, fmt.Errorf("unknown replay mode: %s", config.Mode)
+	}
+	return p, nil
+}
+
+func (p *replayProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	if p.mode == ReplayModeRecord {
+		resp, err := p.upstream.Chat(ctx, req)
+		if err == nil {
+			p.save(&replayFixture{Kind: CallChat, Request: req, Response: resp})
+		}
+		return resp, err
+	}
+
+	fixture, err := p.load(CallChat, req)
+	if err != nil {
+		return nil, err
+	}
+	if fixture.Response == nil {
+		return nil, fmt.Errorf("replay fixture of %s has no response", CallChat)
+	}
+	reportModel(ctx, fixture.Response.Model)
+	return fixture.Response, nil
+}
+
+func (p *replayProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan *ChatStreamResponse, error) {
+	if p.mode == ReplayModeRecord {
+		return p.recordStream(ctx, req)
+	}
+
+	fixture, err := p.load(CallChatStream, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(fixture.Stream) == 0 {
+		return nil, fmt.Errorf("replay fixture of %s has no chunks", CallChatStream)
+	}
+	reportModel(ctx, fixture.Stream[0].Model)
+
+	ch := make(chan *ChatStreamResponse)
+	go func() {
+		defer close(ch)
+		for _, chunk := range fixture.Stream {
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (p *replayProvider) Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	if p.mode == ReplayModeRecord {
+		embedder, ok := p.upstream.(Embedder)
+		if !ok {
+			return nil, fmt.Errorf("%w: upstream %s provider does not support embeddings", errUnsupported, p.config.Upstream)
+		}
+		resp, err := embedder.Embed(ctx, req)
+		if err == nil {
+			p.save(&replayFixture{Kind: CallEmbed, Request: req, Embedding: resp})
+		}
+		return resp, err
+	}
+
+	fixture, err := p.load(CallEmbed, req)
+	if err != nil {
+		return nil, err
+	}
+	if fixture.Embedding == nil {
+		return nil, fmt.Errorf("replay fixture of %s has no embedding", CallEmbed)
+	}
+	reportModel(ctx, fixture.Embedding.Model)
+	return fixture.Embedding, nil
+}
+
+// recordStream forwards the upstream stream and writes its chunks once it completes without error
+func (p *replayProvider) recordStream(ctx context.Context, req *ChatRequest) (<-chan *ChatStreamResponse, error) {
+	stream, err := p.upstream.ChatStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *ChatStreamResponse)
+	go func() {
+		defer close(ch)
+
+		var chunks []*ChatStreamResponse
+		failed := false
+		for chunk := range stream {
+			if chunk.Err != nil {
+				failed = true
+			} else {
+				chunks = append(chunks, chunk)
+			}
+
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				// Drain so the upstream goroutine can exit, the stream is incomplete and not recorded
+				for range stream {
+				}
+				return
+			}
+		}
+
+		if !failed && len(chunks) > 0 {
+			p.save(&replayFixture{Kind: CallChatStream, Request: req, Stream: chunks})
+		}
+	}()
+	return ch, nil
+}
+
+// load reads the fixture of a request, a missing fixture lets another instance serve the request
+func (p *replayProvider) load(kind string, req any) (*replayFixture, error) {
+	path, err := p.fixturePath(kind, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixture replayFixture
+	if err := file.LoadJSONFile(path, &fixture); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: no replay fixture %s, record it with MODE: record", errUnsupported, path)
+		}
+		return nil, fmt.Errorf("failed to load replay fixture %s: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// save writes a fixture, failures are logged since the recorded call itself succeeded
+func (p *replayProvider) save(fixture *replayFixture) {
+	path, err := p.fixturePath(fixture.Kind, fixture.Request)
+	if err == nil {
+		err = file.SaveJSONFile(path, fixture)
+	}
+	if err != nil {
+		log.Printf("Failed to record replay fixture of %s for instance %s: %v", fixture.Kind, p.config.Name, err)
+	}
+}
+
+// fixturePath names a fixture after the kind and a hash of the canonical JSON request,
+// so the same request always maps to the same file
+func (p *replayProvider) fixturePath(kind string, req any) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+	sum := sha256.Sum256(append([]byte(kind+"\n"), data...))
+	return filepath.Join(p.config.FixtureDir, fmt.Sprintf("%s-%s.json", kind, hex.EncodeToString(sum[:8]))), nil
+}
@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func replayConfigYAML(mode, baseURL, fixtureDir string) string {
+	return fmt.Sprintf(`AI:
+  PROVIDERS:
+    replay:
+      ENABLED: true
+      INSTANCES:
+        - NAME: "fixtures"
+          ENABLED: true
+          MODE: %q
+          UPSTREAM: "openai"
+          FIXTURE_DIR: %q
+          BASE_URL: %q
+          KEYS: ["test-key"]
+          MODELS: ["mock-model"]
+          TIMEOUT: 5
+          RATE_LIMIT: "100/s"`, mode, fixtureDir, baseURL)
+}
+
+func TestReplayRecordAndReplay(t *testing.T) {
+	server := newMockOpenAIServer(t, func(w http.ResponseWriter, body map[string]any) {
+		if body["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, `data: {"id":"1","object":"chat.completion.chunk","model":"mock-model","choices":[{"index":0,"delta":{"content":"Hello "}}]}`+"\n\n")
+			fmt.Fprint(w, `data: {"id":"1","object":"chat.completion.chunk","model":"mock-model","choices":[{"index":0,"delta":{"content":"stream"},"finish_reason":"stop"}]}`+"\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"mock-model","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"Hello chat"}}],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`)
+	})
+	fixtureDir := t.TempDir()
+	req := &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Hi"}}}
+
+	initTestConfig(t, replayConfigYAML(ReplayModeRecord, server.URL, fixtureDir))
+	pool := New()
+	if _, err := pool.Chat(context.Background(), req); err != nil {
+		t.Fatalf("Chat() in record mode failed: %v", err)
+	}
+	stream, err := pool.ChatStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ChatStream() in record mode failed: %v", err)
+	}
+	for range stream {
+	}
+
+	entries, _ := os.ReadDir(fixtureDir)
+	if len(entries) != 2 {
+		t.Fatalf("recorded %d fixtures, want one chat and one stream fixture", len(entries))
+	}
+
+	// Replay with the upstream gone, as in CI without network
+	server.Close()
+	initTestConfig(t, replayConfigYAML(ReplayModeReplay, server.URL, fixtureDir))
+	pool = New()
+
+	resp, err := pool.Chat(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Chat() in replay mode failed: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "Hello chat" || resp.Usage.TotalTokens != 5 {
+		t.Errorf("replayed response = %+v", resp)
+	}
+
+	stream, err = pool.ChatStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ChatStream() in replay mode failed: %v", err)
+	}
+	content := ""
+	for chunk := range stream {
+		if chunk.Err != nil {
+			t.Fatalf("replayed stream failed: %v", chunk.Err)
+		}
+		for _, choice := range chunk.Choices {
+			content += choice.Delta.Content
+		}
+	}
+	if content != "Hello stream" {
+		t.Errorf("replayed stream content = %q, want %q", content, "Hello stream")
+	}
+
+	if _, err := pool.Chat(context.Background(), &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "Unrecorded"}}}); err == nil {
+		t.Error("Chat() without a fixture succeeded")
+	}
+}
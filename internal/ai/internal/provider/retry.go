@@ -0,0 +1,196 @@
+// Package provider implements AI provider interfaces
+// Author: Done-0
+// Created: 2025-08-31
+package provider
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Done-0/gin-scaffold/configs"
+)
+
+// Error classes, they decide whether and how a failed attempt is retried
+const (
+	errorRateLimited = "rate_limited" // 429, retried after Retry-After or backoff, with the next key
+	errorAuth        = "auth"         // 401 and 403, retried at once with the next key only
+	errorBadRequest  = "bad_request"  // Other 4xx and requests no instance can serve, never retried
+	errorServer      = "server"       // 5xx and 408, retried after backoff
+	errorNetwork     = "network"      // No response, e.g. connection reset or client timeout, retried after backoff
+)
+
+// Retry defaults, used when not configured
+const (
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	defaultRetryMaxDelay  = 30 * time.Second
+	defaultRetryJitter    = 0.2
+)
+
+// classifyError returns the error class of a failed attempt
+func classifyError(err error) string {
+	if errors.Is(err, errInvalidRequest) || errors.Is(err, errUnsupported) {
+		return errorBadRequest
+	}
+
+	status := httpStatus(err)
+	switch {
+	case status == 0:
+		return errorNetwork
+	case status == http.StatusTooManyRequests:
+		return errorRateLimited
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return errorAuth
+	case status == http.StatusRequestTimeout || status >= http.StatusInternalServerError:
+		return errorServer
+	default:
+		return errorBadRequest
+	}
+}
+
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	jitter     float64
+}
+
+// newRetryPolicy reads the retry policy of an instance, filling in defaults
+func newRetryPolicy(config *configs.ProviderInstanceConfig) retryPolicy {
+	policy := retryPolicy{
+		maxRetries: config.MaxRetries,
+		baseDelay:  time.Duration(config.Retry.BaseDelay) * time.Millisecond,
+		maxDelay:   time.Duration(config.Retry.MaxDelay) * time.Millisecond,
+		jitter:     defaultRetryJitter,
+	}
+	if policy.baseDelay <= 0 {
+		policy.baseDelay = defaultRetryBaseDelay
+	}
+	if policy.maxDelay <= 0 {
+		policy.maxDelay = defaultRetryMaxDelay
+	}
+	if config.Retry.Jitter != nil {
+		policy.jitter = min(max(*config.Retry.Jitter, 0), 1)
+	}
+	return policy
+}
+
+// backoff returns the delay before retry n (0-based): the base delay doubled per retry, capped, minus a random jitter fraction
+func (r retryPolicy) backoff(n int) time.Duration {
+	delay := r.maxDelay
+	if n < 32 {
+		delay = min(r.baseDelay<<n, r.maxDelay)
+	}
+	return delay - time.Duration(rand.Float64()*r.jitter*float64(delay))
+}
+
+// retry runs attempt under the retry policy of an instance until it succeeds, fails with an error that is not
+// retryable, the retries are used up or ctx is done. rotate switches to the next API key and reports whether there
+// was one, it may be nil.
+//
+// A Retry-After longer than MAX_DELAY ends the retries, so the pool can fail over instead of waiting.
+func retry(ctx context.Context, config *configs.ProviderInstanceConfig, rotate func() bool, attempt func(ctx context.Context) error) error {
+	policy := newRetryPolicy(config)
+	for n := 0; ; n++ {
+		hint := &retryHint{}
+		err := attempt(context.WithValue(ctx, retryHintKey{}, hint))
+		recordKey(ctx, config, err)
+		if err == nil || n >= policy.maxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		delay := policy.backoff(n)
+		switch classifyError(err) {
+		case errorBadRequest:
+			return err
+		case errorAuth:
+			// The key is rejected, waiting does not help
+			if rotate == nil || !rotate() {
+				return err
+			}
+			continue
+		case errorRateLimited:
+			// Retry-After applies to the throttled key, the next key only waits for the backoff
+			if rotate == nil || !rotate() {
+				if retryAfter := hint.retryAfter.Load(); retryAfter > 0 {
+					delay = time.Duration(retryAfter)
+				}
+			}
+		case errorServer:
+			if retryAfter := hint.retryAfter.Load(); retryAfter > 0 {
+				delay = time.Duration(retryAfter)
+			}
+		}
+		if delay > policy.maxDelay {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// keyRotation returns the rotate callback of retry that moves apiKey to the next available key of the instance,
+// nil when the instance has fewer than two keys
+func keyRotation(ctx context.Context, config *configs.ProviderInstanceConfig, keyCounter *uint64, apiKey *string) func() bool {
+	if len(config.Keys) < 2 {
+		return nil
+	}
+	return func() bool {
+		key, err := nextKey(ctx, config, keyCounter)
+		if err != nil {
+			return false
+		}
+		*apiKey = key
+		return true
+	}
+}
+
+type retryHintKey struct{}
+
+// retryHint carries the Retry-After of an attempt from the HTTP transport back to retry
+type retryHint struct {
+	retryAfter atomic.Int64 // Nanoseconds, 0 when the response had none
+}
+
+// retryAfterTransport records the Retry-After of throttled and unavailable responses into the retry hint of the
+// request context, so SDK clients that drop response headers still honour it
+type retryAfterTransport struct {
+	base http.RoundTripper
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return resp, err
+	}
+	if hint, ok := req.Context().Value(retryHintKey{}).(*retryHint); ok {
+		hint.retryAfter.Store(int64(parseRetryAfter(resp.Header, time.Now())))
+	}
+	return resp, err
+}
+
+// parseRetryAfter reads retry-after-ms, then Retry-After as seconds or an HTTP date, 0 when absent or invalid
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+
+	value := header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,214 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/Done-0/gin-scaffold/configs"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&APIError{StatusCode: http.StatusTooManyRequests}, errorRateLimited},
+		{&openai.APIError{HTTPStatusCode: http.StatusUnauthorized}, errorAuth},
+		{&APIError{StatusCode: http.StatusForbidden}, errorAuth},
+		{&APIError{StatusCode: http.StatusBadRequest}, errorBadRequest},
+		{&APIError{StatusCode: http.StatusNotFound}, errorBadRequest},
+		{fmt.Errorf("%w: bad part", errInvalidRequest), errorBadRequest},
+		{&APIError{StatusCode: http.StatusRequestTimeout}, errorServer},
+		{&openai.RequestError{HTTPStatusCode: http.StatusBadGateway}, errorServer},
+		{errors.New("connection reset by peer"), errorNetwork},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.want {
+			t.Errorf("classifyError(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 9, 25, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header http.Header
+		want   time.Duration
+	}{
+		{http.Header{"Retry-After": {"3"}}, 3 * time.Second},
+		{http.Header{"Retry-After": {now.Add(90 * time.Second).Format(http.TimeFormat)}}, 90 * time.Second},
+		{http.Header{"Retry-After-Ms": {"250"}, "Retry-After": {"1"}}, 250 * time.Millisecond},
+		{http.Header{"Retry-After": {now.Add(-time.Minute).Format(http.TimeFormat)}}, 0},
+		{http.Header{"Retry-After": {"soon"}}, 0},
+		{http.Header{}, 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%v) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	jitter := 0.5
+	policy := newRetryPolicy(&configs.ProviderInstanceConfig{})
+	if policy.baseDelay != defaultRetryBaseDelay || policy.maxDelay != defaultRetryMaxDelay || policy.jitter != defaultRetryJitter {
+		t.Errorf("default policy = %+v", policy)
+	}
+
+	policy = newRetryPolicy(&configs.ProviderInstanceConfig{Retry: configs.RetryConfig{BaseDelay: 100, MaxDelay: 1000, Jitter: &jitter}})
+	for n, full := range []time.Duration{100, 200, 400, 800, 1000, 1000, 1000} {
+		full *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			if delay := policy.backoff(n); delay > full || delay < full/2 {
+				t.Fatalf("backoff(%d) = %v, want within [%v, %v]", n, delay, full/2, full)
+			}
+		}
+	}
+}
+
+// newRetryServer starts an OpenAI-compatible server answering each request with the next status, then 200,
+// and records the API key of every request
+func newRetryServer(t *testing.T, header http.Header, statuses ...int) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Authorization"))
+		n := len(keys)
+		mu.Unlock()
+
+		if n <= len(statuses) {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			http.Error(w, `{"error":{"message":"failed attempt"}}`, statuses[n-1])
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, mockChatCompletion)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), keys...)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	req := &ChatRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}}
+	chat := func(t *testing.T, config *configs.ProviderInstanceConfig) error {
+		t.Helper()
+		var keyCounter, modelCounter uint64
+		p, err := NewOpenAI(config, &keyCounter, &modelCounter)
+		if err != nil {
+			t.Fatalf("NewOpenAI() failed: %v", err)
+		}
+		_, err = p.Chat(context.Background(), req)
+		return err
+	}
+	newConfig := func(baseURL string, keys ...string) *configs.ProviderInstanceConfig {
+		config := newMockOpenAIConfig(baseURL)
+		config.Keys = keys
+		config.MaxRetries = 3
+		config.Retry = configs.RetryConfig{BaseDelay: 1, MaxDelay: 1000}
+		return config
+	}
+
+	t.Run("server errors back off on the same key", func(t *testing.T) {
+		server, keys := newRetryServer(t, nil, http.StatusBadGateway, http.StatusServiceUnavailable)
+		if err := chat(t, newConfig(server.URL, "key-a", "key-b")); err != nil {
+			t.Fatalf("Chat() failed: %v", err)
+		}
+		if got := keys(); len(got) != 3 || got[0] != got[1] || got[1] != got[2] {
+			t.Errorf("keys = %v, want 3 attempts with one key", got)
+		}
+	})
+
+	t.Run("bad requests are not retried", func(t *testing.T) {
+		server, keys := newRetryServer(t, nil, http.StatusBadRequest)
+		if err := chat(t, newConfig(server.URL, "key-a")); classifyError(err) != errorBadRequest {
+			t.Fatalf("Chat() error = %v, want bad request", err)
+		}
+		if got := keys(); len(got) != 1 {
+			t.Errorf("attempts = %d, want 1", len(got))
+		}
+	})
+
+	t.Run("auth and rate limit errors rotate keys", func(t *testing.T) {
+		server, keys := newRetryServer(t, http.Header{"Retry-After": {"3600"}}, http.StatusUnauthorized, http.StatusTooManyRequests)
+		if err := chat(t, newConfig(server.URL, "key-a", "key-b", "key-c")); err != nil {
+			t.Fatalf("Chat() failed: %v", err)
+		}
+		want := []string{"Bearer key-a", "Bearer key-b", "Bearer key-c"}
+		if got := keys(); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("keys = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("auth errors with a single key are not retried", func(t *testing.T) {
+		server, keys := newRetryServer(t, nil, http.StatusUnauthorized)
+		if err := chat(t, newConfig(server.URL, "key-a")); classifyError(err) != errorAuth {
+			t.Fatalf("Chat() error = %v, want auth error", err)
+		}
+		if got := keys(); len(got) != 1 {
+			t.Errorf("attempts = %d, want 1", len(got))
+		}
+	})
+
+	t.Run("Retry-After is honoured", func(t *testing.T) {
+		server, keys := newRetryServer(t, http.Header{"Retry-After-Ms": {"100"}}, http.StatusTooManyRequests)
+		start := time.Now()
+		if err := chat(t, newConfig(server.URL, "key-a")); err != nil {
+			t.Fatalf("Chat() failed: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+			t.Errorf("elapsed = %v, want at least the 100ms Retry-After", elapsed)
+		}
+		if got := keys(); len(got) != 2 {
+			t.Errorf("attempts = %d, want 2", len(got))
+		}
+	})
+
+	t.Run("Retry-After beyond MAX_DELAY fails over", func(t *testing.T) {
+		server, keys := newRetryServer(t, http.Header{"Retry-After": {"60"}}, http.StatusTooManyRequests)
+		if err := chat(t, newConfig(server.URL, "key-a")); classifyError(err) != errorRateLimited {
+			t.Fatalf("Chat() error = %v, want rate limit error", err)
+		}
+		if got := keys(); len(got) != 1 {
+			t.Errorf("attempts = %d, want 1", len(got))
+		}
+	})
+}
+
+func TestRetryCancel(t *testing.T) {
+	config := newMockOpenAIConfig("")
+	config.MaxRetries = 3
+	config.Retry = configs.RetryConfig{BaseDelay: 60000, MaxDelay: 60000}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	start := time.Now()
+	err := retry(ctx, config, nil, func(ctx context.Context) error {
+		attempts++
+		return &APIError{StatusCode: http.StatusBadGateway}
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("retry() error = %v, want context.DeadlineExceeded", err)
+	}
+	if attempts != 1 || time.Since(start) > 5*time.Second {
+		t.Errorf("attempts = %d after %v, want the backoff cut short by the context", attempts, time.Since(start))
+	}
+}
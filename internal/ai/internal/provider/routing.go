@@ -0,0 +1,130 @@
+// Package provider implements AI provider interfaces
+// Author: Done-0
+// Created: 2025-08-31
+package provider
+
+import (
+	"slices"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Routing strategies, the order in which instances are tried
+const (
+	RoutingRoundRobin   = "round_robin"   // Rotate the starting instance on every request
+	RoutingWeighted     = "weighted"      // Smooth weighted round robin by instance WEIGHT
+	RoutingLeastLatency = "least_latency" // Lowest moving average latency first, unmeasured instances are probed first and failures count as timeouts
+	RoutingPriority     = "priority"      // Lowest PRIORITY tier first, round robin within a tier, higher tiers as fallback
+)
+
+// latencyAlpha weight of the newest sample in the latency moving average
+const latencyAlpha = 0.3
+
+// route orders the instances for a call by strategy, the first is tried first and the rest follow as failover candidates
+func (p *provider) route(strategy, kind string, instances []providerInstance) []providerInstance {
+	switch strategy {
+	case RoutingWeighted:
+		return p.routeWeighted(instances)
+	case RoutingLeastLatency:
+		return p.routeLeastLatency(kind, instances)
+	case RoutingPriority:
+		return p.routePriority(instances)
+	default:
+		return p.routeRoundRobin(instances)
+	}
+}
+
+// routeRoundRobin starts from the next instance, the remaining instances follow in order
+func (p *provider) routeRoundRobin(instances []providerInstance) []providerInstance {
+	start := int(atomic.AddUint64(&p.instanceCounter, 1) % uint64(len(instances)))
+	return append(instances[start:len(instances):len(instances)], instances[:start]...)
+}
+
+// routePriority groups instances into tiers by ascending priority and rotates the starting instance within each tier
+func (p *provider) routePriority(instances []providerInstance) []providerInstance {
+	sorted := slices.Clone(instances)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].instance.Priority < sorted[j].instance.Priority })
+
+	counter := atomic.AddUint64(&p.instanceCounter, 1)
+	ordered := make([]providerInstance, 0, len(sorted))
+	for start := 0; start < len(sorted); {
+		end := start + 1
+		for end < len(sorted) && sorted[end].instance.Priority == sorted[start].instance.Priority {
+			end++
+		}
+		tier := sorted[start:end]
+		offset := int(counter % uint64(len(tier)))
+		ordered = append(append(ordered, tier[offset:]...), tier[:offset]...)
+		start = end
+	}
+	return ordered
+}
+
+// routeWeighted picks the first instance with smooth weighted round robin, so an instance of weight 3 is picked three
+// times as often as one of weight 1 without bursts; the rest follow by descending weight
+func (p *provider) routeWeighted(instances []providerInstance) []providerInstance {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	total, best := 0, 0
+	for i, candidate := range instances {
+		weight := instanceWeight(candidate)
+		total += weight
+		p.currentWeights[candidate.key()] += weight
+		if p.currentWeights[candidate.key()] > p.currentWeights[instances[best].key()] {
+			best = i
+		}
+	}
+	p.currentWeights[instances[best].key()] -= total
+
+	ordered := append([]providerInstance{instances[best]}, slices.Delete(slices.Clone(instances), best, best+1)...)
+	sort.SliceStable(ordered[1:], func(i, j int) bool {
+		return instanceWeight(ordered[1+i]) > instanceWeight(ordered[1+j])
+	})
+	return ordered
+}
+
+// routeLeastLatency orders instances by the moving average latency of the call kind, starting from a rotating
+// instance so ties and unmeasured instances share the load
+func (p *provider) routeLeastLatency(kind string, instances []providerInstance) []providerInstance {
+	ordered := slices.Clone(p.routeRoundRobin(instances))
+
+	p.mu.Lock()
+	latencies := make(map[string]time.Duration, len(ordered))
+	for _, candidate := range ordered {
+		latencies[candidate.key()] = p.latencies[kind+":"+candidate.key()]
+	}
+	p.mu.Unlock()
+
+	sort.SliceStable(ordered, func(i, j int) bool { return latencies[ordered[i].key()] < latencies[ordered[j].key()] })
+	return ordered
+}
+
+// observeLatency folds the latency of a call into the moving average of the instance and call kind
+func (p *provider) observeLatency(kind string, selected providerInstance, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := kind + ":" + selected.key()
+	if prev, exists := p.latencies[key]; exists {
+		latency = time.Duration(latencyAlpha*float64(latency) + (1-latencyAlpha)*float64(prev))
+	}
+	p.latencies[key] = max(latency, 1)
+}
+
+// observeFailure counts a failed call as taking at least the instance TIMEOUT, so a failing instance falls behind
+// healthy ones instead of staying unmeasured and first in line
+func (p *provider) observeFailure(kind string, selected providerInstance, elapsed time.Duration) {
+	p.observeLatency(kind, selected, max(elapsed, time.Duration(selected.instance.Timeout)*time.Second))
+}
+
+// instanceWeight returns the configured weight, unset counts as 1
+func instanceWeight(candidate providerInstance) int {
+	return max(candidate.instance.Weight, 1)
+}
+
+// servesModel reports whether an instance lists the model, any instance serves requests without one
+func servesModel(models []string, model string) bool {
+	return model == "" || slices.Contains(models, model)
+}
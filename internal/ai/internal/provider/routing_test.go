@@ -0,0 +1,198 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Done-0/gin-scaffold/configs"
+)
+
+// routingInstances builds openai instances named after their index
+func routingInstances(configure ...func(*configs.ProviderInstanceConfig)) []providerInstance {
+	instances := make([]providerInstance, len(configure))
+	for i, fn := range configure {
+		instances[i] = providerInstance{name: "openai", instance: configs.ProviderInstanceConfig{Name: fmt.Sprint(i)}}
+		fn(&instances[i].instance)
+	}
+	return instances
+}
+
+// firstPicks routes n times and returns the names of the first candidates
+func firstPicks(p *provider, strategy string, instances []providerInstance, n int) string {
+	var picks []string
+	for i := 0; i < n; i++ {
+		ordered := p.route(strategy, CallChat, instances)
+		if len(ordered) != len(instances) {
+			return fmt.Sprintf("route returned %d of %d instances", len(ordered), len(instances))
+		}
+		picks = append(picks, ordered[0].instance.Name)
+	}
+	return strings.Join(picks, "")
+}
+
+func TestRouteWeighted(t *testing.T) {
+	p := New().(*provider)
+	instances := routingInstances(
+		func(c *configs.ProviderInstanceConfig) { c.Weight = 4 },
+		func(c *configs.ProviderInstanceConfig) { c.Weight = 2 },
+		func(c *configs.ProviderInstanceConfig) {}, // Unset counts as 1
+	)
+
+	// Smooth weighted round robin interleaves instead of sending bursts to the heaviest instance
+	if got, want := firstPicks(p, RoutingWeighted, instances, 7), "0102010"; got != want {
+		t.Errorf("weighted picks = %s, want %s", got, want)
+	}
+
+	ordered := p.route(RoutingWeighted, CallChat, instances)
+	if ordered[1].instance.Weight < ordered[2].instance.Weight {
+		t.Errorf("failover order = %s, %s, want descending weight", ordered[1].instance.Name, ordered[2].instance.Name)
+	}
+}
+
+func TestRoutePriority(t *testing.T) {
+	p := New().(*provider)
+	instances := routingInstances(
+		func(c *configs.ProviderInstanceConfig) { c.Priority = 1 },
+		func(c *configs.ProviderInstanceConfig) {},
+		func(c *configs.ProviderInstanceConfig) { c.Priority = 2 },
+		func(c *configs.ProviderInstanceConfig) {},
+	)
+
+	for i := 0; i < 4; i++ {
+		ordered := p.route(RoutingPriority, CallChat, instances)
+		var names []string
+		for _, candidate := range ordered {
+			names = append(names, candidate.instance.Name)
+		}
+		if got := strings.Join(names, ""); got != "1302" && got != "3102" {
+			t.Errorf("priority order = %s, want tier 0 (1, 3) then 0 then 2", got)
+		}
+	}
+	if got := firstPicks(p, RoutingPriority, instances, 4); got != "1313" && got != "3131" {
+		t.Errorf("priority picks = %s, want round robin within tier 0", got)
+	}
+}
+
+func TestRouteLeastLatency(t *testing.T) {
+	p := New().(*provider)
+	instances := routingInstances(
+		func(c *configs.ProviderInstanceConfig) {},
+		func(c *configs.ProviderInstanceConfig) {},
+		func(c *configs.ProviderInstanceConfig) {},
+	)
+	p.observeLatency(CallChat, instances[0], 300*time.Millisecond)
+	p.observeLatency(CallChat, instances[1], 100*time.Millisecond)
+
+	// Unmeasured instances are probed first
+	if ordered := p.route(RoutingLeastLatency, CallChat, instances); ordered[0].instance.Name != "2" {
+		t.Errorf("first = %s, want the unmeasured instance 2", ordered[0].instance.Name)
+	}
+
+	p.observeLatency(CallChat, instances[2], 200*time.Millisecond)
+	if got := firstPicks(p, RoutingLeastLatency, instances, 3); got != "111" {
+		t.Errorf("least latency picks = %s, want 111", got)
+	}
+
+	// The moving average follows a slowing instance: 100ms -> 0.3*1000 + 0.7*100 = 370ms
+	p.observeLatency(CallChat, instances[1], time.Second)
+	ordered := p.route(RoutingLeastLatency, CallChat, instances)
+	if ordered[0].instance.Name != "2" || ordered[1].instance.Name != "0" || ordered[2].instance.Name != "1" {
+		t.Errorf("order = %s%s%s, want 201", ordered[0].instance.Name, ordered[1].instance.Name, ordered[2].instance.Name)
+	}
+
+	// Latency is tracked per call kind
+	if ordered := p.route(RoutingLeastLatency, CallEmbed, instances); len(ordered) != 3 {
+		t.Errorf("embed order = %d instances, want 3", len(ordered))
+	}
+}
+
+func TestRouteLeastLatencyFailures(t *testing.T) {
+	p := New().(*provider)
+	instances := routingInstances(
+		func(c *configs.ProviderInstanceConfig) {},
+		func(c *configs.ProviderInstanceConfig) { c.Timeout = 30 },
+	)
+	p.observeLatency(CallChat, instances[0], 2*time.Second)
+
+	// A fast failure of the unmeasured instance counts as its 30s timeout, putting it behind the slow healthy one
+	failed := p.recordFailure(context.Background(), CallChat, instances[1], p.breaker(instances[1].key()), breakerConfig{threshold: 5}, time.Now(), &APIError{Provider: "openai", StatusCode: http.StatusInternalServerError})
+	if !failed {
+		t.Fatalf("recordFailure() = false, want failover on a server error")
+	}
+	if got := firstPicks(p, RoutingLeastLatency, instances, 2); got != "00" {
+		t.Errorf("least latency picks = %s, want the healthy instance 0", got)
+	}
+
+	// Invalid requests say nothing about the instance and leave its latency alone
+	p.recordFailure(context.Background(), CallEmbed, instances[1], p.breaker(instances[1].key()), breakerConfig{threshold: 5}, time.Now(), &APIError{Provider: "openai", StatusCode: http.StatusBadRequest})
+	if latency, measured := p.latencies[CallEmbed+":"+instances[1].key()]; measured {
+		t.Errorf("embed latency = %v after an invalid request, want unmeasured", latency)
+	}
+}
+
+func TestModelAwareRouting(t *testing.T) {
+	var hitsA, hitsB int32
+	serverA := newMockOpenAIServer(t, func(w http.ResponseWriter, body map[string]any) {
+		atomic.AddInt32(&hitsA, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, mockChatCompletion)
+	})
+	serverB := newMockOpenAIServer(t, func(w http.ResponseWriter, body map[string]any) {
+		atomic.AddInt32(&hitsB, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, mockChatCompletion)
+	})
+
+	initTestConfig(t, fmt.Sprintf(`AI:
+  ROUTING:
+    STRATEGY: "weighted"
+  PROVIDERS:
+    openai:
+      ENABLED: true
+      INSTANCES:
+        - NAME: "a"
+          ENABLED: true
+          BASE_URL: %q
+          KEYS: ["test-key"]
+          MODELS: ["model-a", "model-shared"]
+          WEIGHT: 3
+          TIMEOUT: 5
+          RATE_LIMIT: "100/s"
+        - NAME: "b"
+          ENABLED: true
+          BASE_URL: %q
+          KEYS: ["test-key"]
+          MODELS: ["model-b", "model-shared"]
+          TIMEOUT: 5
+          RATE_LIMIT: "100/s"`, serverA.URL, serverB.URL))
+
+	pool := New()
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := pool.Chat(ctx, &ChatRequest{Model: "model-b", Messages: []Message{{Role: RoleUser, Content: "hi"}}}); err != nil {
+			t.Fatalf("Chat() failed: %v", err)
+		}
+	}
+	if hitsA != 0 || hitsB != 3 {
+		t.Errorf("hits = a:%d b:%d, want every model-b request on b", hitsA, hitsB)
+	}
+
+	for i := 0; i < 4; i++ {
+		if _, err := pool.Chat(ctx, &ChatRequest{Model: "model-shared", Messages: []Message{{Role: RoleUser, Content: "hi"}}}); err != nil {
+			t.Fatalf("Chat() failed: %v", err)
+		}
+	}
+	if hitsA != 3 || hitsB != 4 {
+		t.Errorf("hits = a:%d b:%d, want model-shared split 3:1 by weight", hitsA, hitsB)
+	}
+
+	_, err := pool.Chat(ctx, &ChatRequest{Model: "model-c", Messages: []Message{{Role: RoleUser, Content: "hi"}}})
+	if err == nil || !strings.Contains(err.Error(), "no enabled provider instance serves model model-c") {
+		t.Errorf("Chat() error = %v, want no instance for model-c", err)
+	}
+}
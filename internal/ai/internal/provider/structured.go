@@ -0,0 +1,86 @@
+// Package provider implements AI provider interfaces
+// Author: Done-0
+// Created: 2025-08-31
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Done-0/gin-scaffold/internal/utils/validator"
+)
+
+// defaultSchemaName is sent when a JSON schema response format has no name
+const defaultSchemaName = "response"
+
+// validate checks that the response format can be sent to a provider
+func (f *ResponseFormat) validate() error {
+	if f == nil {
+		return nil
+	}
+
+	switch f.Type {
+	case "", ResponseFormatText, ResponseFormatJSONObject:
+		return nil
+	case ResponseFormatJSONSchema:
+		if len(f.Schema) == 0 {
+			return fmt.Errorf("%w: response format %s requires a schema", errInvalidRequest, f.Type)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown response format %q", errInvalidRequest, f.Type)
+	}
+}
+
+// isJSON reports whether the response format asks for JSON output
+func (f *ResponseFormat) isJSON() bool {
+	return f != nil && (f.Type == ResponseFormatJSONObject || f.Type == ResponseFormatJSONSchema)
+}
+
+// schemaName returns the schema name, falling back to the default
+func (f *ResponseFormat) schemaName() string {
+	if f.Name == "" {
+		return defaultSchemaName
+	}
+	return f.Name
+}
+
+// DecodeJSON decodes the first choice of a response into T and validates it with its validate struct tags
+func DecodeJSON[T any](resp *ChatResponse) (*T, error) {
+	if resp == nil || len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("response has no choices")
+	}
+
+	var result T
+	content := trimCodeFence(resp.Choices[0].Message.Content)
+	if err := json.Unmarshal([]byte(content), &result); err != nil {
+		return nil, fmt.Errorf("failed to decode response as %T: %w", result, err)
+	}
+
+	if reflect.Indirect(reflect.ValueOf(result)).Kind() == reflect.Struct {
+		if err := validator.NewValidator.Struct(result); err != nil {
+			return nil, fmt.Errorf("response failed validation: %w", err)
+		}
+	}
+	return &result, nil
+}
+
+// trimCodeFence strips the markdown code fence some models wrap JSON output in
+func trimCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	rest, found := strings.CutPrefix(content, "```")
+	if !found {
+		return content
+	}
+	rest, found = strings.CutSuffix(rest, "```")
+	if !found {
+		return content
+	}
+	// Drop the language tag on the opening line, e.g. ```json
+	if _, body, ok := strings.Cut(rest, "\n"); ok {
+		rest = body
+	}
+	return strings.TrimSpace(rest)
+}
@@ -0,0 +1,150 @@
+// Package provider implements AI provider interfaces
+// Author: Done-0
+// Created: 2025-08-31
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/tokenizer"
+	"github.com/Done-0/gin-scaffold/internal/types/errno"
+	"github.com/Done-0/gin-scaffold/internal/utils/errorx"
+)
+
+// Context guard policies
+const (
+	ContextPolicyReject   = "reject"   // Fail the request with errno.ErrAIContextTooLong
+	ContextPolicyTruncate = "truncate" // Drop the oldest non-system messages until the prompt fits
+)
+
+// Framing tokens of the chat format, as counted by OpenAI
+const (
+	tokensPerMessage = 3  // Role and delimiters of every message
+	tokensPerName    = 1  // Name field of a message
+	tokensPerReply   = 3  // Priming of the assistant reply
+	tokensPerImage   = 85 // Non-text part, the cost of a low detail image
+)
+
+// CountPromptTokens counts the prompt tokens of a request for model, exactly for OpenAI models whose encoding
+// is available and estimated otherwise
+func CountPromptTokens(model string, req *ChatRequest) int {
+	tokens := tokensPerReply
+	for _, msg := range req.Messages {
+		tokens += CountMessageTokens(model, msg)
+	}
+	if len(req.Tools) > 0 {
+		if data, err := json.Marshal(req.Tools); err == nil {
+			tokens += tokenizer.Count(model, string(data))
+		}
+	}
+	return tokens
+}
+
+// CountMessageTokens counts the tokens of one message for model, including its framing
+func CountMessageTokens(model string, msg Message) int {
+	tokens := tokensPerMessage + tokenizer.Count(model, msg.Content) + tokenizer.Count(model, msg.ReasoningContent)
+	if msg.Name != "" {
+		tokens += tokensPerName + tokenizer.Count(model, msg.Name)
+	}
+	for _, part := range msg.Parts {
+		if part.Type == PartTypeText {
+			tokens += tokenizer.Count(model, part.Text)
+		} else {
+			tokens += tokensPerImage
+		}
+	}
+	for _, call := range msg.ToolCalls {
+		tokens += tokenizer.Count(model, call.Function.Name) + tokenizer.Count(model, call.Function.Arguments)
+	}
+	return tokens
+}
+
+// CountEmbeddingTokens counts the input tokens of an embedding request for model
+func CountEmbeddingTokens(model string, req *EmbeddingRequest) int {
+	tokens := 0
+	for _, input := range req.Input {
+		tokens += tokenizer.Count(model, input)
+	}
+	return tokens
+}
+
+// fitContext checks that the prompt and the output budget fit the model context window before the request is
+// dispatched. Under the truncate policy the oldest turns are dropped first; a prompt that still does not fit is
+// rejected as unsupported, so an instance with a larger window may serve it.
+func fitContext(config *configs.ProviderInstanceConfig, model string, req *ChatRequest) (*ChatRequest, error) {
+	window, ok := contextWindow(model, config.ContextWindows)
+	if !ok {
+		return req, nil
+	}
+
+	output := req.MaxTokens
+	if output <= 0 {
+		output = config.MaxTokens
+	}
+	limit := max(window-output, 0)
+
+	tokens := CountPromptTokens(model, req)
+	if tokens <= limit {
+		return req, nil
+	}
+
+	cfg, err := configs.GetConfig()
+	if err == nil && cfg.AI.ContextGuard.Policy == ContextPolicyTruncate {
+		if truncated, ok := truncateMessages(model, req, tokens, limit); ok {
+			log.Printf("Truncated prompt of %d tokens to %d messages for the context window of %s", tokens, len(truncated.Messages), model)
+			return truncated, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %w", errUnsupported, errorx.New(errno.ErrAIContextTooLong,
+		errorx.KV("tokens", strconv.Itoa(tokens)), errorx.KV("limit", strconv.Itoa(limit)), errorx.KV("model", model)))
+}
+
+// truncateMessages drops the oldest non-system messages, and the tool results left without their call, until the
+// prompt fits the limit; the last message is always kept
+func truncateMessages(model string, req *ChatRequest, tokens, limit int) (*ChatRequest, bool) {
+	messages := append([]Message(nil), req.Messages...)
+	for tokens > limit {
+		oldest := -1
+		for i := 0; i < len(messages)-1; i++ {
+			if messages[i].Role != RoleSystem {
+				oldest = i
+				break
+			}
+		}
+		if oldest < 0 {
+			return nil, false
+		}
+
+		tokens -= CountMessageTokens(model, messages[oldest])
+		messages = append(messages[:oldest], messages[oldest+1:]...)
+		for oldest < len(messages)-1 && messages[oldest].Role == RoleTool {
+			tokens -= CountMessageTokens(model, messages[oldest])
+			messages = append(messages[:oldest], messages[oldest+1:]...)
+		}
+	}
+
+	truncated := *req
+	truncated.Messages = messages
+	return &truncated, true
+}
+
+// contextWindow returns the context window of the exact model, falling back to the longest configured model name it starts with
+func contextWindow(model string, windows []configs.ContextWindowConfig) (int, bool) {
+	best := configs.ContextWindowConfig{}
+	found := false
+	for _, window := range windows {
+		if window.Model == model {
+			return window.Tokens, window.Tokens > 0
+		}
+		if strings.HasPrefix(model, window.Model) && len(window.Model) > len(best.Model) {
+			best, found = window, true
+		}
+	}
+	return best.Tokens, found && best.Tokens > 0
+}
@@ -10,18 +10,133 @@ type Provider interface {
 	ChatStream(ctx context.Context, req *ChatRequest) (<-chan *ChatStreamResponse, error)
 }
 
+// Embedder is implemented by providers that can generate embeddings
+type Embedder interface {
+	Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error)
+}
+
+// Pool dispatches requests across all configured provider instances
+type Pool interface {
+	Provider
+	Embedder
+	BreakerStates() []BreakerState
+	KeyStates() []KeyState
+}
+
+// Message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Tool choice types
+const (
+	ToolChoiceAuto     = "auto"     // Model decides whether to call tools
+	ToolChoiceNone     = "none"     // Model must not call tools
+	ToolChoiceRequired = "required" // Model must call at least one tool
+	ToolChoiceFunction = "function" // Model must call the named function
+)
+
+// Content part types
+const (
+	PartTypeText     = "text"      // Plain text
+	PartTypeImageURL = "image_url" // Image referenced by URL
+	PartTypeImage    = "image"     // Inline base64 image
+	PartTypeFile     = "file"      // Document such as a PDF, inline base64 or referenced by URL
+)
+
+// Response format types
+const (
+	ResponseFormatText       = "text"        // Free-form text, the default
+	ResponseFormatJSONObject = "json_object" // Any valid JSON object
+	ResponseFormatJSONSchema = "json_schema" // JSON constrained by ResponseFormat.Schema
+)
+
+// ToolTypeFunction is the only tool type currently supported
+const ToolTypeFunction = "function"
+
 // Request types
 type ChatRequest struct {
-	Model       string    `json:"model"`
-	Messages    []Message `json:"messages"`
-	MaxTokens   int       `json:"max_tokens,omitempty"`
-	Temperature float64   `json:"temperature,omitempty"`
+	Model          string          `json:"model"`
+	Messages       []Message       `json:"messages"`
+	MaxTokens      int             `json:"max_tokens,omitempty"`      // Overrides the instance MAX_TOKENS, 0=instance default
+	Temperature    *float32        `json:"temperature,omitempty"`     // Overrides the instance TEMPERATURE, nil=instance default
+	TopP           *float32        `json:"top_p,omitempty"`           // Overrides the instance TOP_P, nil=instance default
+	TopK           int             `json:"top_k,omitempty"`           // Overrides the instance TOP_K, 0=instance default, ignored by openai
+	Stop           []string        `json:"stop,omitempty"`            // Stop sequences
+	Seed           *int            `json:"seed,omitempty"`            // Sampling seed for reproducible output, ignored by anthropic
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"` // Structured output, nil=free-form text
+	Tools          []Tool          `json:"tools,omitempty"`
+	ToolChoice     *ToolChoice     `json:"tool_choice,omitempty"`
+}
+
+// ResponseFormat constrains the model output to JSON
+type ResponseFormat struct {
+	Type   string         `json:"type"`             // text, json_object or json_schema
+	Name   string         `json:"name,omitempty"`   // Schema name, required by openai, defaults to "response"
+	Schema map[string]any `json:"schema,omitempty"` // JSON schema of the output, required for json_schema
+	Strict bool           `json:"strict,omitempty"` // Enforce the schema exactly (openai)
 }
 
 type Message struct {
-	Role             string `json:"role"`
-	Content          string `json:"content"`
-	ReasoningContent string `json:"reasoning_content,omitempty"`
+	Role             string           `json:"role"`
+	Content          string           `json:"content"`
+	Parts            []ContentPart    `json:"parts,omitempty"` // Multimodal content, sent after Content when both are set
+	ReasoningContent string           `json:"reasoning_content,omitempty"`
+	ReasoningBlocks  []ReasoningBlock `json:"reasoning_blocks,omitempty"` // Signed reasoning, sent back verbatim and in order on the next turn (anthropic)
+	ToolCalls        []ToolCall       `json:"tool_calls,omitempty"`       // Set on assistant messages that request tool calls
+	ToolCallID       string           `json:"tool_call_id,omitempty"`     // Set on tool messages, references ToolCall.ID
+	Name             string           `json:"name,omitempty"`             // Function name on tool messages, optional
+}
+
+// ReasoningBlock one signed reasoning block of a response
+type ReasoningBlock struct {
+	Type      string `json:"type"`                // thinking or redacted_thinking
+	Thinking  string `json:"thinking,omitempty"`  // Reasoning text, for thinking blocks
+	Signature string `json:"signature,omitempty"` // Signature of thinking blocks
+	Data      string `json:"data,omitempty"`      // Encrypted reasoning, for redacted_thinking blocks
+}
+
+// ContentPart one piece of multimodal message content
+type ContentPart struct {
+	Type     string `json:"type"`                // text, image_url, image or file
+	Text     string `json:"text,omitempty"`      // Text content, for text parts
+	URL      string `json:"url,omitempty"`       // Remote or data URL, for image_url and file parts
+	Data     string `json:"data,omitempty"`      // Base64 encoded content, for image and file parts
+	MIMEType string `json:"mime_type,omitempty"` // e.g. image/png, application/pdf
+	Filename string `json:"filename,omitempty"`  // Optional file name, for file parts
+	Detail   string `json:"detail,omitempty"`    // Image detail hint: low, high or auto (openai)
+}
+
+// Tool types
+type Tool struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+type FunctionDefinition struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"` // JSON schema of the function arguments
+}
+
+type ToolChoice struct {
+	Type     string `json:"type"`               // auto, none, required or function
+	Function string `json:"function,omitempty"` // Function name when Type is function
+}
+
+type ToolCall struct {
+	Index    int          `json:"index"` // Position of the call, used to merge stream deltas
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"` // JSON encoded arguments, may be partial in stream deltas
 }
 
 // Response types
@@ -49,6 +164,7 @@ type Usage struct {
 }
 
 // Stream response types
+// A stream that fails ends with an element whose Err is set, a stream that closes without one completed normally
 type ChatStreamResponse struct {
 	ID                string         `json:"id"`
 	Object            string         `json:"object"`
@@ -58,6 +174,7 @@ type ChatStreamResponse struct {
 	SystemFingerprint string         `json:"system_fingerprint,omitempty"`
 	Usage             *Usage         `json:"usage,omitempty"`
 	Provider          string         `json:"provider"`
+	Err               error          `json:"-"` // Terminal error, set on the last element of a failed stream
 }
 
 type StreamChoice struct {
@@ -67,7 +184,30 @@ type StreamChoice struct {
 }
 
 type MessageDelta struct {
-	Role             string `json:"role,omitempty"`
-	Content          string `json:"content,omitempty"`
-	ReasoningContent string `json:"reasoning_content,omitempty"`
+	Role             string           `json:"role,omitempty"`
+	Content          string           `json:"content,omitempty"`
+	ReasoningContent string           `json:"reasoning_content,omitempty"`
+	ReasoningBlocks  []ReasoningBlock `json:"reasoning_blocks,omitempty"` // Reasoning blocks completed by this chunk
+	ToolCalls        []ToolCall       `json:"tool_calls,omitempty"`
+}
+
+// Embedding types
+type EmbeddingRequest struct {
+	Model      string   `json:"model"`                // Embedding model, rotated over EMBEDDING_MODELS when empty
+	Input      []string `json:"input"`                // Texts to embed, split into batches as the provider requires
+	Dimensions int      `json:"dimensions,omitempty"` // Output dimensionality, 0=model default
+}
+
+type EmbeddingResponse struct {
+	Object   string      `json:"object"`
+	Data     []Embedding `json:"data"` // One embedding per input, in input order
+	Model    string      `json:"model"`
+	Usage    Usage       `json:"usage"`
+	Provider string      `json:"provider"`
+}
+
+type Embedding struct {
+	Object    string    `json:"object"`
+	Index     int       `json:"index"` // Position of the input the embedding belongs to
+	Embedding []float32 `json:"embedding"`
 }
@@ -0,0 +1,179 @@
+// Package quota provides per-caller AI token budgets
+// Author: Done-0
+// Created: 2025-09-25
+package quota
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	goRedis "github.com/redis/go-redis/v9"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/provider"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/recorder"
+	"github.com/Done-0/gin-scaffold/internal/redis"
+	"github.com/Done-0/gin-scaffold/internal/types/errno"
+	"github.com/Done-0/gin-scaffold/internal/utils/errorx"
+)
+
+// keyPrefix prefix of the Redis counters, followed by the caller and the period
+const keyPrefix = "ai:quota:"
+
+// Counters outlive their period by a day so late settlements still land on them
+const (
+	dailyTTL   = 2 * 24 * time.Hour
+	monthlyTTL = 32 * 24 * time.Hour
+)
+
+// reserveScript checks every counter against its limit and only then adds the tokens to all of them,
+// returning the 1-based position of the first exceeded counter, or 0 when the tokens were reserved
+var reserveScript = goRedis.NewScript(`
+local tokens = tonumber(ARGV[1])
+for i, key in ipairs(KEYS) do
+	local used = tonumber(redis.call('GET', key) or '0')
+	if used + tokens > tonumber(ARGV[i * 2]) then
+		return i
+	end
+end
+for i, key in ipairs(KEYS) do
+	redis.call('INCRBY', key, tokens)
+	redis.call('EXPIRE', key, ARGV[i * 2 + 1])
+end
+return 0
+`)
+
+// period one budget window of a caller
+type period struct {
+	name  string
+	key   string
+	limit int64
+	ttl   time.Duration
+}
+
+type quota struct {
+	redisManager redis.RedisManager
+}
+
+func New(redisManager redis.RedisManager) Quota {
+	return &quota{redisManager: redisManager}
+}
+
+// Reserve holds tokens against the budgets of the caller set with recorder.WithCaller and rejects the call
+// with errno.ErrTooManyRequests when a budget would be exceeded. Calls without a caller are not limited, and
+// budgets fail open when Redis is unavailable so an outage of the counters does not take down AI calls.
+func (q *quota) Reserve(ctx context.Context, tokens int) (*Reservation, error) {
+	cfg, err := configs.GetConfig()
+	if err != nil || !cfg.AI.Quota.Enabled {
+		return nil, nil
+	}
+
+	caller := recorder.CallerFrom(ctx)
+	if caller == "" {
+		return nil, nil
+	}
+
+	periods := periods(caller, findLimit(caller, &cfg.AI.Quota), time.Now())
+	if len(periods) == 0 {
+		return nil, nil
+	}
+
+	client := q.client()
+	if client == nil {
+		return nil, nil
+	}
+
+	keys := make([]string, len(periods))
+	args := []any{tokens}
+	for i, p := range periods {
+		keys[i] = p.key
+		args = append(args, p.limit, int64(p.ttl.Seconds()))
+	}
+
+	exceeded, err := reserveScript.Run(ctx, client, keys, args...).Int()
+	if err != nil {
+		log.Printf("Failed to reserve AI token quota for caller %s, allowing the call: %v", caller, err)
+		return nil, nil
+	}
+	if exceeded > 0 {
+		p := periods[exceeded-1]
+		return nil, errorx.New(errno.ErrTooManyRequests, errorx.KV("limit", strconv.FormatInt(p.limit, 10)+" tokens"), errorx.KV("period", p.name))
+	}
+
+	return &Reservation{quota: q, keys: keys, reserved: tokens}, nil
+}
+
+// Settle replaces the reserved tokens with the tokens the call actually used, it is safe on a nil reservation
+// and only takes effect once
+func (r *Reservation) Settle(ctx context.Context, used int) {
+	if r == nil || r.settled {
+		return
+	}
+	r.settled = true
+
+	delta := used - r.reserved
+	if delta == 0 {
+		return
+	}
+
+	client := r.quota.client()
+	if client == nil {
+		return
+	}
+
+	// The call is over, settle even when the caller has gone away
+	ctx = context.WithoutCancel(ctx)
+	_, err := client.Pipelined(ctx, func(pipe goRedis.Pipeliner) error {
+		for _, key := range r.keys {
+			pipe.IncrBy(ctx, key, int64(delta))
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to settle AI token quota on %v: %v", r.keys, err)
+	}
+}
+
+// client returns the Redis client, nil when Redis is not initialized
+func (q *quota) client() *goRedis.Client {
+	if q.redisManager == nil {
+		return nil
+	}
+	return q.redisManager.Client()
+}
+
+// findLimit returns the budget configured for the caller, falling back to the default budget
+func findLimit(caller string, cfg *configs.QuotaConfig) configs.QuotaLimitConfig {
+	for _, limit := range cfg.Callers {
+		if limit.Caller == caller {
+			return limit
+		}
+	}
+	return cfg.Default
+}
+
+// periods returns the limited budget windows of the caller at now
+func periods(caller string, limit configs.QuotaLimitConfig, now time.Time) []period {
+	now = now.UTC()
+	var result []period
+	if limit.Daily > 0 {
+		result = append(result, period{name: "day", key: fmt.Sprintf("%s%s:%s", keyPrefix, caller, now.Format("2006-01-02")), limit: limit.Daily, ttl: dailyTTL})
+	}
+	if limit.Monthly > 0 {
+		result = append(result, period{name: "month", key: fmt.Sprintf("%s%s:%s", keyPrefix, caller, now.Format("2006-01")), limit: limit.Monthly, ttl: monthlyTTL})
+	}
+	return result
+}
+
+// EstimateChat estimates the prompt tokens of a chat request before it is sent
+func EstimateChat(req *provider.ChatRequest) int {
+	return provider.CountPromptTokens(req.Model, req)
+}
+
+// EstimateEmbedding estimates the input tokens of an embedding request before it is sent
+func EstimateEmbedding(req *provider.EmbeddingRequest) int {
+	return provider.CountEmbeddingTokens(req.Model, req)
+}
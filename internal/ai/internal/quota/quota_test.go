@@ -0,0 +1,89 @@
+package quota
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/provider"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/recorder"
+)
+
+// nilRedisManager a Redis manager that was never initialized
+type nilRedisManager struct{}
+
+func (nilRedisManager) Client() *redis.Client { return nil }
+func (nilRedisManager) Initialize() error     { return nil }
+func (nilRedisManager) Close() error          { return nil }
+
+func TestPeriods(t *testing.T) {
+	cfg := &configs.QuotaConfig{
+		Default: configs.QuotaLimitConfig{Daily: 1000, Monthly: 20000},
+		Callers: []configs.QuotaLimitConfig{{Caller: "tenant-a", Daily: 0, Monthly: 500}},
+	}
+	now := time.Date(2025, 9, 25, 23, 30, 0, 0, time.FixedZone("UTC+8", 8*3600))
+
+	got := periods("tenant-b", findLimit("tenant-b", cfg), now)
+	if len(got) != 2 {
+		t.Fatalf("default budget periods = %+v, want day and month", got)
+	}
+	if got[0].key != "ai:quota:tenant-b:2025-09-25" || got[0].limit != 1000 || got[0].name != "day" {
+		t.Errorf("daily period = %+v, want the UTC day", got[0])
+	}
+	if got[1].key != "ai:quota:tenant-b:2025-09" || got[1].limit != 20000 || got[1].name != "month" {
+		t.Errorf("monthly period = %+v", got[1])
+	}
+
+	got = periods("tenant-a", findLimit("tenant-a", cfg), now)
+	if len(got) != 1 || got[0].name != "month" || got[0].limit != 500 {
+		t.Errorf("caller budget periods = %+v, want only the monthly limit", got)
+	}
+}
+
+func TestEstimateChat(t *testing.T) {
+	req := &provider.ChatRequest{Messages: []provider.Message{
+		{Role: provider.RoleSystem, Content: "Be brief."},
+		{Role: provider.RoleUser, Content: "你好", Parts: []provider.ContentPart{
+			{Type: provider.PartTypeText, Text: "Describe this"},
+			{Type: provider.PartTypeImageURL, URL: "https://example.com/cat.png"},
+		}},
+	}}
+
+	// 3 for the reply, 3+3, 3+1, 4 for the text part, 85 for the image
+	if got := EstimateChat(req); got != 102 {
+		t.Errorf("EstimateChat() = %d, want 102", got)
+	}
+	if got := EstimateEmbedding(&provider.EmbeddingRequest{Input: []string{"abcd", "abcde"}}); got != 3 {
+		t.Errorf("EstimateEmbedding() = %d, want 3", got)
+	}
+}
+
+func TestReserveFailsOpen(t *testing.T) {
+	testDir := t.TempDir()
+	os.MkdirAll(filepath.Join(testDir, "configs"), 0755)
+	os.WriteFile(filepath.Join(testDir, "configs", "config.local.yml"), []byte(`AI:
+  QUOTA:
+    ENABLED: true
+    DEFAULT:
+      DAILY: 10`), 0644)
+	t.Chdir(testDir)
+	if err := configs.New(); err != nil {
+		t.Fatalf("Failed to initialize config: %v", err)
+	}
+
+	q := New(nilRedisManager{})
+
+	// Calls without a caller and calls while Redis is down are not limited, settling a nil reservation is a no-op
+	for _, ctx := range []context.Context{context.Background(), recorder.WithCaller(context.Background(), "tenant-a")} {
+		reservation, err := q.Reserve(ctx, 100)
+		if err != nil || reservation != nil {
+			t.Errorf("Reserve() = %v, %v, want no reservation", reservation, err)
+		}
+		reservation.Settle(ctx, 200)
+	}
+}
@@ -0,0 +1,20 @@
+// Package quota provides per-caller AI token budgets
+// Author: Done-0
+// Created: 2025-09-25
+package quota
+
+import (
+	"context"
+)
+
+type Quota interface {
+	Reserve(ctx context.Context, tokens int) (*Reservation, error)
+}
+
+// Reservation tokens held against the budgets of a caller until the call is settled
+type Reservation struct {
+	quota    *quota
+	keys     []string
+	reserved int
+	settled  bool
+}
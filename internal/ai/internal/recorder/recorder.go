@@ -0,0 +1,153 @@
+// Package recorder provides AI usage and cost accounting
+// Author: Done-0
+// Created: 2025-09-25
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/provider"
+	"github.com/Done-0/gin-scaffold/internal/db"
+	"github.com/Done-0/gin-scaffold/internal/model/usage"
+)
+
+// dayLayout format of the day column, days are in UTC
+const dayLayout = "2006-01-02"
+
+// maxErrorLength keeps error messages within the error column
+const maxErrorLength = 512
+
+type callerKey struct{}
+
+// WithCaller returns a context whose AI calls are accounted to caller, e.g. a user or tenant ID
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFrom returns the caller set by WithCaller, empty when unknown
+func CallerFrom(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+type recorder struct {
+	dbManager db.DatabaseManager
+}
+
+func New(dbManager db.DatabaseManager) Recorder {
+	return &recorder{dbManager: dbManager}
+}
+
+// Record persists a finished call in the background so accounting never delays the response
+func (r *recorder) Record(ctx context.Context, call *provider.Call) {
+	cfg, err := configs.GetConfig()
+	if err != nil || !cfg.AI.Usage.Enabled {
+		return
+	}
+
+	record := newRecord(CallerFrom(ctx), call, cfg.AI.Usage.Prices)
+	go func() {
+		if err := r.save(context.WithoutCancel(ctx), record); err != nil {
+			log.Printf("Failed to record AI usage for %s provider, instance: %s: %v", call.Provider, call.Instance, err)
+		}
+	}()
+}
+
+// UsageSummary aggregates usage and cost by day, model and caller
+func (r *recorder) UsageSummary(ctx context.Context, query *UsageQuery) ([]UsageSummary, error) {
+	database := r.dbManager.DB()
+	if database == nil {
+		return nil, fmt.Errorf("database is not initialized")
+	}
+
+	tx := database.WithContext(ctx).Model(&usage.AIUsage{}).
+		Select(`day, model, caller, COUNT(*) AS calls,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS failures,
+			SUM(prompt_tokens) AS prompt_tokens,
+			SUM(completion_tokens) AS completion_tokens,
+			SUM(total_tokens) AS total_tokens,
+			SUM(cost) AS cost`, usage.StatusError).
+		Where("deleted = ?", false)
+
+	if query != nil {
+		if !query.From.IsZero() {
+			tx = tx.Where("day >= ?", query.From.UTC().Format(dayLayout))
+		}
+		if !query.To.IsZero() {
+			tx = tx.Where("day <= ?", query.To.UTC().Format(dayLayout))
+		}
+		if query.Model != "" {
+			tx = tx.Where("model = ?", query.Model)
+		}
+		if query.Caller != "" {
+			tx = tx.Where("caller = ?", query.Caller)
+		}
+	}
+
+	var summaries []UsageSummary
+	if err := tx.Group("day, model, caller").Order("day, model, caller").Scan(&summaries).Error; err != nil {
+		return nil, fmt.Errorf("failed to query AI usage: %w", err)
+	}
+	return summaries, nil
+}
+
+// save writes a usage record
+func (r *recorder) save(ctx context.Context, record *usage.AIUsage) error {
+	database := r.dbManager.DB()
+	if database == nil {
+		return fmt.Errorf("database is not initialized")
+	}
+	return database.WithContext(ctx).Create(record).Error
+}
+
+// newRecord converts a call to a usage record, pricing it with the configured model prices
+func newRecord(caller string, call *provider.Call, prices []configs.ModelPriceConfig) *usage.AIUsage {
+	record := &usage.AIUsage{
+		Caller:           caller,
+		Kind:             call.Kind,
+		Provider:         call.Provider,
+		Instance:         call.Instance,
+		Model:            call.Model,
+		KeyIndex:         call.KeyIndex,
+		PromptTokens:     call.Usage.PromptTokens,
+		CompletionTokens: call.Usage.CompletionTokens,
+		TotalTokens:      call.Usage.TotalTokens,
+		LatencyMs:        call.Latency.Milliseconds(),
+		Status:           usage.StatusSuccess,
+		Day:              time.Now().UTC().Format(dayLayout),
+	}
+
+	if price, ok := findPrice(call.Model, prices); ok {
+		record.Cost = (float64(call.Usage.PromptTokens)*price.Input + float64(call.Usage.CompletionTokens)*price.Output) / 1e6
+	}
+
+	if call.Err != nil {
+		record.Status = usage.StatusError
+		record.Error = call.Err.Error()
+		if len(record.Error) > maxErrorLength {
+			// Cutting at a byte offset may split a rune, drop the broken tail
+			record.Error = strings.ToValidUTF8(record.Error[:maxErrorLength], "")
+		}
+	}
+	return record
+}
+
+// findPrice returns the price of the exact model, falling back to the longest configured model name it starts with
+func findPrice(model string, prices []configs.ModelPriceConfig) (configs.ModelPriceConfig, bool) {
+	var best configs.ModelPriceConfig
+	found := false
+	for _, price := range prices {
+		if price.Model == model {
+			return price, true
+		}
+		if strings.HasPrefix(model, price.Model) && len(price.Model) > len(best.Model) {
+			best, found = price, true
+		}
+	}
+	return best, found
+}
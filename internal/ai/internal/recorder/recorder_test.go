@@ -0,0 +1,100 @@
+package recorder
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/provider"
+	"github.com/Done-0/gin-scaffold/internal/db/dbtest"
+	"github.com/Done-0/gin-scaffold/internal/model/usage"
+)
+
+var testPrices = []configs.ModelPriceConfig{
+	{Model: "gpt-4o", Input: 2.5, Output: 10},
+	{Model: "gpt-4o-mini", Input: 0.15, Output: 0.6},
+}
+
+func TestNewRecord(t *testing.T) {
+	call := &provider.Call{
+		Kind:     provider.CallChat,
+		Provider: "openai",
+		Instance: "official",
+		Model:    "gpt-4o-mini-2024-07-18",
+		KeyIndex: 2,
+		Usage:    provider.Usage{PromptTokens: 1_000_000, CompletionTokens: 500_000, TotalTokens: 1_500_000},
+		Latency:  1500 * time.Millisecond,
+	}
+
+	record := newRecord("tenant-a", call, testPrices)
+	// Longest prefix wins: gpt-4o-mini, not gpt-4o
+	if record.Cost != 0.15+0.3 {
+		t.Errorf("cost = %v, want 0.45", record.Cost)
+	}
+	if record.Caller != "tenant-a" || record.KeyIndex != 2 || record.LatencyMs != 1500 || record.Status != usage.StatusSuccess {
+		t.Errorf("record = %+v", record)
+	}
+	if record.Day != time.Now().UTC().Format(dayLayout) {
+		t.Errorf("day = %s", record.Day)
+	}
+
+	call.Model = "unpriced-model"
+	call.Err = errors.New(strings.Repeat("é", maxErrorLength))
+	record = newRecord("", call, testPrices)
+	if record.Cost != 0 || record.Status != usage.StatusError {
+		t.Errorf("unpriced failed record = %+v", record)
+	}
+	if len(record.Error) > maxErrorLength || !strings.HasPrefix(record.Error, "é") || strings.ContainsRune(record.Error, '�') {
+		t.Errorf("error length = %d, want a valid UTF-8 message within %d bytes", len(record.Error), maxErrorLength)
+	}
+}
+
+func TestUsageSummary(t *testing.T) {
+	dbManager := dbtest.New(t, &usage.AIUsage{})
+	r := &recorder{dbManager: dbManager}
+	ctx := context.Background()
+
+	records := []*usage.AIUsage{
+		{Day: "2025-09-01", Caller: "a", Model: "gpt-4o", Kind: provider.CallChat, Provider: "openai", Instance: "x", PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15, Cost: 1.5, Status: usage.StatusSuccess},
+		{Day: "2025-09-01", Caller: "a", Model: "gpt-4o", Kind: provider.CallChat, Provider: "openai", Instance: "x", PromptTokens: 20, CompletionTokens: 5, TotalTokens: 25, Cost: 2, Status: usage.StatusSuccess},
+		{Day: "2025-09-01", Caller: "a", Model: "gpt-4o", Kind: provider.CallChat, Provider: "openai", Instance: "x", Status: usage.StatusError},
+		{Day: "2025-09-01", Caller: "b", Model: "gpt-4o", Kind: provider.CallChat, Provider: "openai", Instance: "x", TotalTokens: 7, Cost: 0.5, Status: usage.StatusSuccess},
+		{Day: "2025-09-02", Caller: "a", Model: "gemini-2.5-pro", Kind: provider.CallChat, Provider: "gemini", Instance: "y", TotalTokens: 9, Cost: 0.25, Status: usage.StatusSuccess},
+		{Day: "2025-10-01", Caller: "a", Model: "gpt-4o", Kind: provider.CallChat, Provider: "openai", Instance: "x", TotalTokens: 100, Cost: 10, Status: usage.StatusSuccess},
+	}
+	for _, record := range records {
+		if err := r.save(ctx, record); err != nil {
+			t.Fatalf("save() failed: %v", err)
+		}
+	}
+
+	summaries, err := r.UsageSummary(ctx, &UsageQuery{
+		From: time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2025, 9, 30, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("UsageSummary() failed: %v", err)
+	}
+	if len(summaries) != 3 {
+		t.Fatalf("summaries = %+v, want 3 day/model/caller groups in September", summaries)
+	}
+
+	first := summaries[0]
+	if first.Day != "2025-09-01" || first.Model != "gpt-4o" || first.Caller != "a" {
+		t.Errorf("first group = %+v", first)
+	}
+	if first.Calls != 3 || first.Failures != 1 || first.PromptTokens != 30 || first.TotalTokens != 40 || first.Cost != 3.5 {
+		t.Errorf("first group totals = %+v", first)
+	}
+
+	summaries, err = r.UsageSummary(ctx, &UsageQuery{Caller: "a", Model: "gpt-4o"})
+	if err != nil {
+		t.Fatalf("UsageSummary() failed: %v", err)
+	}
+	if len(summaries) != 2 || summaries[1].Day != "2025-10-01" {
+		t.Errorf("filtered summaries = %+v", summaries)
+	}
+}
@@ -0,0 +1,37 @@
+// Package recorder provides AI usage and cost accounting
+// Author: Done-0
+// Created: 2025-09-25
+package recorder
+
+import (
+	"context"
+	"time"
+
+	"github.com/Done-0/gin-scaffold/internal/ai/internal/provider"
+)
+
+type Recorder interface {
+	Record(ctx context.Context, call *provider.Call)
+	UsageSummary(ctx context.Context, query *UsageQuery) ([]UsageSummary, error)
+}
+
+// UsageQuery filters usage records, zero values match everything
+type UsageQuery struct {
+	From   time.Time `json:"from"`             // First UTC day included
+	To     time.Time `json:"to"`               // Last UTC day included
+	Model  string    `json:"model,omitempty"`  // Only this model
+	Caller string    `json:"caller,omitempty"` // Only this caller
+}
+
+// UsageSummary usage and cost aggregated by day, model and caller
+type UsageSummary struct {
+	Day              string  `json:"day"`
+	Model            string  `json:"model"`
+	Caller           string  `json:"caller"`
+	Calls            int64   `json:"calls"`
+	Failures         int64   `json:"failures"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
@@ -0,0 +1,141 @@
+// Package tokenizer provides token counting for AI prompts
+// Author: Done-0
+// Created: 2025-09-25
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Pre-tokenization patterns of the encodings. Go regexp has no lookahead, the trailing \s+(?!\S) alternative of
+// the original patterns is emulated in split by giving the last whitespace of a run back to the next piece.
+var (
+	cl100kPattern = regexp.MustCompile(`^(?:(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+)`)
+	o200kPattern  = regexp.MustCompile(`^(?:[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+)`)
+)
+
+// bpe a byte pair encoding loaded from a tiktoken vocabulary file
+type bpe struct {
+	ranks   map[string]int
+	pattern *regexp.Regexp
+}
+
+// loadBPE reads a tiktoken file, one base64 encoded token and its rank per line
+func loadBPE(name, path string) (*bpe, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := &bpe{ranks: make(map[string]int), pattern: cl100kPattern}
+	if name == EncodingO200K {
+		enc.pattern = o200kPattern
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		fields := bytes.Fields(scanner.Bytes())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want a token and a rank", path, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(string(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		rank, err := strconv.Atoi(string(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		enc.ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return enc, nil
+}
+
+// count counts the tokens of text
+func (e *bpe) count(text string) int {
+	tokens := 0
+	for _, piece := range e.split(text) {
+		tokens += e.countPiece(piece)
+	}
+	return tokens
+}
+
+// split cuts text into the pieces BPE merges within
+func (e *bpe) split(text string) []string {
+	var pieces []string
+	for len(text) > 0 {
+		loc := e.pattern.FindStringIndex(text)
+		end := 1
+		if loc != nil && loc[1] > 0 {
+			end = loc[1]
+		}
+
+		// \s+(?!\S): a whitespace run followed by a non-space leaves its last character to the next piece,
+		// runs ending in a line break matched \s*[\r\n]+ and are kept whole
+		piece := text[:end]
+		if end < len(text) && isSpace(piece) {
+			if last, size := utf8.DecodeLastRuneInString(piece); size < len(piece) && last != '\n' && last != '\r' {
+				next, _ := utf8.DecodeRuneInString(text[end:])
+				if !unicode.IsSpace(next) {
+					end -= size
+				}
+			}
+		}
+
+		pieces = append(pieces, text[:end])
+		text = text[end:]
+	}
+	return pieces
+}
+
+// countPiece merges the bytes of a piece by rank until no pair is in the vocabulary
+func (e *bpe) countPiece(piece string) int {
+	if _, ok := e.ranks[piece]; ok {
+		return 1
+	}
+
+	// parts holds the start offsets of the current tokens, followed by the end of the piece
+	parts := make([]int, len(piece)+1)
+	for i := range parts {
+		parts[i] = i
+	}
+
+	for len(parts) > 2 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i+2 < len(parts); i++ {
+			if rank, ok := e.ranks[piece[parts[i]:parts[i+2]]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+	return len(parts) - 1
+}
+
+// isSpace reports whether s consists of whitespace only
+func isSpace(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
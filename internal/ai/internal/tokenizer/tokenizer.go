@@ -0,0 +1,105 @@
+// Package tokenizer provides token counting for AI prompts
+// Author: Done-0
+// Created: 2025-09-25
+package tokenizer
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/Done-0/gin-scaffold/configs"
+)
+
+// Encodings of OpenAI models
+const (
+	EncodingCL100K = "cl100k_base" // gpt-4, gpt-3.5-turbo and text-embedding-3
+	EncodingO200K  = "o200k_base"  // gpt-4o, gpt-4.1, gpt-5 and the o-series
+)
+
+// charsPerToken characters per token assumed by the heuristic used for models without a BPE encoding
+const charsPerToken = 4
+
+// encodingPrefixes maps model name prefixes to their encoding, the longest matching prefix wins
+var encodingPrefixes = map[string]string{
+	"gpt-4o":                 EncodingO200K,
+	"gpt-4.1":                EncodingO200K,
+	"gpt-4.5":                EncodingO200K,
+	"gpt-5":                  EncodingO200K,
+	"chatgpt-4o":             EncodingO200K,
+	"o1":                     EncodingO200K,
+	"o3":                     EncodingO200K,
+	"o4":                     EncodingO200K,
+	"gpt-4":                  EncodingCL100K,
+	"gpt-3.5":                EncodingCL100K,
+	"text-embedding-3":       EncodingCL100K,
+	"text-embedding-ada-002": EncodingCL100K,
+}
+
+var (
+	encodingsMu sync.Mutex
+	encodings   = make(map[string]*bpe) // key: encoding file path, nil when the file failed to load
+)
+
+// Count counts the tokens of text for model, with the model's BPE encoding when its vocabulary file is
+// available in the configured TOKENIZER_DIR and with a heuristic otherwise
+func Count(model, text string) int {
+	if text == "" {
+		return 0
+	}
+	if enc := encodingFor(model); enc != nil {
+		return enc.count(text)
+	}
+	return Estimate(text)
+}
+
+// Estimate estimates the tokens of text, rounding up so short texts are not free
+func Estimate(text string) int {
+	return (utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken
+}
+
+// EncodingName returns the BPE encoding of an OpenAI model, empty for other models
+func EncodingName(model string) string {
+	// Strip routing prefixes such as "openai/gpt-4o"
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+
+	best := ""
+	for prefix := range encodingPrefixes {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	return encodingPrefixes[best]
+}
+
+// encodingFor returns the loaded encoding of model, nil when it has none or its file is unavailable
+func encodingFor(model string) *bpe {
+	name := EncodingName(model)
+	if name == "" {
+		return nil
+	}
+
+	cfg, err := configs.GetConfig()
+	if err != nil || cfg.AI.ContextGuard.TokenizerDir == "" {
+		return nil
+	}
+	path := filepath.Join(cfg.AI.ContextGuard.TokenizerDir, name+".tiktoken")
+
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+
+	enc, loaded := encodings[path]
+	if !loaded {
+		enc, err = loadBPE(name, path)
+		if err != nil {
+			// Remembered as nil so the file is not read again on every call
+			log.Printf("Failed to load %s encoding, estimating tokens instead: %v", name, err)
+		}
+		encodings[path] = enc
+	}
+	return enc
+}
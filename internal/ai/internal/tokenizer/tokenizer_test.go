@@ -0,0 +1,77 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Done-0/gin-scaffold/configs"
+)
+
+// writeVocabulary writes a tiktoken file with every single byte and the merges he, ll and hell
+func writeVocabulary(t *testing.T, path string) {
+	t.Helper()
+	var b strings.Builder
+	for i := 0; i < 256; i++ {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), i)
+	}
+	for i, merge := range []string{"he", "ll", "hell"} {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(merge)), 256+i)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatalf("failed to write vocabulary: %v", err)
+	}
+}
+
+func TestEncodingName(t *testing.T) {
+	tests := map[string]string{
+		"gpt-4o-mini":     EncodingO200K,
+		"openai/gpt-4.1":  EncodingO200K,
+		"gpt-4-turbo":     EncodingCL100K,
+		"gpt-3.5-turbo":   EncodingCL100K,
+		"claude-sonnet-4": "",
+		"qwen3:8b":        "",
+	}
+	for model, want := range tests {
+		if got := EncodingName(model); got != want {
+			t.Errorf("EncodingName(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	testDir := t.TempDir()
+	os.MkdirAll(filepath.Join(testDir, "configs"), 0755)
+	os.WriteFile(filepath.Join(testDir, "configs", "config.local.yml"), []byte(fmt.Sprintf(`AI:
+  CONTEXT_GUARD:
+    TOKENIZER_DIR: %q`, testDir)), 0644)
+	writeVocabulary(t, filepath.Join(testDir, EncodingCL100K+".tiktoken"))
+	t.Chdir(testDir)
+	if err := configs.New(); err != nil {
+		t.Fatalf("Failed to initialize config: %v", err)
+	}
+
+	enc := encodingFor("gpt-4")
+	if enc == nil {
+		t.Fatal("cl100k_base encoding was not loaded")
+	}
+	if got, want := enc.split("hello   world\n\nok"), []string{"hello", "  ", " world", "\n\n", "ok"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("split() = %q, want %q", got, want)
+	}
+
+	// hello merges to hell+o, " world" has no merges
+	if got := Count("gpt-4", "hello world"); got != 8 {
+		t.Errorf("Count() with BPE = %d, want 8", got)
+	}
+	// No o200k_base file, nor an encoding for other models: estimated
+	if got := Count("gpt-4o", "hello world"); got != 3 {
+		t.Errorf("Count() without vocabulary = %d, want the estimate 3", got)
+	}
+	if got := Count("claude-sonnet-4", "hello world"); got != 3 {
+		t.Errorf("Count() for other models = %d, want the estimate 3", got)
+	}
+}
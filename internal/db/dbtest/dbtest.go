@@ -0,0 +1,44 @@
+// Package dbtest provides an in-memory database manager for tests
+// Author: Done-0
+// Created: 2025-09-25
+package dbtest
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/Done-0/gin-scaffold/internal/db"
+)
+
+// manager serves an in-memory SQLite database
+type manager struct {
+	db *gorm.DB
+}
+
+func (m *manager) DB() *gorm.DB      { return m.db }
+func (m *manager) Initialize() error { return nil }
+func (m *manager) Close() error      { return nil }
+
+// New opens an in-memory SQLite database with models migrated, closed when the test ends
+func New(t testing.TB, models ...any) db.DatabaseManager {
+	t.Helper()
+	database, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	// Every connection to :memory: opens a fresh database, so keep a single one
+	sqlDB, err := database.DB()
+	if err != nil {
+		t.Fatalf("failed to get SQL database: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := database.AutoMigrate(models...); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return &manager{db: database}
+}
@@ -0,0 +1,37 @@
+// Package conversation provides AI conversation model definitions
+// Author: Done-0
+// Created: 2025-09-25
+package conversation
+
+import "github.com/Done-0/gin-scaffold/internal/model/base"
+
+// Conversation represents a multi-turn AI chat session
+type Conversation struct {
+	base.Base
+	Caller          string `gorm:"type:varchar(64);index;default:''" json:"caller"` // Owner of the conversation, e.g. a user or tenant ID
+	Title           string `gorm:"type:varchar(255);default:''" json:"title"`       // Conversation title
+	SystemPrompt    string `gorm:"type:text" json:"system_prompt"`                  // System message sent before the history
+	Summary         string `gorm:"type:text" json:"summary"`                        // Summary of the turns folded by the summarize strategy
+	SummarizedUntil int64  `gorm:"type:bigint;default:0" json:"summarized_until"`   // ID of the last message folded into Summary, 0=none
+	MessageCount    int    `gorm:"type:int;default:0" json:"message_count"`         // Number of stored messages
+}
+
+// TableName specifies table name
+func (Conversation) TableName() string {
+	return "ai_conversations"
+}
+
+// Message represents one stored message of a conversation
+type Message struct {
+	base.Base
+	ConversationID int64  `gorm:"type:bigint;index;not null" json:"conversation_id"` // Conversation the message belongs to
+	Role           string `gorm:"type:varchar(16);not null" json:"role"`             // system, user, assistant or tool
+	Content        string `gorm:"type:text" json:"content"`                          // Text content
+	Payload        string `gorm:"type:text" json:"payload"`                          // Full message as JSON, keeps parts and tool calls
+	Tokens         int    `gorm:"type:int;default:0" json:"tokens"`                  // Estimated tokens, used to trim the history
+}
+
+// TableName specifies table name
+func (Message) TableName() string {
+	return "ai_conversation_messages"
+}
@@ -4,12 +4,19 @@
 package model
 
 import (
+	"github.com/Done-0/gin-scaffold/internal/model/conversation"
+	"github.com/Done-0/gin-scaffold/internal/model/prompt"
+	"github.com/Done-0/gin-scaffold/internal/model/usage"
 	"github.com/Done-0/gin-scaffold/internal/model/user"
 )
 
 // GetAllModels gets and registers all models for database migration
 func GetAllModels() []any {
 	return []any{
-		&user.User{}, // User model
+		&user.User{},                 // User model
+		&usage.AIUsage{},             // AI usage model
+		&conversation.Conversation{}, // AI conversation model
+		&conversation.Message{},      // AI conversation message model
+		&prompt.PromptVersion{},      // AI prompt template version model
 	}
 }
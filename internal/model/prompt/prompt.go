@@ -0,0 +1,21 @@
+// Package prompt provides prompt template model definitions
+// Author: Done-0
+// Created: 2025-09-25
+package prompt
+
+import "github.com/Done-0/gin-scaffold/internal/model/base"
+
+// PromptVersion represents one immutable version of a prompt template, the newest version of a path is the current template
+type PromptVersion struct {
+	base.Base
+	Path    string `gorm:"type:varchar(255);not null;uniqueIndex:idx_ai_prompt_path_version" json:"path"` // Template path, e.g. stories/midnight_store
+	Version int    `gorm:"type:int;not null;uniqueIndex:idx_ai_prompt_path_version" json:"version"`       // Version number, starting at 1
+	Author  string `gorm:"type:varchar(64);default:''" json:"author"`                                     // Author of the change
+	Note    string `gorm:"type:varchar(255);default:''" json:"note"`                                      // Change note
+	Content string `gorm:"type:text" json:"content"`                                                      // Template as JSON
+}
+
+// TableName specifies table name
+func (PromptVersion) TableName() string {
+	return "ai_prompt_versions"
+}
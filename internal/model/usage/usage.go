@@ -0,0 +1,36 @@
+// Package usage provides AI usage record model definitions
+// Author: Done-0
+// Created: 2025-09-25
+package usage
+
+import "github.com/Done-0/gin-scaffold/internal/model/base"
+
+// AIUsage represents one provider call with its token usage and cost
+type AIUsage struct {
+	base.Base
+	Day              string  `gorm:"type:varchar(10);index;not null" json:"day"`            // UTC day of the call, e.g. 2025-09-25
+	Caller           string  `gorm:"type:varchar(64);index;default:''" json:"caller"`       // Caller the request was made for, empty when unknown
+	Kind             string  `gorm:"type:varchar(16);not null" json:"kind"`                 // chat, chat_stream or embed
+	Provider         string  `gorm:"type:varchar(32);not null" json:"provider"`             // Provider type
+	Instance         string  `gorm:"type:varchar(64);not null" json:"instance"`             // Provider instance name
+	Model            string  `gorm:"type:varchar(128);index;not null" json:"model"`         // Model name
+	KeyIndex         int     `gorm:"type:int;default:-1" json:"key_index"`                  // Position of the API key in the instance KEYS, -1=no key
+	PromptTokens     int     `gorm:"type:int;default:0" json:"prompt_tokens"`               // Prompt tokens
+	CompletionTokens int     `gorm:"type:int;default:0" json:"completion_tokens"`           // Completion tokens
+	TotalTokens      int     `gorm:"type:int;default:0" json:"total_tokens"`                // Total tokens
+	Cost             float64 `gorm:"type:decimal(20,8);default:0" json:"cost"`              // Cost computed from the configured model price
+	LatencyMs        int64   `gorm:"type:bigint;default:0" json:"latency_ms"`               // Latency in milliseconds
+	Status           string  `gorm:"type:varchar(16);not null" json:"status"`               // success or error
+	Error            string  `gorm:"type:varchar(512);default:null" json:"error,omitempty"` // Error message of a failed call
+}
+
+// Usage statuses
+const (
+	StatusSuccess = "success"
+	StatusError   = "error"
+)
+
+// TableName specifies table name
+func (AIUsage) TableName() string {
+	return "ai_usages"
+}
@@ -0,0 +1,23 @@
+// Package errno provides system-level error code definitions
+// Author: Done-0
+// Created: 2025-09-25
+package errno
+
+import (
+	"github.com/Done-0/gin-scaffold/internal/utils/errorx/code"
+)
+
+// AI error codes: 20000 ~ 29999
+// Used: 20001-20003
+// Next available: 20004
+const (
+	ErrAIStreamFailed   = 20001 // AI stream ended with a provider error
+	ErrAIContextTooLong = 20002 // Prompt does not fit the model context window
+	ErrAIModelNotFound  = 20003 // No enabled provider instance serves the requested model
+)
+
+func init() {
+	code.Register(ErrAIStreamFailed, "AI stream failed: {{.msg}}")
+	code.Register(ErrAIContextTooLong, "prompt of {{.tokens}} tokens exceeds the {{.limit}} tokens the context window of {{.model}} leaves for it")
+	code.Register(ErrAIModelNotFound, "model {{.model}} is not served by any enabled provider instance")
+}
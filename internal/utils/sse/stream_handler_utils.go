@@ -6,12 +6,20 @@ package sse
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/Done-0/gin-scaffold/internal/sse"
+	"github.com/Done-0/gin-scaffold/internal/types/errno"
+	"github.com/Done-0/gin-scaffold/internal/utils/errorx"
+	"github.com/Done-0/gin-scaffold/internal/utils/vo"
 )
 
+// EventError event type of the terminal error event of a stream
+const EventError = "error"
+
 type Handler func(ctx context.Context, ch chan<- *sse.Event)
 
 // Stream processes data using a custom handler function
@@ -38,3 +46,16 @@ func Send(ch chan<- *sse.Event, eventType string, data any) error {
 	}
 	return nil
 }
+
+// SendError emits a terminal error event carrying the errorx code and message, errors without a code are reported as internal errors
+func SendError(ch chan<- *sse.Event, err error) error {
+	var statusErr errorx.StatusError
+	if !errors.As(err, &statusErr) {
+		statusErr = errorx.New(errno.ErrInternalServer, errorx.KV("msg", err.Error())).(errorx.StatusError)
+	}
+
+	return Send(ch, EventError, vo.Error{
+		Code:    strconv.Itoa(int(statusErr.Code())),
+		Message: statusErr.Msg(),
+	})
+}
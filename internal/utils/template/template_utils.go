@@ -16,24 +16,44 @@ type Message struct {
 	Content string `json:"content"`
 }
 
+// funcMap custom functions available to every template
+var funcMap = template.FuncMap{
+	"add": func(a, b int) int {
+		return a + b
+	},
+	"unixToTime": func(unixTime int64) string {
+		return time.Unix(unixTime, 0).Format("2006年01月02日 15时04分")
+	},
+}
+
 // Replace parses and executes a Go template with the given variables and custom functions.
+// Referencing a variable missing from vars fails instead of rendering "<no value>".
 func Replace(text string, vars map[string]any) (string, error) {
-	if text == "" {
-		return "", nil
+	tmpl, err := Parse(text)
+	if err != nil {
+		return "", err
 	}
+	return Execute(tmpl, vars)
+}
 
-	funcMap := template.FuncMap{
-		"add": func(a, b int) int {
-			return a + b
-		},
-		"unixToTime": func(unixTime int64) string {
-			return time.Unix(unixTime, 0).Format("2006年01月02日 15时04分")
-		},
+// Parse compiles a Go template with the custom functions, nil when text is empty. The result is safe for
+// concurrent use, so callers rendering the same text repeatedly can parse it once and reuse it with Execute.
+func Parse(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
 	}
 
-	tmpl, err := template.New("prompt").Funcs(funcMap).Parse(text)
+	tmpl, err := template.New("prompt").Option("missingkey=error").Funcs(funcMap).Parse(text)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %w", err)
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Execute renders a template compiled by Parse with the given variables, an empty string for a nil template
+func Execute(tmpl *template.Template, vars map[string]any) (string, error) {
+	if tmpl == nil {
+		return "", nil
 	}
 
 	var buf bytes.Buffer
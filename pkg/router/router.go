@@ -18,6 +18,11 @@ func New(r *gin.Engine, container *wire.Container) {
 	// Create API v2 route group
 	v2 := r.Group("/api/v2")
 
+	// Create OpenAI-compatible gateway route group
+	gateway := r.Group("/v1")
+
 	// Register routes by modules
 	routes.RegisterTestRoutes(container, v1, v2)
+	routes.RegisterConversationRoutes(container, v1)
+	routes.RegisterGatewayRoutes(container, gateway)
 }
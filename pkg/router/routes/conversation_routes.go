@@ -0,0 +1,21 @@
+// Package routes provides route registration functionality
+// Author: Done-0
+// Created: 2025-09-25
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/Done-0/gin-scaffold/pkg/wire"
+)
+
+// RegisterConversationRoutes registers conversation module routes, authenticated with gateway API keys whose caller
+// owns the conversations
+func RegisterConversationRoutes(container *wire.Container, v1 *gin.RouterGroup) {
+	conversation := v1.Group("/conversation", container.ConversationController.Authenticate)
+	{
+		conversation.POST("/create", container.ConversationController.CreateConversation)
+		conversation.GET("/list", container.ConversationController.ListConversations)
+		conversation.POST("/delete", container.ConversationController.DeleteConversation)
+	}
+}
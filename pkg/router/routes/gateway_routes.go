@@ -0,0 +1,17 @@
+// Package routes provides route registration functionality
+// Author: Done-0
+// Created: 2025-09-25
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/Done-0/gin-scaffold/pkg/wire"
+)
+
+// RegisterGatewayRoutes registers OpenAI-compatible gateway routes, gateway is the /v1 group OpenAI SDK clients use as base URL
+func RegisterGatewayRoutes(container *wire.Container, gateway *gin.RouterGroup) {
+	gateway.Use(container.GatewayController.Authenticate)
+	gateway.POST("/chat/completions", container.GatewayController.ChatCompletions)
+	gateway.GET("/models", container.GatewayController.ListModels)
+}
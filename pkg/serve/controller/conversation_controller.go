@@ -0,0 +1,136 @@
+// Package controller provides conversation controller
+// Author: Done-0
+// Created: 2025-09-25
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/ai"
+	"github.com/Done-0/gin-scaffold/internal/types/errno"
+	"github.com/Done-0/gin-scaffold/internal/utils/errorx"
+	"github.com/Done-0/gin-scaffold/internal/utils/validator"
+	"github.com/Done-0/gin-scaffold/internal/utils/vo"
+	"github.com/Done-0/gin-scaffold/pkg/serve/controller/dto"
+	"github.com/Done-0/gin-scaffold/pkg/serve/service"
+)
+
+// ConversationController conversation HTTP controller
+type ConversationController struct {
+	conversationService service.ConversationService
+}
+
+// NewConversationController creates conversation controller
+func NewConversationController(conversationService service.ConversationService) *ConversationController {
+	return &ConversationController{
+		conversationService: conversationService,
+	}
+}
+
+// Authenticate requires a gateway API key with a caller as Bearer token, conversations belong to the caller of the
+// key. The keys are read on every request, so key changes apply on config reload.
+func (cc *ConversationController) Authenticate(c *gin.Context) {
+	cfg, err := configs.GetConfig()
+	if err == nil {
+		if key, ok := gatewayKey(c, cfg.AI.Gateway.Keys); ok && key.Caller != "" {
+			c.Request = c.Request.WithContext(ai.WithCaller(c.Request.Context(), key.Caller))
+			c.Next()
+			return
+		}
+	}
+
+	c.JSON(http.StatusUnauthorized, vo.Fail(c, nil, errorx.New(errno.ErrUnauthorized, errorx.KV("msg", "API key with a caller required"))))
+	c.Abort()
+}
+
+// CreateConversation handles conversation creation endpoint
+// @Router /api/v1/conversation/create [post]
+func (cc *ConversationController) CreateConversation(c *gin.Context) {
+	req := &dto.CreateConversationRequest{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, vo.Fail(c, err, errorx.New(errno.ErrInvalidParams, errorx.KV("msg", "bind JSON failed"))))
+		return
+	}
+
+	validationErrors := validator.Validate(req)
+	if validationErrors != nil {
+		c.JSON(http.StatusBadRequest, vo.Fail(c, validationErrors, errorx.New(errno.ErrInvalidParams, errorx.KV("msg", "validation failed"))))
+		return
+	}
+
+	response, err := cc.conversationService.CreateConversation(c, req)
+	if err != nil {
+		conversationServiceFail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, vo.Success(c, response))
+}
+
+// ListConversations handles conversation listing endpoint
+// @Router /api/v1/conversation/list [get]
+func (cc *ConversationController) ListConversations(c *gin.Context) {
+	req := &dto.ListConversationsRequest{}
+	if err := c.ShouldBindQuery(req); err != nil {
+		c.JSON(http.StatusBadRequest, vo.Fail(c, err, errorx.New(errno.ErrInvalidParams, errorx.KV("msg", "bind query failed"))))
+		return
+	}
+
+	validationErrors := validator.Validate(req)
+	if validationErrors != nil {
+		c.JSON(http.StatusBadRequest, vo.Fail(c, validationErrors, errorx.New(errno.ErrInvalidParams, errorx.KV("msg", "validation failed"))))
+		return
+	}
+
+	response, err := cc.conversationService.ListConversations(c, req)
+	if err != nil {
+		conversationServiceFail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, vo.Success(c, response))
+}
+
+// DeleteConversation handles conversation deletion endpoint
+// @Router /api/v1/conversation/delete [post]
+func (cc *ConversationController) DeleteConversation(c *gin.Context) {
+	req := &dto.DeleteConversationRequest{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, vo.Fail(c, err, errorx.New(errno.ErrInvalidParams, errorx.KV("msg", "bind JSON failed"))))
+		return
+	}
+
+	validationErrors := validator.Validate(req)
+	if validationErrors != nil {
+		c.JSON(http.StatusBadRequest, vo.Fail(c, validationErrors, errorx.New(errno.ErrInvalidParams, errorx.KV("msg", "validation failed"))))
+		return
+	}
+
+	response, err := cc.conversationService.DeleteConversation(c, req)
+	if err != nil {
+		conversationServiceFail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, vo.Success(c, response))
+}
+
+// conversationServiceFail maps a service error to its status, unknown errors are reported as internal errors
+func conversationServiceFail(c *gin.Context, err error) {
+	var statusErr errorx.StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.Code() {
+		case errno.ErrResourceNotFound:
+			c.JSON(http.StatusNotFound, vo.Fail(c, err, statusErr))
+			return
+		case errno.ErrUnauthorized:
+			c.JSON(http.StatusUnauthorized, vo.Fail(c, err, statusErr))
+			return
+		}
+	}
+	c.JSON(http.StatusInternalServerError, vo.Fail(c, err, errorx.New(errno.ErrInternalServer)))
+}
@@ -0,0 +1,21 @@
+// Package dto provides conversation-related data transfer object definitions
+// Author: Done-0
+// Created: 2025-09-25
+package dto
+
+// CreateConversationRequest create conversation request
+type CreateConversationRequest struct {
+	Title        string `json:"title" validate:"omitempty,max=255"`
+	SystemPrompt string `json:"system_prompt"`
+}
+
+// ListConversationsRequest list conversations request
+type ListConversationsRequest struct {
+	Page     int `form:"page" validate:"omitempty,min=1"`
+	PageSize int `form:"page_size" validate:"omitempty,min=1,max=100"`
+}
+
+// DeleteConversationRequest delete conversation request
+type DeleteConversationRequest struct {
+	ID int64 `json:"id,string" validate:"required"`
+}
@@ -0,0 +1,79 @@
+// Package dto provides OpenAI-compatible gateway data transfer object definitions
+// Author: Done-0
+// Created: 2025-09-25
+package dto
+
+import "encoding/json"
+
+// ChatCompletionRequest OpenAI chat completion request
+type ChatCompletionRequest struct {
+	Model               string                       `json:"model" validate:"required"`
+	Messages            []ChatCompletionMessage      `json:"messages" validate:"required,min=1,dive"`
+	MaxTokens           int                          `json:"max_tokens" validate:"omitempty,min=1"`
+	MaxCompletionTokens int                          `json:"max_completion_tokens" validate:"omitempty,min=1"` // Preferred over max_tokens when both are set
+	Temperature         *float32                     `json:"temperature" validate:"omitempty,min=0,max=2"`
+	TopP                *float32                     `json:"top_p" validate:"omitempty,min=0,max=1"`
+	Stop                json.RawMessage              `json:"stop"` // String or array of strings
+	Seed                *int                         `json:"seed"`
+	ResponseFormat      *ChatCompletionFormat        `json:"response_format"`
+	Tools               []ChatCompletionTool         `json:"tools" validate:"omitempty,dive"`
+	ToolChoice          json.RawMessage              `json:"tool_choice"` // "auto", "none", "required" or {"type":"function","function":{"name":...}}
+	Stream              bool                         `json:"stream"`
+	StreamOptions       *ChatCompletionStreamOptions `json:"stream_options"`
+	User                string                       `json:"user"` // Accepted for compatibility, the caller comes from the API key
+}
+
+// ChatCompletionMessage OpenAI chat message, content is a string or an array of content parts
+type ChatCompletionMessage struct {
+	Role             string                   `json:"role" validate:"required,oneof=system developer user assistant tool"`
+	Content          json.RawMessage          `json:"content"`
+	ReasoningContent string                   `json:"reasoning_content"`
+	Name             string                   `json:"name"`
+	ToolCalls        []ChatCompletionToolCall `json:"tool_calls"`
+	ToolCallID       string                   `json:"tool_call_id"`
+}
+
+// ChatCompletionContentPart OpenAI content part of a multimodal message
+type ChatCompletionContentPart struct {
+	Type     string `json:"type"` // text or image_url
+	Text     string `json:"text"`
+	ImageURL *struct {
+		URL    string `json:"url"`    // Remote or data URL
+		Detail string `json:"detail"` // low, high or auto
+	} `json:"image_url"`
+}
+
+// ChatCompletionToolCall OpenAI tool call of an assistant message
+type ChatCompletionToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// ChatCompletionTool OpenAI tool definition
+type ChatCompletionTool struct {
+	Type     string `json:"type" validate:"required,eq=function"`
+	Function struct {
+		Name        string         `json:"name" validate:"required"`
+		Description string         `json:"description"`
+		Parameters  map[string]any `json:"parameters"`
+	} `json:"function"`
+}
+
+// ChatCompletionFormat OpenAI response format
+type ChatCompletionFormat struct {
+	Type       string `json:"type" validate:"required,oneof=text json_object json_schema"`
+	JSONSchema *struct {
+		Name   string         `json:"name"`
+		Schema map[string]any `json:"schema"`
+		Strict bool           `json:"strict"`
+	} `json:"json_schema"`
+}
+
+// ChatCompletionStreamOptions OpenAI stream options
+type ChatCompletionStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"` // Send a final chunk carrying the usage
+}
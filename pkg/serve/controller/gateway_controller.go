@@ -0,0 +1,159 @@
+// Package controller provides OpenAI-compatible gateway controller
+// Author: Done-0
+// Created: 2025-09-25
+package controller
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/ai"
+	"github.com/Done-0/gin-scaffold/internal/sse"
+	"github.com/Done-0/gin-scaffold/internal/types/errno"
+	"github.com/Done-0/gin-scaffold/internal/utils/errorx"
+	"github.com/Done-0/gin-scaffold/internal/utils/validator"
+	"github.com/Done-0/gin-scaffold/internal/utils/vo"
+	"github.com/Done-0/gin-scaffold/pkg/serve/controller/dto"
+	"github.com/Done-0/gin-scaffold/pkg/serve/service"
+
+	gatewayVo "github.com/Done-0/gin-scaffold/pkg/vo"
+)
+
+// OpenAI error types
+const (
+	gatewayErrInvalidRequest = "invalid_request_error"
+	gatewayErrAuthentication = "authentication_error"
+	gatewayErrRateLimit      = "rate_limit_error"
+	gatewayErrAPI            = "api_error"
+)
+
+// GatewayController OpenAI-compatible gateway HTTP controller, responses use the OpenAI wire format instead of vo.Result
+type GatewayController struct {
+	gatewayService service.GatewayService
+	sseManager     sse.SSEManager
+}
+
+// NewGatewayController creates OpenAI-compatible gateway controller
+func NewGatewayController(gatewayService service.GatewayService, sseManager sse.SSEManager) *GatewayController {
+	return &GatewayController{
+		gatewayService: gatewayService,
+		sseManager:     sseManager,
+	}
+}
+
+// Authenticate checks the Bearer token against the gateway keys and accounts the request to the caller of the key.
+// The keys are read on every request, so key changes apply on config reload.
+func (gc *GatewayController) Authenticate(c *gin.Context) {
+	cfg, err := configs.GetConfig()
+	if err != nil || !cfg.AI.Gateway.Enabled {
+		gatewayFail(c, http.StatusNotFound, gatewayErrInvalidRequest, errorx.New(errno.ErrResourceNotFound, errorx.KV("resource", "route"), errorx.KV("id", c.Request.URL.Path)))
+		c.Abort()
+		return
+	}
+
+	if key, ok := gatewayKey(c, cfg.AI.Gateway.Keys); ok {
+		if key.Caller != "" {
+			c.Request = c.Request.WithContext(ai.WithCaller(c.Request.Context(), key.Caller))
+		}
+		c.Next()
+		return
+	}
+
+	gatewayFail(c, http.StatusUnauthorized, gatewayErrAuthentication, errorx.New(errno.ErrUnauthorized, errorx.KV("msg", "invalid gateway API key")))
+	c.Abort()
+}
+
+// gatewayKey returns the gateway key sent as Bearer token, false when the request carries none of keys
+func gatewayKey(c *gin.Context, keys []configs.GatewayKeyConfig) (configs.GatewayKeyConfig, bool) {
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !found || token == "" {
+		return configs.GatewayKeyConfig{}, false
+	}
+	for _, key := range keys {
+		if key.Key != "" && subtle.ConstantTimeCompare([]byte(token), []byte(key.Key)) == 1 {
+			return key, true
+		}
+	}
+	return configs.GatewayKeyConfig{}, false
+}
+
+// ChatCompletions handles OpenAI chat completions endpoint, streaming when the request sets stream
+// @Router /v1/chat/completions [post]
+func (gc *GatewayController) ChatCompletions(c *gin.Context) {
+	req := &dto.ChatCompletionRequest{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		gatewayFail(c, http.StatusBadRequest, gatewayErrInvalidRequest, errorx.New(errno.ErrInvalidParams, errorx.KV("msg", "bind JSON failed")))
+		return
+	}
+
+	validationErrors := validator.Validate(req)
+	if validationErrors != nil {
+		gatewayFail(c, http.StatusBadRequest, gatewayErrInvalidRequest, errorx.New(errno.ErrInvalidParams, errorx.KV("msg", "validation failed: "+validationErrors[0].Field)))
+		return
+	}
+
+	if req.Stream {
+		events, err := gc.gatewayService.ChatCompletionsStream(c, req)
+		if err != nil {
+			gatewayServiceFail(c, err)
+			return
+		}
+		_ = gc.sseManager.StreamToClient(c, events)
+		return
+	}
+
+	response, err := gc.gatewayService.ChatCompletions(c, req)
+	if err != nil {
+		gatewayServiceFail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ListModels handles OpenAI model listing endpoint
+// @Router /v1/models [get]
+func (gc *GatewayController) ListModels(c *gin.Context) {
+	response, err := gc.gatewayService.ListModels(c)
+	if err != nil {
+		gatewayServiceFail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// gatewayServiceFail maps a service error to the OpenAI status and error type, provider failures are reported as 502
+func gatewayServiceFail(c *gin.Context, err error) {
+	var statusErr errorx.StatusError
+	if !errors.As(err, &statusErr) {
+		gatewayFail(c, http.StatusBadGateway, gatewayErrAPI, errorx.New(errno.ErrServiceUnavailable, errorx.KV("service", err.Error())))
+		return
+	}
+
+	switch statusErr.Code() {
+	case errno.ErrInvalidParams, errno.ErrAIContextTooLong:
+		gatewayFail(c, http.StatusBadRequest, gatewayErrInvalidRequest, statusErr)
+	case errno.ErrAIModelNotFound:
+		gatewayFail(c, http.StatusNotFound, gatewayErrInvalidRequest, statusErr)
+	case errno.ErrTooManyRequests:
+		gatewayFail(c, http.StatusTooManyRequests, gatewayErrRateLimit, statusErr)
+	default:
+		gatewayFail(c, http.StatusInternalServerError, gatewayErrAPI, statusErr)
+	}
+}
+
+// gatewayFail writes an OpenAI error body carrying the localized errorx message and code
+func gatewayFail(c *gin.Context, status int, errType string, err error) {
+	result := vo.Fail(c, nil, err)
+	c.JSON(status, gatewayVo.GatewayErrorResponse{Error: gatewayVo.GatewayError{
+		Message: result.Error.Message,
+		Type:    errType,
+		Code:    result.Error.Code,
+	}})
+}
@@ -0,0 +1,18 @@
+// Package service provides conversation service interfaces
+// Author: Done-0
+// Created: 2025-09-25
+package service
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/Done-0/gin-scaffold/pkg/serve/controller/dto"
+	"github.com/Done-0/gin-scaffold/pkg/vo"
+)
+
+// ConversationService conversation service interface
+type ConversationService interface {
+	CreateConversation(c *gin.Context, req *dto.CreateConversationRequest) (*vo.ConversationResponse, error)
+	ListConversations(c *gin.Context, req *dto.ListConversationsRequest) (*vo.ListConversationsResponse, error)
+	DeleteConversation(c *gin.Context, req *dto.DeleteConversationRequest) (*vo.DeleteConversationResponse, error)
+}
@@ -0,0 +1,19 @@
+// Package service provides OpenAI-compatible gateway service interfaces
+// Author: Done-0
+// Created: 2025-09-25
+package service
+
+import (
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	"github.com/Done-0/gin-scaffold/pkg/serve/controller/dto"
+	"github.com/Done-0/gin-scaffold/pkg/vo"
+)
+
+// GatewayService OpenAI-compatible gateway service interface
+type GatewayService interface {
+	ChatCompletions(c *gin.Context, req *dto.ChatCompletionRequest) (*vo.ChatCompletionResponse, error)
+	ChatCompletionsStream(c *gin.Context, req *dto.ChatCompletionRequest) (<-chan *sse.Event, error)
+	ListModels(c *gin.Context) (*vo.ModelListResponse, error)
+}
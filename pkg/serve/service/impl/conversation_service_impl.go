@@ -0,0 +1,134 @@
+// Package impl provides conversation service implementation
+// Author: Done-0
+// Created: 2025-09-25
+package impl
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Done-0/gin-scaffold/internal/ai"
+	"github.com/Done-0/gin-scaffold/internal/logger"
+	"github.com/Done-0/gin-scaffold/internal/types/errno"
+	"github.com/Done-0/gin-scaffold/internal/utils/errorx"
+	"github.com/Done-0/gin-scaffold/pkg/serve/controller/dto"
+	"github.com/Done-0/gin-scaffold/pkg/serve/service"
+	"github.com/Done-0/gin-scaffold/pkg/vo"
+)
+
+// defaultConversationPageSize conversations per page when the request does not set one
+const defaultConversationPageSize = 20
+
+// ConversationServiceImpl conversation service implementation
+type ConversationServiceImpl struct {
+	loggerManager logger.LoggerManager
+	aiManager     *ai.AIManager
+}
+
+// NewConversationService creates conversation service implementation
+func NewConversationService(loggerManager logger.LoggerManager, aiManager *ai.AIManager) service.ConversationService {
+	return &ConversationServiceImpl{
+		loggerManager: loggerManager,
+		aiManager:     aiManager,
+	}
+}
+
+// CreateConversation handles conversation creation, the conversation belongs to the authenticated caller
+func (cs *ConversationServiceImpl) CreateConversation(c *gin.Context, req *dto.CreateConversationRequest) (*vo.ConversationResponse, error) {
+	caller, err := requestCaller(c)
+	if err != nil {
+		return nil, err
+	}
+
+	conv := &ai.Conversation{
+		Caller:       caller,
+		Title:        req.Title,
+		SystemPrompt: req.SystemPrompt,
+	}
+	if err := cs.aiManager.CreateConversation(c.Request.Context(), conv); err != nil {
+		cs.loggerManager.Logger().Errorf("failed to create conversation: %v", err)
+		return nil, err
+	}
+	return toConversationResponse(conv), nil
+}
+
+// ListConversations handles conversation listing, only the conversations of the authenticated caller are listed
+func (cs *ConversationServiceImpl) ListConversations(c *gin.Context, req *dto.ListConversationsRequest) (*vo.ListConversationsResponse, error) {
+	caller, err := requestCaller(c)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &ai.ConversationQuery{
+		Caller:   caller,
+		Page:     max(req.Page, 1),
+		PageSize: req.PageSize,
+	}
+	if query.PageSize == 0 {
+		query.PageSize = defaultConversationPageSize
+	}
+	convs, total, err := cs.aiManager.ListConversations(c.Request.Context(), query)
+	if err != nil {
+		cs.loggerManager.Logger().Errorf("failed to list conversations: %v", err)
+		return nil, err
+	}
+
+	items := make([]*vo.ConversationResponse, len(convs))
+	for i := range convs {
+		items[i] = toConversationResponse(&convs[i])
+	}
+	return &vo.ListConversationsResponse{
+		Items:    items,
+		Total:    total,
+		Page:     query.Page,
+		PageSize: query.PageSize,
+	}, nil
+}
+
+// DeleteConversation handles conversation deletion, conversations of other callers are reported as not found
+func (cs *ConversationServiceImpl) DeleteConversation(c *gin.Context, req *dto.DeleteConversationRequest) (*vo.DeleteConversationResponse, error) {
+	caller, err := requestCaller(c)
+	if err != nil {
+		return nil, err
+	}
+
+	conv, err := cs.aiManager.GetConversation(c.Request.Context(), req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if conv.Caller != caller {
+		return nil, errorx.New(errno.ErrResourceNotFound, errorx.KV("resource", "conversation"), errorx.KV("id", strconv.FormatInt(req.ID, 10)))
+	}
+
+	if err := cs.aiManager.DeleteConversation(c.Request.Context(), req.ID); err != nil {
+		cs.loggerManager.Logger().Errorf("failed to delete conversation %d: %v", req.ID, err)
+		return nil, err
+	}
+	return &vo.DeleteConversationResponse{
+		ID:      req.ID,
+		Message: "Conversation deleted",
+	}, nil
+}
+
+// requestCaller returns the caller the request was authenticated as, conversations are never shared between callers
+func requestCaller(c *gin.Context) (string, error) {
+	caller := ai.CallerFrom(c.Request.Context())
+	if caller == "" {
+		return "", errorx.New(errno.ErrUnauthorized, errorx.KV("msg", "API key with a caller required"))
+	}
+	return caller, nil
+}
+
+// toConversationResponse converts a conversation to its response
+func toConversationResponse(conv *ai.Conversation) *vo.ConversationResponse {
+	return &vo.ConversationResponse{
+		ID:           conv.ID,
+		Caller:       conv.Caller,
+		Title:        conv.Title,
+		SystemPrompt: conv.SystemPrompt,
+		MessageCount: conv.MessageCount,
+		CreatedAt:    conv.CreatedAt,
+		UpdatedAt:    conv.UpdatedAt,
+	}
+}
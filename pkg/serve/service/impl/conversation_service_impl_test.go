@@ -0,0 +1,92 @@
+// Package impl provides conversation service implementation test
+// Author: Done-0
+// Created: 2025-09-25
+package impl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/ai"
+	"github.com/Done-0/gin-scaffold/internal/db/dbtest"
+	"github.com/Done-0/gin-scaffold/pkg/serve/controller"
+	"github.com/Done-0/gin-scaffold/pkg/vo"
+
+	conversationModel "github.com/Done-0/gin-scaffold/internal/model/conversation"
+)
+
+func TestConversationCallerIsolation(t *testing.T) {
+	initGatewayConfig(t, "http://127.0.0.1:0")
+	cfg, err := configs.GetConfig()
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	aiManager, err := ai.New(cfg, dbtest.New(t, &conversationModel.Conversation{}, &conversationModel.Message{}), nil)
+	if err != nil {
+		t.Fatalf("Failed to create AI manager: %v", err)
+	}
+	conversationController := controller.NewConversationController(NewConversationService(newTestLoggerManager(), aiManager))
+	router := gin.New()
+	conversation := router.Group("/api/v1/conversation", conversationController.Authenticate)
+	conversation.POST("/create", conversationController.CreateConversation)
+	conversation.GET("/list", conversationController.ListConversations)
+	conversation.POST("/delete", conversationController.DeleteConversation)
+
+	do := func(method, path, key, body string, data any) int {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if data != nil && w.Code == http.StatusOK {
+			result := struct {
+				Data any `json:"data"`
+			}{Data: data}
+			if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+				t.Fatalf("invalid response %s: %v", w.Body.String(), err)
+			}
+		}
+		return w.Code
+	}
+
+	// The caller comes from the key, a caller in the body is ignored
+	var created vo.ConversationResponse
+	if code := do(http.MethodPost, "/api/v1/conversation/create", "test-gateway-key", `{"caller": "tenant-b", "title": "Trip"}`, &created); code != http.StatusOK {
+		t.Fatalf("create status = %d, want 200", code)
+	}
+	if created.Caller != "tenant-a" {
+		t.Errorf("caller = %q, want tenant-a of the key", created.Caller)
+	}
+
+	for _, key := range []string{"", "wrong-key", "anonymous-gateway-key"} {
+		if code := do(http.MethodGet, "/api/v1/conversation/list", key, "", nil); code != http.StatusUnauthorized {
+			t.Errorf("list with key %q status = %d, want 401", key, code)
+		}
+	}
+
+	var list vo.ListConversationsResponse
+	if code := do(http.MethodGet, "/api/v1/conversation/list?caller=tenant-a", "other-gateway-key", "", &list); code != http.StatusOK || list.Total != 0 {
+		t.Errorf("list of another caller = %d, %d conversations, want none", code, list.Total)
+	}
+	if code := do(http.MethodGet, "/api/v1/conversation/list", "test-gateway-key", "", &list); code != http.StatusOK || list.Total != 1 {
+		t.Errorf("list of the owner = %d, %d conversations, want 1", code, list.Total)
+	}
+
+	deleteBody := `{"id": "` + strconv.FormatInt(created.ID, 10) + `"}`
+	if code := do(http.MethodPost, "/api/v1/conversation/delete", "other-gateway-key", deleteBody, nil); code != http.StatusNotFound {
+		t.Errorf("delete by another caller status = %d, want 404", code)
+	}
+	if code := do(http.MethodPost, "/api/v1/conversation/delete", "test-gateway-key", deleteBody, nil); code != http.StatusOK {
+		t.Errorf("delete by the owner status = %d, want 200", code)
+	}
+}
@@ -0,0 +1,451 @@
+// Package impl provides OpenAI-compatible gateway service implementation
+// Author: Done-0
+// Created: 2025-09-25
+package impl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/ai"
+	"github.com/Done-0/gin-scaffold/internal/logger"
+	"github.com/Done-0/gin-scaffold/internal/types/errno"
+	"github.com/Done-0/gin-scaffold/internal/utils/errorx"
+	"github.com/Done-0/gin-scaffold/pkg/serve/controller/dto"
+	"github.com/Done-0/gin-scaffold/pkg/serve/service"
+	"github.com/Done-0/gin-scaffold/pkg/vo"
+
+	utilsVo "github.com/Done-0/gin-scaffold/internal/utils/vo"
+)
+
+// streamDone data of the event that ends an OpenAI stream
+const streamDone = "[DONE]"
+
+// GatewayServiceImpl OpenAI-compatible gateway service implementation
+type GatewayServiceImpl struct {
+	loggerManager logger.LoggerManager
+	aiManager     *ai.AIManager
+}
+
+// NewGatewayService creates OpenAI-compatible gateway service implementation
+func NewGatewayService(loggerManager logger.LoggerManager, aiManager *ai.AIManager) service.GatewayService {
+	return &GatewayServiceImpl{
+		loggerManager: loggerManager,
+		aiManager:     aiManager,
+	}
+}
+
+// ChatCompletions handles non-streaming chat completions
+func (gs *GatewayServiceImpl) ChatCompletions(c *gin.Context, req *dto.ChatCompletionRequest) (*vo.ChatCompletionResponse, error) {
+	chatReq, err := toChatRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := gs.aiManager.Chat(c.Request.Context(), chatReq)
+	if err != nil {
+		gs.loggerManager.Logger().Errorf("gateway chat completion for model %s failed: %v", req.Model, err)
+		return nil, err
+	}
+	return toChatCompletion(resp, req.Model), nil
+}
+
+// ChatCompletionsStream handles streaming chat completions. The stream is opened before the first event so
+// failover and quota errors are still returned as HTTP errors; it ends with data: [DONE], or with an error
+// object when the provider fails mid-stream.
+func (gs *GatewayServiceImpl) ChatCompletionsStream(c *gin.Context, req *dto.ChatCompletionRequest) (<-chan *sse.Event, error) {
+	chatReq, err := toChatRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := gs.aiManager.ChatStream(c.Request.Context(), chatReq)
+	if err != nil {
+		gs.loggerManager.Logger().Errorf("gateway chat completion stream for model %s failed: %v", req.Model, err)
+		return nil, err
+	}
+
+	includeUsage := req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+	events := make(chan *sse.Event, 100)
+
+	go func() {
+		defer close(events)
+
+		var last *vo.ChatCompletionChunk
+		var usage *ai.Usage
+		for resp := range stream {
+			if resp == nil {
+				continue
+			}
+
+			if resp.Err != nil {
+				gs.loggerManager.Logger().Errorf("gateway chat completion stream for model %s failed: %v", req.Model, resp.Err)
+				sendGatewayEvent(events, gatewayErrorResponse(c, errorx.New(errno.ErrAIStreamFailed, errorx.KV("msg", resp.Err.Error()))))
+				return
+			}
+
+			if resp.Usage != nil {
+				usage = resp.Usage
+			}
+			chunk := toChatCompletionChunk(resp, req.Model)
+			// Some providers number every chunk, OpenAI streams keep the ID of the first one
+			if last != nil {
+				chunk.ID = last.ID
+			}
+			last = chunk
+			if len(chunk.Choices) > 0 {
+				sendGatewayEvent(events, chunk)
+			}
+		}
+
+		// OpenAI sends the usage in a final chunk without choices when asked for it
+		if includeUsage && usage != nil && last != nil {
+			sendGatewayEvent(events, &vo.ChatCompletionChunk{
+				ID:                last.ID,
+				Object:            last.Object,
+				Created:           last.Created,
+				Model:             last.Model,
+				Choices:           []vo.ChatCompletionChunkChoice{},
+				Usage:             toChatCompletionUsage(usage),
+				SystemFingerprint: last.SystemFingerprint,
+			})
+		}
+		events <- &sse.Event{Data: streamDone}
+	}()
+
+	return events, nil
+}
+
+// ListModels handles model listing, every model of an enabled instance is listed once, owned by the first provider serving it
+func (gs *GatewayServiceImpl) ListModels(c *gin.Context) (*vo.ModelListResponse, error) {
+	cfg, err := configs.GetConfig()
+	if err != nil {
+		gs.loggerManager.Logger().Errorf("failed to get config: %v", err)
+		return nil, err
+	}
+
+	owners := servedModels(cfg)
+	models := make([]vo.ModelEntry, 0, len(owners))
+	for model, owner := range owners {
+		models = append(models, vo.ModelEntry{ID: model, Object: "model", OwnedBy: owner})
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+
+	return &vo.ModelListResponse{Object: "list", Data: models}, nil
+}
+
+// servedModels maps the models of the enabled instances to the first provider serving them, in provider name order
+func servedModels(cfg *configs.Config) map[string]string {
+	names := make([]string, 0, len(cfg.AI.Providers))
+	for name := range cfg.AI.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	owners := make(map[string]string)
+	for _, name := range names {
+		prov := cfg.AI.Providers[name]
+		if !prov.Enabled {
+			continue
+		}
+		for _, inst := range prov.Instances {
+			if !inst.Enabled {
+				continue
+			}
+			for _, model := range inst.Models {
+				if _, exists := owners[model]; !exists {
+					owners[model] = name
+				}
+			}
+		}
+	}
+	return owners
+}
+
+// toChatRequest translates an OpenAI chat completion request, rejecting models no enabled instance serves
+func toChatRequest(req *dto.ChatCompletionRequest) (*ai.ChatRequest, error) {
+	cfg, err := configs.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	if _, served := servedModels(cfg)[req.Model]; !served {
+		return nil, errorx.New(errno.ErrAIModelNotFound, errorx.KV("model", req.Model))
+	}
+
+	chatReq := &ai.ChatRequest{
+		Model:       req.Model,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		Seed:        req.Seed,
+	}
+	if req.MaxCompletionTokens > 0 {
+		chatReq.MaxTokens = req.MaxCompletionTokens
+	}
+
+	for i := range req.Messages {
+		msg, err := toMessage(&req.Messages[i])
+		if err != nil {
+			return nil, invalidParams("messages[%d]: %v", i, err)
+		}
+		chatReq.Messages = append(chatReq.Messages, msg)
+	}
+
+	if chatReq.Stop, err = toStop(req.Stop); err != nil {
+		return nil, invalidParams("stop: %v", err)
+	}
+	if chatReq.ToolChoice, err = toToolChoice(req.ToolChoice); err != nil {
+		return nil, invalidParams("tool_choice: %v", err)
+	}
+
+	for _, tool := range req.Tools {
+		chatReq.Tools = append(chatReq.Tools, ai.Tool{
+			Type: ai.ToolTypeFunction,
+			Function: ai.FunctionDefinition{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  tool.Function.Parameters,
+			},
+		})
+	}
+
+	if format := req.ResponseFormat; format != nil {
+		chatReq.ResponseFormat = &ai.ResponseFormat{Type: format.Type}
+		if format.Type == ai.ResponseFormatJSONSchema {
+			if format.JSONSchema == nil {
+				return nil, invalidParams("response_format: json_schema is required")
+			}
+			chatReq.ResponseFormat.Name = format.JSONSchema.Name
+			chatReq.ResponseFormat.Schema = format.JSONSchema.Schema
+			chatReq.ResponseFormat.Strict = format.JSONSchema.Strict
+		}
+	}
+
+	return chatReq, nil
+}
+
+// toMessage translates an OpenAI message, developer messages are sent as system messages
+func toMessage(in *dto.ChatCompletionMessage) (ai.Message, error) {
+	msg := ai.Message{
+		Role:             in.Role,
+		ReasoningContent: in.ReasoningContent,
+		ToolCallID:       in.ToolCallID,
+		Name:             in.Name,
+	}
+	if msg.Role == "developer" {
+		msg.Role = ai.RoleSystem
+	}
+
+	for i, call := range in.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, ai.ToolCall{
+			Index: i,
+			ID:    call.ID,
+			Type:  ai.ToolTypeFunction,
+			Function: ai.FunctionCall{
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			},
+		})
+	}
+
+	content := strings.TrimSpace(string(in.Content))
+	switch {
+	case content == "" || content == "null":
+	case strings.HasPrefix(content, `"`):
+		if err := json.Unmarshal(in.Content, &msg.Content); err != nil {
+			return msg, err
+		}
+	default:
+		var parts []dto.ChatCompletionContentPart
+		if err := json.Unmarshal(in.Content, &parts); err != nil {
+			return msg, errors.New("content must be a string or an array of content parts")
+		}
+		for _, part := range parts {
+			switch {
+			case part.Type == ai.PartTypeText:
+				msg.Parts = append(msg.Parts, ai.ContentPart{Type: ai.PartTypeText, Text: part.Text})
+			case part.Type == ai.PartTypeImageURL && part.ImageURL != nil:
+				msg.Parts = append(msg.Parts, ai.ContentPart{Type: ai.PartTypeImageURL, URL: part.ImageURL.URL, Detail: part.ImageURL.Detail})
+			default:
+				return msg, fmt.Errorf("unsupported content part type %q", part.Type)
+			}
+		}
+	}
+	return msg, nil
+}
+
+// toStop reads stop sequences given as a string or an array of strings
+func toStop(raw json.RawMessage) ([]string, error) {
+	var stop string
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if err := json.Unmarshal(raw, &stop); err == nil {
+		return []string{stop}, nil
+	}
+	var stops []string
+	if err := json.Unmarshal(raw, &stops); err != nil {
+		return nil, errors.New("must be a string or an array of strings")
+	}
+	return stops, nil
+}
+
+// toToolChoice reads a tool choice given as auto, none or required, or as a named function object
+func toToolChoice(raw json.RawMessage) (*ai.ToolChoice, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var mode string
+	if err := json.Unmarshal(raw, &mode); err == nil {
+		if !slices.Contains([]string{ai.ToolChoiceAuto, ai.ToolChoiceNone, ai.ToolChoiceRequired}, mode) {
+			return nil, fmt.Errorf("unsupported tool choice %q", mode)
+		}
+		return &ai.ToolChoice{Type: mode}, nil
+	}
+
+	var named struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(raw, &named); err != nil || named.Function.Name == "" {
+		return nil, errors.New("must be auto, none, required or a function object with a name")
+	}
+	return &ai.ToolChoice{Type: ai.ToolChoiceFunction, Function: named.Function.Name}, nil
+}
+
+// toChatCompletion translates a chat response into an OpenAI chat completion
+func toChatCompletion(resp *ai.ChatResponse, model string) *vo.ChatCompletionResponse {
+	completion := &vo.ChatCompletionResponse{
+		ID:                resp.ID,
+		Object:            "chat.completion",
+		Created:           resp.Created,
+		Model:             reportedModel(resp.Model, model),
+		Choices:           make([]vo.ChatCompletionChoice, len(resp.Choices)),
+		Usage:             toChatCompletionUsage(&resp.Usage),
+		SystemFingerprint: resp.SystemFingerprint,
+	}
+	for i, choice := range resp.Choices {
+		message := vo.ChatCompletionMessage{
+			Role:             ai.RoleAssistant,
+			ReasoningContent: choice.Message.ReasoningContent,
+			ToolCalls:        toToolCalls(choice.Message.ToolCalls, false),
+		}
+		// Content is null on messages that only call tools
+		if choice.Message.Content != "" || len(message.ToolCalls) == 0 {
+			message.Content = &choice.Message.Content
+		}
+		completion.Choices[i] = vo.ChatCompletionChoice{
+			Index:        choice.Index,
+			Message:      message,
+			FinishReason: finishReason(choice.FinishReason),
+		}
+	}
+	return completion
+}
+
+// toChatCompletionChunk translates a stream response into an OpenAI chunk, the usage is sent separately
+func toChatCompletionChunk(resp *ai.ChatStreamResponse, model string) *vo.ChatCompletionChunk {
+	chunk := &vo.ChatCompletionChunk{
+		ID:                resp.ID,
+		Object:            "chat.completion.chunk",
+		Created:           resp.Created,
+		Model:             reportedModel(resp.Model, model),
+		Choices:           make([]vo.ChatCompletionChunkChoice, len(resp.Choices)),
+		SystemFingerprint: resp.SystemFingerprint,
+	}
+	for i, choice := range resp.Choices {
+		delta := vo.ChatCompletionMessage{
+			Role:             choice.Delta.Role,
+			ReasoningContent: choice.Delta.ReasoningContent,
+			ToolCalls:        toToolCalls(choice.Delta.ToolCalls, true),
+		}
+		if choice.Delta.Content != "" {
+			delta.Content = &choice.Delta.Content
+		}
+		chunk.Choices[i] = vo.ChatCompletionChunkChoice{
+			Index:        choice.Index,
+			Delta:        delta,
+			FinishReason: finishReason(choice.FinishReason),
+		}
+	}
+	return chunk
+}
+
+// toToolCalls translates tool calls, stream deltas keep their index so clients can merge them
+func toToolCalls(calls []ai.ToolCall, delta bool) []vo.ChatCompletionToolCall {
+	var out []vo.ChatCompletionToolCall
+	for _, call := range calls {
+		toolCall := vo.ChatCompletionToolCall{
+			ID:   call.ID,
+			Type: call.Type,
+			Function: vo.ChatCompletionFunctionCall{
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			},
+		}
+		if delta {
+			index := call.Index
+			toolCall.Index = &index
+		} else if toolCall.Type == "" {
+			toolCall.Type = ai.ToolTypeFunction
+		}
+		out = append(out, toolCall)
+	}
+	return out
+}
+
+func toChatCompletionUsage(usage *ai.Usage) *vo.ChatCompletionUsage {
+	return &vo.ChatCompletionUsage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+}
+
+// finishReason returns nil for an unfinished choice, serialized as null
+func finishReason(reason string) *string {
+	if reason == "" {
+		return nil
+	}
+	return &reason
+}
+
+// reportedModel returns the model the provider reported, falling back to the requested one
+func reportedModel(reported, requested string) string {
+	if reported != "" {
+		return reported
+	}
+	return requested
+}
+
+func invalidParams(format string, args ...any) error {
+	return errorx.New(errno.ErrInvalidParams, errorx.KV("msg", fmt.Sprintf(format, args...)))
+}
+
+// gatewayErrorResponse builds the OpenAI error of a failed stream with the localized errorx message
+func gatewayErrorResponse(c *gin.Context, err error) *vo.GatewayErrorResponse {
+	result := utilsVo.Fail(c, nil, err)
+	return &vo.GatewayErrorResponse{Error: vo.GatewayError{
+		Message: result.Error.Message,
+		Type:    "api_error",
+		Code:    result.Error.Code,
+	}}
+}
+
+// sendGatewayEvent emits an OpenAI stream event, which carries no event type
+func sendGatewayEvent(ch chan<- *sse.Event, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	ch <- &sse.Event{Data: string(payload)}
+}
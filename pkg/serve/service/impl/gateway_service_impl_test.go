@@ -0,0 +1,447 @@
+// Package impl provides OpenAI-compatible gateway service implementation test
+// Author: Done-0
+// Created: 2025-09-25
+package impl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Done-0/gin-scaffold/configs"
+	"github.com/Done-0/gin-scaffold/internal/ai"
+	"github.com/Done-0/gin-scaffold/internal/sse"
+	"github.com/Done-0/gin-scaffold/internal/types/errno"
+	"github.com/Done-0/gin-scaffold/internal/utils/errorx"
+	"github.com/Done-0/gin-scaffold/pkg/serve/controller"
+	"github.com/Done-0/gin-scaffold/pkg/serve/controller/dto"
+	"github.com/Done-0/gin-scaffold/pkg/vo"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testLoggerManager discards every log entry
+type testLoggerManager struct {
+	logger *logrus.Logger
+}
+
+func (m *testLoggerManager) Logger() *logrus.Logger { return m.logger }
+func (m *testLoggerManager) Initialize() error      { return nil }
+func (m *testLoggerManager) Close() error           { return nil }
+
+func newTestLoggerManager() *testLoggerManager {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return &testLoggerManager{logger: logger}
+}
+
+// initGatewayConfig loads a config enabling the gateway with keys test-gateway-key of caller tenant-a,
+// other-gateway-key of caller tenant-b and anonymous-gateway-key without caller, and one OpenAI instance serving
+// mock-model at baseURL
+func initGatewayConfig(t *testing.T, baseURL string) {
+	t.Helper()
+	testDir := t.TempDir()
+	configDir := filepath.Join(testDir, "configs")
+	os.MkdirAll(configDir, 0755)
+	os.WriteFile(filepath.Join(configDir, "config.local.yml"), []byte(fmt.Sprintf(`AI:
+  GATEWAY:
+    ENABLED: true
+    KEYS:
+      - KEY: "test-gateway-key"
+        CALLER: "tenant-a"
+      - KEY: "other-gateway-key"
+        CALLER: "tenant-b"
+      - KEY: "anonymous-gateway-key"
+  PROVIDERS:
+    openai:
+      ENABLED: true
+      INSTANCES:
+        - NAME: "mock"
+          ENABLED: true
+          BASE_URL: %q
+          KEYS: ["test-key"]
+          MODELS: ["mock-model"]
+          TIMEOUT: 5
+          MAX_RETRIES: 0
+          RATE_LIMIT: "100/s"`, baseURL)), 0644)
+
+	oldDir, _ := os.Getwd()
+	os.Chdir(testDir)
+	t.Cleanup(func() { os.Chdir(oldDir) })
+
+	if err := configs.New(); err != nil {
+		t.Fatalf("Failed to initialize config: %v", err)
+	}
+}
+
+func TestToMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      dto.ChatCompletionMessage
+		want    func(msg ai.Message) bool
+		wantErr bool
+	}{
+		{
+			name: "string content",
+			in:   dto.ChatCompletionMessage{Role: ai.RoleUser, Content: json.RawMessage(`"Hello"`)},
+			want: func(msg ai.Message) bool { return msg.Content == "Hello" && len(msg.Parts) == 0 },
+		},
+		{
+			name: "content parts",
+			in: dto.ChatCompletionMessage{Role: ai.RoleUser, Content: json.RawMessage(`[
+				{"type": "text", "text": "Describe"},
+				{"type": "image_url", "image_url": {"url": "https://example.com/cat.png", "detail": "low"}}
+			]`)},
+			want: func(msg ai.Message) bool {
+				return msg.Content == "" && len(msg.Parts) == 2 &&
+					msg.Parts[0] == ai.ContentPart{Type: ai.PartTypeText, Text: "Describe"} &&
+					msg.Parts[1] == ai.ContentPart{Type: ai.PartTypeImageURL, URL: "https://example.com/cat.png", Detail: "low"}
+			},
+		},
+		{
+			name: "null content of a tool-only message",
+			in: dto.ChatCompletionMessage{Role: ai.RoleAssistant, Content: json.RawMessage(`null`), ToolCalls: []dto.ChatCompletionToolCall{
+				{ID: "call_1", Type: "function", Function: struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				}{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+			}},
+			want: func(msg ai.Message) bool {
+				return msg.Content == "" && len(msg.ToolCalls) == 1 && msg.ToolCalls[0].ID == "call_1" &&
+					msg.ToolCalls[0].Type == ai.ToolTypeFunction && msg.ToolCalls[0].Function.Arguments == `{"city":"Paris"}`
+			},
+		},
+		{
+			name: "missing content of a tool result",
+			in:   dto.ChatCompletionMessage{Role: ai.RoleTool, ToolCallID: "call_1"},
+			want: func(msg ai.Message) bool { return msg.Content == "" && msg.ToolCallID == "call_1" },
+		},
+		{
+			name: "developer message",
+			in:   dto.ChatCompletionMessage{Role: "developer", Content: json.RawMessage(`"Be brief"`)},
+			want: func(msg ai.Message) bool { return msg.Role == ai.RoleSystem && msg.Content == "Be brief" },
+		},
+		{
+			name:    "number content",
+			in:      dto.ChatCompletionMessage{Role: ai.RoleUser, Content: json.RawMessage(`42`)},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported content part",
+			in:      dto.ChatCompletionMessage{Role: ai.RoleUser, Content: json.RawMessage(`[{"type": "input_audio"}]`)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := toMessage(&tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("toMessage() = %+v, want error", msg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("toMessage() failed: %v", err)
+			}
+			if !tt.want(msg) {
+				t.Errorf("toMessage() = %+v", msg)
+			}
+		})
+	}
+}
+
+func TestToStop(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    []string
+		wantErr bool
+	}{
+		{name: "missing", raw: ""},
+		{name: "null", raw: `null`},
+		{name: "string", raw: `"END"`, want: []string{"END"}},
+		{name: "array", raw: `["END", "STOP"]`, want: []string{"END", "STOP"}},
+		{name: "number", raw: `42`, wantErr: true},
+		{name: "array of numbers", raw: `[1, 2]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stop, err := toStop(json.RawMessage(tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("toStop() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(stop, "|") != strings.Join(tt.want, "|") || len(stop) != len(tt.want) {
+				t.Errorf("toStop() = %q, want %q", stop, tt.want)
+			}
+		})
+	}
+}
+
+func TestToToolChoice(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    *ai.ToolChoice
+		wantErr bool
+	}{
+		{name: "missing", raw: ""},
+		{name: "null", raw: `null`},
+		{name: "auto", raw: `"auto"`, want: &ai.ToolChoice{Type: ai.ToolChoiceAuto}},
+		{name: "none", raw: `"none"`, want: &ai.ToolChoice{Type: ai.ToolChoiceNone}},
+		{name: "required", raw: `"required"`, want: &ai.ToolChoice{Type: ai.ToolChoiceRequired}},
+		{name: "unknown mode", raw: `"sometimes"`, wantErr: true},
+		{name: "named function", raw: `{"type": "function", "function": {"name": "get_weather"}}`, want: &ai.ToolChoice{Type: ai.ToolChoiceFunction, Function: "get_weather"}},
+		{name: "function without name", raw: `{"type": "function"}`, wantErr: true},
+		{name: "number", raw: `1`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			choice, err := toToolChoice(json.RawMessage(tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("toToolChoice() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (choice == nil) != (tt.want == nil) || choice != nil && *choice != *tt.want {
+				t.Errorf("toToolChoice() = %+v, want %+v", choice, tt.want)
+			}
+		})
+	}
+}
+
+func TestToChatRequest(t *testing.T) {
+	initGatewayConfig(t, "http://127.0.0.1:0")
+
+	tests := []struct {
+		name     string
+		req      string
+		want     func(req *ai.ChatRequest) bool
+		wantCode int32 // errno of the expected error, 0=success
+	}{
+		{
+			name: "full request",
+			req: `{"model": "mock-model", "max_tokens": 10, "max_completion_tokens": 20, "stop": "END",
+				"tool_choice": {"type": "function", "function": {"name": "get_weather"}},
+				"tools": [{"type": "function", "function": {"name": "get_weather", "parameters": {"type": "object"}}}],
+				"response_format": {"type": "json_schema", "json_schema": {"name": "weather", "schema": {"type": "object"}, "strict": true}},
+				"messages": [{"role": "developer", "content": "Be brief"}, {"role": "user", "content": [{"type": "text", "text": "Weather?"}]}]}`,
+			want: func(req *ai.ChatRequest) bool {
+				return req.Model == "mock-model" && req.MaxTokens == 20 && len(req.Stop) == 1 && req.Stop[0] == "END" &&
+					req.ToolChoice != nil && req.ToolChoice.Function == "get_weather" &&
+					len(req.Tools) == 1 && req.Tools[0].Type == ai.ToolTypeFunction && req.Tools[0].Function.Parameters["type"] == "object" &&
+					req.ResponseFormat != nil && req.ResponseFormat.Name == "weather" && req.ResponseFormat.Strict &&
+					len(req.Messages) == 2 && req.Messages[0].Role == ai.RoleSystem && len(req.Messages[1].Parts) == 1
+			},
+		},
+		{
+			name:     "unserved model",
+			req:      `{"model": "other-model", "messages": [{"role": "user", "content": "Hi"}]}`,
+			wantCode: errno.ErrAIModelNotFound,
+		},
+		{
+			name:     "invalid message content",
+			req:      `{"model": "mock-model", "messages": [{"role": "user", "content": 42}]}`,
+			wantCode: errno.ErrInvalidParams,
+		},
+		{
+			name:     "invalid stop",
+			req:      `{"model": "mock-model", "stop": {"seq": "END"}, "messages": [{"role": "user", "content": "Hi"}]}`,
+			wantCode: errno.ErrInvalidParams,
+		},
+		{
+			name:     "invalid tool choice",
+			req:      `{"model": "mock-model", "tool_choice": "sometimes", "messages": [{"role": "user", "content": "Hi"}]}`,
+			wantCode: errno.ErrInvalidParams,
+		},
+		{
+			name:     "json schema format without schema",
+			req:      `{"model": "mock-model", "response_format": {"type": "json_schema"}, "messages": [{"role": "user", "content": "Hi"}]}`,
+			wantCode: errno.ErrInvalidParams,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req dto.ChatCompletionRequest
+			if err := json.Unmarshal([]byte(tt.req), &req); err != nil {
+				t.Fatalf("invalid test request: %v", err)
+			}
+
+			chatReq, err := toChatRequest(&req)
+			if tt.wantCode != 0 {
+				var statusErr errorx.StatusError
+				if !errors.As(err, &statusErr) || statusErr.Code() != tt.wantCode {
+					t.Errorf("toChatRequest() error = %v, want errno %d", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("toChatRequest() failed: %v", err)
+			}
+			if !tt.want(chatReq) {
+				t.Errorf("toChatRequest() = %+v", chatReq)
+			}
+		})
+	}
+}
+
+func TestToChatCompletionChunk(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *ai.ChatStreamResponse
+		want string
+	}{
+		{
+			name: "content delta",
+			resp: &ai.ChatStreamResponse{ID: "1", Created: 7, Model: "mock-model-2025", Choices: []ai.StreamChoice{
+				{Delta: ai.MessageDelta{Role: ai.RoleAssistant, Content: "Hi"}},
+			}},
+			want: `{"id":"1","object":"chat.completion.chunk","created":7,"model":"mock-model-2025","choices":[{"index":0,"delta":{"role":"assistant","content":"Hi"},"finish_reason":null}]}`,
+		},
+		{
+			name: "tool call delta keeps its index",
+			resp: &ai.ChatStreamResponse{ID: "1", Choices: []ai.StreamChoice{
+				{Delta: ai.MessageDelta{ToolCalls: []ai.ToolCall{{Index: 1, Function: ai.FunctionCall{Arguments: `{"city":`}}}}},
+			}},
+			want: `{"id":"1","object":"chat.completion.chunk","created":0,"model":"mock-model","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"{\"city\":"}}]},"finish_reason":null}]}`,
+		},
+		{
+			name: "finish",
+			resp: &ai.ChatStreamResponse{ID: "1", Choices: []ai.StreamChoice{{FinishReason: "stop"}}},
+			want: `{"id":"1","object":"chat.completion.chunk","created":0,"model":"mock-model","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		},
+		{
+			name: "usage only",
+			resp: &ai.ChatStreamResponse{ID: "1", Usage: &ai.Usage{TotalTokens: 5}},
+			want: `{"id":"1","object":"chat.completion.chunk","created":0,"model":"mock-model","choices":[]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(toChatCompletionChunk(tt.resp, "mock-model"))
+			if err != nil {
+				t.Fatalf("failed to marshal chunk: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("chunk = %s\nwant    %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatCompletionsStream(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunks := []string{
+			`{"id":"1","object":"chat.completion.chunk","created":7,"model":"mock-model","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+			`{"id":"1","object":"chat.completion.chunk","created":7,"model":"mock-model","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}]}`,
+			`{"id":"1","object":"chat.completion.chunk","created":7,"model":"mock-model","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`,
+		}
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer upstream.Close()
+	initGatewayConfig(t, upstream.URL)
+
+	cfg, err := configs.GetConfig()
+	if err != nil {
+		t.Fatalf("Failed to get config: %v", err)
+	}
+	aiManager, err := ai.New(cfg, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create AI manager: %v", err)
+	}
+	gatewayController := controller.NewGatewayController(NewGatewayService(newTestLoggerManager(), aiManager), sse.New(cfg))
+	router := gin.New()
+	gateway := router.Group("/v1", gatewayController.Authenticate)
+	gateway.POST("/chat/completions", gatewayController.ChatCompletions)
+
+	tests := []struct {
+		name         string
+		includeUsage bool
+	}{
+		{name: "without usage"},
+		{name: "with usage", includeUsage: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"model": "mock-model", "stream": true, "stream_options": {"include_usage": %t}, "messages": [{"role": "user", "content": "Hi"}]}`, tt.includeUsage)
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer test-gateway-key")
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+				t.Fatalf("response = %d %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+			}
+
+			// Every event is a single data line without an event type, the last one is [DONE]
+			var data []string
+			for _, event := range strings.Split(strings.TrimSuffix(w.Body.String(), "\n\n"), "\n\n") {
+				payload, ok := strings.CutPrefix(event, "data:")
+				if !ok || strings.Contains(event, "\n") {
+					t.Fatalf("event %q is not a single data line", event)
+				}
+				data = append(data, strings.TrimSpace(payload))
+			}
+			if len(data) == 0 || data[len(data)-1] != streamDone {
+				t.Fatalf("stream = %q, want it to end with [DONE]", data)
+			}
+
+			var id, content string
+			var usage *vo.ChatCompletionUsage
+			for _, payload := range data[:len(data)-1] {
+				var chunk vo.ChatCompletionChunk
+				if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+					t.Fatalf("invalid chunk %q: %v", payload, err)
+				}
+				if id == "" {
+					id = chunk.ID
+				}
+				if chunk.Object != "chat.completion.chunk" || chunk.ID != id {
+					t.Errorf("chunk = %s, want object chat.completion.chunk and id %s", payload, id)
+				}
+				if chunk.Usage != nil {
+					if len(chunk.Choices) != 0 {
+						t.Errorf("usage chunk = %s, want no choices", payload)
+					}
+					usage = chunk.Usage
+				}
+				for _, choice := range chunk.Choices {
+					if choice.Delta.Content != nil {
+						content += *choice.Delta.Content
+					}
+				}
+			}
+
+			if content != "Hello" {
+				t.Errorf("content = %q, want Hello", content)
+			}
+			if tt.includeUsage && (usage == nil || usage.TotalTokens != 5) {
+				t.Errorf("usage = %+v, want the trailer chunk with 5 total tokens", usage)
+			}
+			if !tt.includeUsage && usage != nil {
+				t.Errorf("usage = %+v, want no trailer chunk", usage)
+			}
+		})
+	}
+}
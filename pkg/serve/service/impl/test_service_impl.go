@@ -17,9 +17,12 @@ import (
 	"github.com/Done-0/gin-scaffold/internal/logger"
 	"github.com/Done-0/gin-scaffold/internal/redis"
 	"github.com/Done-0/gin-scaffold/internal/types/errno"
+	"github.com/Done-0/gin-scaffold/internal/utils/errorx"
 	"github.com/Done-0/gin-scaffold/pkg/serve/controller/dto"
 	"github.com/Done-0/gin-scaffold/pkg/serve/service"
 	"github.com/Done-0/gin-scaffold/pkg/vo"
+
+	sseUtil "github.com/Done-0/gin-scaffold/internal/utils/sse"
 )
 
 // TestServiceImpl test service implementation
@@ -127,7 +130,7 @@ func (ts *TestServiceImpl) TestI18n(c *gin.Context) (*vo.TestI18nResponse, error
 	}, nil
 }
 
-// TestStream handles SSE related test
+// TestStream handles SSE related test, token quotas only apply when an authenticating middleware set the caller
 func (ts *TestServiceImpl) TestStream(c *gin.Context, req *dto.TestStreamRequest) (<-chan *sse.Event, error) {
 	vars := map[string]any{
 		"user_name":    req.Name,
@@ -153,12 +156,19 @@ func (ts *TestServiceImpl) TestStream(c *gin.Context, req *dto.TestStreamRequest
 		}
 	}
 
+	// Keep the caller set by an authenticating middleware so quotas apply, the test routes have none and are not budgeted
+	baseCtx := ai.WithCaller(context.Background(), ai.CallerFrom(c.Request.Context()))
+	baseCtx = ai.WithCacheTTL(baseCtx, time.Duration(tmpl.CacheTTL)*time.Second)
+	if c.GetHeader(ai.CacheBypassHeader) != "" {
+		baseCtx = ai.WithoutCache(baseCtx)
+	}
+
 	events := make(chan *sse.Event, 100)
 
 	go func() {
 		defer close(events)
 
-		ctx, cancel := context.WithCancel(context.Background())
+		ctx, cancel := context.WithCancel(baseCtx)
 		defer cancel()
 
 		heartbeatTicker := time.NewTicker(15 * time.Second)
@@ -169,6 +179,12 @@ func (ts *TestServiceImpl) TestStream(c *gin.Context, req *dto.TestStreamRequest
 		})
 		if err != nil {
 			ts.loggerManager.Logger().Errorf("failed to start AI chat stream: %v", err)
+			// Keep the code of errors that already carry one, e.g. an exhausted token quota
+			var statusErr errorx.StatusError
+			if !errors.As(err, &statusErr) {
+				err = errorx.New(errno.ErrAIStreamFailed, errorx.KV("msg", err.Error()))
+			}
+			sseUtil.SendError(events, err)
 			return
 		}
 
@@ -188,6 +204,12 @@ func (ts *TestServiceImpl) TestStream(c *gin.Context, req *dto.TestStreamRequest
 				continue
 			}
 
+			if resp.Err != nil {
+				ts.loggerManager.Logger().Errorf("AI chat stream failed: %v", resp.Err)
+				sseUtil.SendError(events, errorx.New(errno.ErrAIStreamFailed, errorx.KV("msg", resp.Err.Error())))
+				return
+			}
+
 			payload, err := json.Marshal(resp)
 			if err != nil {
 				continue
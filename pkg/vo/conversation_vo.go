@@ -0,0 +1,29 @@
+// Package vo provides conversation-related value object definitions
+// Author: Done-0
+// Created: 2025-09-25
+package vo
+
+// ConversationResponse conversation response
+type ConversationResponse struct {
+	ID           int64  `json:"id,string"`
+	Caller       string `json:"caller"`
+	Title        string `json:"title"`
+	SystemPrompt string `json:"system_prompt"`
+	MessageCount int    `json:"message_count"`
+	CreatedAt    int64  `json:"created_at"`
+	UpdatedAt    int64  `json:"updated_at"`
+}
+
+// ListConversationsResponse list conversations response
+type ListConversationsResponse struct {
+	Items    []*ConversationResponse `json:"items"`
+	Total    int64                   `json:"total"`
+	Page     int                     `json:"page"`
+	PageSize int                     `json:"page_size"`
+}
+
+// DeleteConversationResponse delete conversation response
+type DeleteConversationResponse struct {
+	ID      int64  `json:"id,string"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,96 @@
+// Package vo provides OpenAI-compatible gateway value object definitions
+// Author: Done-0
+// Created: 2025-09-25
+package vo
+
+// ChatCompletionResponse OpenAI chat completion
+type ChatCompletionResponse struct {
+	ID                string                 `json:"id"`
+	Object            string                 `json:"object"` // chat.completion
+	Created           int64                  `json:"created"`
+	Model             string                 `json:"model"`
+	Choices           []ChatCompletionChoice `json:"choices"`
+	Usage             *ChatCompletionUsage   `json:"usage"`
+	SystemFingerprint string                 `json:"system_fingerprint,omitempty"`
+}
+
+// ChatCompletionChoice OpenAI chat completion choice
+type ChatCompletionChoice struct {
+	Index        int                   `json:"index"`
+	Message      ChatCompletionMessage `json:"message"`
+	FinishReason *string               `json:"finish_reason"`
+}
+
+// ChatCompletionMessage OpenAI response message, also used as the stream delta
+type ChatCompletionMessage struct {
+	Role             string                   `json:"role,omitempty"`
+	Content          *string                  `json:"content,omitempty"`
+	ReasoningContent string                   `json:"reasoning_content,omitempty"`
+	ToolCalls        []ChatCompletionToolCall `json:"tool_calls,omitempty"`
+}
+
+// ChatCompletionToolCall OpenAI tool call, Index is only set in stream deltas
+type ChatCompletionToolCall struct {
+	Index    *int                       `json:"index,omitempty"`
+	ID       string                     `json:"id,omitempty"`
+	Type     string                     `json:"type,omitempty"`
+	Function ChatCompletionFunctionCall `json:"function"`
+}
+
+// ChatCompletionFunctionCall OpenAI function call
+type ChatCompletionFunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// ChatCompletionUsage OpenAI token usage
+type ChatCompletionUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChatCompletionChunk OpenAI chat completion stream chunk
+type ChatCompletionChunk struct {
+	ID                string                      `json:"id"`
+	Object            string                      `json:"object"` // chat.completion.chunk
+	Created           int64                       `json:"created"`
+	Model             string                      `json:"model"`
+	Choices           []ChatCompletionChunkChoice `json:"choices"`
+	Usage             *ChatCompletionUsage        `json:"usage,omitempty"`
+	SystemFingerprint string                      `json:"system_fingerprint,omitempty"`
+}
+
+// ChatCompletionChunkChoice OpenAI stream chunk choice
+type ChatCompletionChunkChoice struct {
+	Index        int                   `json:"index"`
+	Delta        ChatCompletionMessage `json:"delta"`
+	FinishReason *string               `json:"finish_reason"`
+}
+
+// ModelListResponse OpenAI model list
+type ModelListResponse struct {
+	Object string       `json:"object"` // list
+	Data   []ModelEntry `json:"data"`
+}
+
+// ModelEntry OpenAI model
+type ModelEntry struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"` // model
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"` // Provider of the first instance serving the model
+}
+
+// GatewayErrorResponse OpenAI error body
+type GatewayErrorResponse struct {
+	Error GatewayError `json:"error"`
+}
+
+// GatewayError OpenAI error, Code carries the errno code
+type GatewayError struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"` // invalid_request_error, authentication_error, rate_limit_error or api_error
+	Param   *string `json:"param"`
+	Code    string  `json:"code"`
+}
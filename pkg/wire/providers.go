@@ -36,11 +36,15 @@ var MapperProviders = wire.NewSet()
 // ServiceProviders provides business logic layer dependencies
 var ServiceProviders = wire.NewSet(
 	impl.NewTestService,
+	impl.NewConversationService,
+	impl.NewGatewayService,
 )
 
 // ControllerProviders provides controller layer dependencies
 var ControllerProviders = wire.NewSet(
 	controller.NewTestController,
+	controller.NewConversationController,
+	controller.NewGatewayController,
 )
 
 // AllProviders combines all provider sets in dependency order
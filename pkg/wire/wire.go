@@ -35,7 +35,9 @@ type Container struct {
 	// QueueProducer   queue.Producer
 
 	// Controllers
-	TestController *controller.TestController
+	TestController         *controller.TestController
+	ConversationController *controller.ConversationController
+	GatewayController      *controller.GatewayController
 
 	// Services
 
@@ -22,12 +22,12 @@ import (
 
 // NewContainer initializes the complete application container using Wire
 func NewContainer(config *configs.Config) (*Container, error) {
-	manager, err := ai.New(config)
+	databaseManager := db.New(config)
+	redisManager, err := redis.New(config)
 	if err != nil {
 		return nil, err
 	}
-	databaseManager := db.New(config)
-	redisManager, err := redis.New(config)
+	manager, err := ai.New(config, databaseManager, redisManager)
 	if err != nil {
 		return nil, err
 	}
@@ -39,15 +39,21 @@ func NewContainer(config *configs.Config) (*Container, error) {
 	sseManager := sse.New(config)
 	testService := impl.NewTestService(loggerManager, redisManager, manager)
 	testController := controller.NewTestController(testService, sseManager)
+	conversationService := impl.NewConversationService(loggerManager, manager)
+	conversationController := controller.NewConversationController(conversationService)
+	gatewayService := impl.NewGatewayService(loggerManager, manager)
+	gatewayController := controller.NewGatewayController(gatewayService, sseManager)
 	container := &Container{
-		Config:          config,
-		AIManager:       manager,
-		DatabaseManager: databaseManager,
-		RedisManager:    redisManager,
-		LoggerManager:   loggerManager,
-		I18nManager:     i18nManager,
-		SSEManager:      sseManager,
-		TestController:  testController,
+		Config:                 config,
+		AIManager:              manager,
+		DatabaseManager:        databaseManager,
+		RedisManager:           redisManager,
+		LoggerManager:          loggerManager,
+		I18nManager:            i18nManager,
+		SSEManager:             sseManager,
+		TestController:         testController,
+		ConversationController: conversationController,
+		GatewayController:      gatewayController,
 	}
 	return container, nil
 }
@@ -67,5 +73,7 @@ type Container struct {
 	SSEManager      sse.SSEManager
 
 	// Controllers
-	TestController *controller.TestController
+	TestController         *controller.TestController
+	ConversationController *controller.ConversationController
+	GatewayController      *controller.GatewayController
 }